@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gophermart/internal/accrualsystem"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	if err := logger.Initialize("info"); err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	address := flag.String("a", "localhost:8081", "address and port to run accrual system")
+	delay := flag.Duration("processing-delay", time.Second, "time an order spends in each intermediate status")
+	rpm := flag.Int("rpm", 0, "max order info requests per minute, 0 - unlimited")
+	flag.Parse()
+
+	system := accrualsystem.NewSystem(accrualsystem.Config{ProcessingDelay: *delay})
+	defer system.Close()
+
+	srv := &http.Server{
+		Addr:    *address,
+		Handler: accrualsystem.NewHandler(system, *rpm),
+	}
+
+	go func() {
+		logger.Info("Starting local accrual system",
+			zap.String("address", *address),
+			zap.Duration("processing_delay", *delay),
+			zap.Int("requests_per_minute", *rpm))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Accrual system server error", zap.Error(err))
+			os.Exit(1)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Failed to stop accrual system server", zap.Error(err))
+	}
+	logger.Info("Local accrual system stopped")
+}
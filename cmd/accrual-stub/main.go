@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gophermart/internal/accrualstub"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	if err := logger.Initialize("info"); err != nil {
+		log.Fatalf("failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	address := flag.String("a", "localhost:8081", "address and port to run stub")
+	scenarioPath := flag.String("scenario", "", "path to JSON scenario file")
+	flag.Parse()
+
+	// Без сценария все заказы не зарегистрированы, поведение задается через /control
+	var scenario accrualstub.Scenario
+	if *scenarioPath != "" {
+		var err error
+		scenario, err = accrualstub.LoadScenario(*scenarioPath)
+		if err != nil {
+			logger.Error("Failed to load scenario", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	srv := &http.Server{
+		Addr:    *address,
+		Handler: accrualstub.NewServer(scenario),
+	}
+
+	go func() {
+		logger.Info("Starting accrual stub",
+			zap.String("address", *address),
+			zap.String("scenario", *scenarioPath))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Stub server error", zap.Error(err))
+			os.Exit(1)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Failed to stop stub server", zap.Error(err))
+	}
+	logger.Info("Accrual stub stopped")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/storage"
+	"gophermart/internal/usecase"
+)
+
+const adminUsage = `usage: gophermart admin [-d database_uri] [-actor name] <command> [flags]
+
+commands:
+  requeue       -order N [-reason text]
+  requeue-bulk  -status STATUS [-from time] [-to time] [-reason text]
+  force         -order N -status PROCESSED|INVALID [-accrual sum] -reason text
+
+time is RFC 3339 or YYYY-MM-DD
+`
+
+// runAdmin выполняет административную команду напрямую через хранилище и возвращает код выхода
+func runAdmin(args []string) int {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, adminUsage) }
+	databaseURI := flags.String("d", os.Getenv("DATABASE_URI"), "database connection string")
+	actor := flags.String("actor", os.Getenv("USER"), "operator name for the audit log")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *databaseURI == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if *actor == "" {
+		*actor = "cli"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	store, err := storage.NewPostgresRepository(ctx, *databaseURI)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	admin := usecase.NewAdminUseCase(store)
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "requeue":
+		cmd := flag.NewFlagSet("requeue", flag.ContinueOnError)
+		order := cmd.String("order", "", "order number")
+		reason := cmd.String("reason", "", "reason for the audit log")
+		if err := cmd.Parse(commandArgs); err != nil || *order == "" {
+			fmt.Fprint(os.Stderr, adminUsage)
+			return 2
+		}
+		err = admin.RequeueOrder(ctx, *actor, *order, *reason)
+		if err == nil {
+			fmt.Printf("order %s requeued\n", *order)
+		}
+
+	case "requeue-bulk":
+		cmd := flag.NewFlagSet("requeue-bulk", flag.ContinueOnError)
+		status := cmd.String("status", "", "status of orders to requeue")
+		from := cmd.String("from", "", "uploaded at or after")
+		to := cmd.String("to", "", "uploaded before")
+		reason := cmd.String("reason", "", "reason for the audit log")
+		if err := cmd.Parse(commandArgs); err != nil || *status == "" {
+			fmt.Fprint(os.Stderr, adminUsage)
+			return 2
+		}
+		filter := domain.RequeueFilter{Status: domain.OrderStatus(*status)}
+		if filter.UploadedFrom, err = parseAdminTime(*from); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -from: %v\n", err)
+			return 2
+		}
+		if filter.UploadedTo, err = parseAdminTime(*to); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -to: %v\n", err)
+			return 2
+		}
+		var count int
+		count, err = admin.RequeueOrders(ctx, *actor, filter, *reason)
+		if err == nil {
+			fmt.Printf("%d orders requeued\n", count)
+		}
+
+	case "force":
+		cmd := flag.NewFlagSet("force", flag.ContinueOnError)
+		order := cmd.String("order", "", "order number")
+		status := cmd.String("status", "", "PROCESSED or INVALID")
+		accrualValue := cmd.String("accrual", "0", "accrual for PROCESSED")
+		reason := cmd.String("reason", "", "mandatory reason for the audit log")
+		if err := cmd.Parse(commandArgs); err != nil || *order == "" || *status == "" {
+			fmt.Fprint(os.Stderr, adminUsage)
+			return 2
+		}
+		accrual, parseErr := domain.ParseMoney(*accrualValue)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid -accrual: %v\n", parseErr)
+			return 2
+		}
+		err = admin.ForceOrderStatus(ctx, *actor, *order, domain.OrderStatus(*status), accrual, *reason)
+		if err == nil {
+			fmt.Printf("order %s set to %s\n", *order, *status)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown admin command %q\n\n%s", command, adminUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
+
+// parseAdminTime разбирает время в формате RFC 3339 или дату YYYY-MM-DD; пустая строка - нулевое время
+func parseAdminTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
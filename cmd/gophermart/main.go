@@ -5,8 +5,6 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"runtime"
 	"syscall"
 	"time"
 
@@ -29,6 +27,18 @@ func main() {
 	}
 	defer logger.Sync()
 
+	// Административные команды выполняются без запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		code := runAdmin(os.Args[2:])
+		logger.Sync()
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := runMigrate(os.Args[2:])
+		logger.Sync()
+		os.Exit(code)
+	}
+
 	logger.Info("Starting GopherMart service")
 
 	// Загружаем конфигурацию
@@ -39,59 +49,148 @@ func main() {
 	}
 	logger.Info("Config loaded successfully",
 		zap.String("run_address", cfg.RunAddress),
+		zap.String("storage", cfg.Storage),
+		zap.Bool("skip_migrations", cfg.SkipMigrations),
 		zap.String("database_uri", cfg.DatabaseURI),
-		zap.String("accrual_address", cfg.AccrualSystemAddress))
-
-	// Определяем путь к миграциям относительно исполняемого файла
-	_, filename, _, ok := runtime.Caller(0)
-	if !ok {
-		logger.Error("Failed to determine executable path")
-		os.Exit(1)
-	}
-	execDir := filepath.Dir(filename)
-	projectRoot := filepath.Dir(filepath.Dir(execDir))
-	migrationsPath := filepath.Join(projectRoot, "migrations")
-
-	logger.Info("Running migrations",
-		zap.String("migrations_path", migrationsPath),
-		zap.String("database_uri", cfg.DatabaseURI))
-
-	// Запускаем миграции
-	if err := storage.RunMigrations(cfg.DatabaseURI, migrationsPath); err != nil {
-		logger.Error("Failed to run migrations", zap.Error(err))
-		os.Exit(1)
-	}
-	logger.Info("Database migrations completed successfully")
+		zap.String("accrual_address", cfg.AccrualSystemAddress),
+		zap.String("instance_id", cfg.InstanceID))
 
 	// Инициализируем хранилище
-	store, err := storage.NewPostgresRepository(context.Background(), cfg.DatabaseURI)
-	if err != nil {
-		logger.Error("Failed to initialize storage", zap.Error(err))
-		os.Exit(1)
+	var store usecase.Storage
+	switch cfg.Storage {
+	case config.StorageMemory:
+		logger.Warn("Using in-memory storage, data will be lost on restart")
+		store = storage.NewMemoryStorage()
+
+	default:
+		store, err = newPostgresStorage(cfg.DatabaseURI, cfg.SkipMigrations)
+		if err != nil {
+			logger.Error("Failed to initialize storage", zap.Error(err))
+			os.Exit(1)
+		}
 	}
 	defer store.Close()
-	logger.Info("Storage initialized successfully")
+	logger.Info("Storage initialized successfully", zap.String("storage", cfg.Storage))
 
 	// Инициализируем JWT manager
 	jwtManager := jwt.NewManager(cfg.JWT.SigningKey, cfg.JWT.TokenTTL)
 	logger.Info("JWT manager initialized",
 		zap.Duration("token_ttl", cfg.JWT.TokenTTL))
 
-	// Инициализируем сервис начислений
-	accrualService := accrual.NewService(cfg.AccrualSystemAddress)
+	// Инициализируем сервис начислений за автоматическим выключателем. Проверка доступности
+	// возвращает запросы на основной адрес после его восстановления.
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+	accrualTransport := accrual.TransportConfig{
+		CAFile:              cfg.Accrual.CAFile,
+		CertFile:            cfg.Accrual.CertFile,
+		KeyFile:             cfg.Accrual.KeyFile,
+		AuthHeader:          cfg.Accrual.AuthHeader,
+		RequestTimeout:      cfg.Accrual.RequestTimeout,
+		MaxIdleConns:        cfg.Accrual.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.Accrual.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.Accrual.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.Accrual.IdleConnTimeout,
+		CassetteMode:        accrual.CassetteMode(cfg.Accrual.CassetteMode),
+		CassettePath:        cfg.Accrual.CassettePath,
+	}
+	accrualHTTPClient, err := accrual.NewHTTPClient(accrualTransport)
+	if err != nil {
+		logger.Error("Failed to configure accrual client", zap.Error(err))
+		os.Exit(1)
+	}
+	accrualService := accrual.NewServiceWithClient(accrualHTTPClient, cfg.AccrualAddresses()...)
+	// При воспроизведении кассеты сети нет и проверять доступность нечего
+	if accrual.CassetteMode(cfg.Accrual.CassetteMode) != accrual.CassetteReplay {
+		probeClient, err := accrual.NewProbeHTTPClient(accrualTransport)
+		if err != nil {
+			logger.Error("Failed to configure accrual health checks", zap.Error(err))
+			os.Exit(1)
+		}
+		accrualService.StartHealthChecks(healthCtx, probeClient, cfg.Accrual.HealthInterval)
+	}
+
+	// Внесение сбоев стоит перед выключателем, чтобы учения проверяли и его
+	var accrualClient accrual.Client = accrualService
+	faults := accrual.FaultConfig{
+		Latency:             cfg.Accrual.Faults.Latency,
+		LatencyJitter:       cfg.Accrual.Faults.LatencyJitter,
+		ErrorRate:           cfg.Accrual.Faults.ErrorRate,
+		TooManyRequestsRate: cfg.Accrual.Faults.TooManyRequestsRate,
+		MaxRetryAfter:       cfg.Accrual.Faults.MaxRetryAfter,
+		MalformedRate:       cfg.Accrual.Faults.MalformedRate,
+	}
+	if err := faults.Validate(); err != nil {
+		logger.Error("Invalid accrual fault injection settings", zap.Error(err))
+		os.Exit(1)
+	}
+	if faults.Enabled() {
+		logger.Warn("Accrual fault injection enabled",
+			zap.Duration("latency", faults.Latency),
+			zap.Duration("latency_jitter", faults.LatencyJitter),
+			zap.Float64("error_rate", faults.ErrorRate),
+			zap.Float64("too_many_requests_rate", faults.TooManyRequestsRate),
+			zap.Float64("malformed_rate", faults.MalformedRate))
+		accrualClient = accrual.NewFaultInjector(accrualService, faults)
+	}
+
+	accrualBreaker := accrual.NewCircuitBreaker(accrualClient, accrual.BreakerConfig{
+		FailureThreshold: cfg.Accrual.BreakerFailures,
+		OpenTimeout:      cfg.Accrual.BreakerTimeout,
+		HalfOpenRequests: cfg.Accrual.BreakerHalfOpenRequests,
+	})
 	logger.Info("Accrual service initialized",
-		zap.String("address", cfg.AccrualSystemAddress))
+		zap.Strings("addresses", cfg.AccrualAddresses()),
+		zap.Duration("health_interval", cfg.Accrual.HealthInterval),
+		zap.Bool("mtls", cfg.Accrual.CertFile != ""),
+		zap.String("cassette_mode", cfg.Accrual.CassetteMode),
+		zap.Int("breaker_failures", cfg.Accrual.BreakerFailures),
+		zap.Duration("breaker_timeout", cfg.Accrual.BreakerTimeout))
 
 	// Инициализируем usecase и обработчики
 	userUseCase := usecase.NewUserUseCase(store, jwtManager)
-	orderUseCase := usecase.NewOrderUseCase(store, accrualService)
+	orderUseCase := usecase.NewOrderUseCase(store, accrualBreaker)
 	balanceUseCase := usecase.NewBalanceUseCase(store)
+	adminUseCase := usecase.NewAdminUseCase(store)
+
+	// Пустое расписание заменяется расписанием по умолчанию
+	var pollSchedule usecase.PollSchedule
+	if cfg.Accrual.PollSchedule != "" {
+		pollSchedule, err = usecase.ParsePollSchedule(cfg.Accrual.PollSchedule)
+		if err != nil {
+			logger.Error("Invalid accrual poll schedule", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	// Запускаем обработку очереди начислений, включая задания, оставшиеся с прошлого запуска
+	orderUseCase.StartAccrualWorker(usecase.AccrualWorkerConfig{
+		Workers:           cfg.Accrual.Workers,
+		RequestsPerSecond: cfg.Accrual.RequestsPerSecond,
+		InstanceID:        cfg.InstanceID,
+		LeaseTTL:          cfg.Accrual.LeaseTTL,
+		CallbackTimeout:   cfg.Accrual.CallbackTimeout,
+		Schedule:          pollSchedule,
+	})
+	// Сверка находит начисления, изменившиеся после обработки заказа
+	orderUseCase.StartReconciliation(usecase.ReconciliationConfig{
+		Interval: cfg.Accrual.ReconcileInterval,
+		Window:   cfg.Accrual.ReconcileWindow,
+	})
 
 	authHandler := handler.NewAuthHandler(userUseCase)
 	orderHandler := handler.NewOrderHandler(orderUseCase)
 	balanceHandler := handler.NewBalanceHandler(balanceUseCase)
+	// Уведомления принимаются, только если задан общий секрет подписи
+	var callbackDecoder handler.AccrualCallbackDecoder
+	if cfg.Accrual.CallbackSecret != "" {
+		callbackDecoder = accrual.NewCallbackDecoder([]byte(cfg.Accrual.CallbackSecret), cfg.Accrual.CallbackReplayWindow)
+	}
+	accrualHandler := handler.NewAccrualHandler(accrualBreaker, accrual.EndpointMetrics(), callbackDecoder, orderUseCase, orderUseCase)
+
+	adminHandler := handler.NewAdminHandler(adminUseCase, cfg.AdminToken)
 
-	h := handler.NewHandler(authHandler, orderHandler, balanceHandler)
+	h := handler.NewHandler(authHandler, orderHandler, balanceHandler, accrualHandler, adminHandler)
 	router := handler.NewRouter(h)
 	logger.Info("Handlers initialized successfully")
 
@@ -119,6 +218,12 @@ func main() {
 
 	// Останавливаем обработку заказов
 	orderUseCase.Shutdown(ctx)
+	stopHealthChecks()
+
+	// Сохраняем кассету, если обмен с системой начислений записывался
+	if err := accrual.CloseHTTPClient(accrualHTTPClient); err != nil {
+		logger.Error("Failed to save accrual cassette", zap.Error(err))
+	}
 
 	// Останавливаем HTTP сервер
 	if err := srv.Stop(ctx); err != nil {
@@ -132,3 +237,19 @@ func main() {
 
 	logger.Info("Server stopped")
 }
+
+// newPostgresStorage подключается к PostgreSQL, предварительно применяя встроенные
+// миграции, если их применение не отключено
+func newPostgresStorage(databaseURI string, skipMigrations bool) (*storage.PostgresRepository, error) {
+	if skipMigrations {
+		logger.Info("Skipping database migrations")
+	} else {
+		logger.Info("Running migrations", zap.String("database_uri", databaseURI))
+		if err := storage.RunMigrations(databaseURI); err != nil {
+			return nil, err
+		}
+		logger.Info("Database migrations completed successfully")
+	}
+
+	return storage.NewPostgresRepository(context.Background(), databaseURI)
+}
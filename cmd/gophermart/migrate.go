@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gophermart/internal/storage"
+)
+
+const migrateUsage = `usage: gophermart migrate [-d database_uri] <command>
+
+commands:
+  up        apply all pending migrations
+  down [N]  roll back the last N migrations, 1 by default
+  status    print the schema version and the latest embedded migration
+  goto N    migrate up or down to version N
+  force N   mark version N as applied without running it, clears the dirty flag
+`
+
+// runMigrate управляет схемой базы данных встроенными миграциями и возвращает код выхода
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	databaseURI := flags.String("d", os.Getenv("DATABASE_URI"), "database connection string")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *databaseURI == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+
+	// version разбирает обязательный номер версии команды
+	version := func() (int, bool) {
+		if len(commandArgs) != 1 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 0, false
+		}
+		v, err := strconv.Atoi(commandArgs[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", commandArgs[0])
+			return 0, false
+		}
+		return v, true
+	}
+
+	var err error
+	switch command {
+	case "up":
+		err = storage.RunMigrations(*databaseURI)
+
+	case "down":
+		steps := 1
+		if len(commandArgs) > 0 {
+			steps, err = strconv.Atoi(commandArgs[0])
+			if err != nil || steps <= 0 || len(commandArgs) > 1 {
+				fmt.Fprint(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		err = storage.RollbackMigrations(*databaseURI, steps)
+
+	case "status":
+		var status *storage.MigrationStatus
+		status, err = storage.GetMigrationStatus(*databaseURI)
+		if err == nil {
+			printMigrationStatus(status)
+		}
+
+	case "goto":
+		v, ok := version()
+		if !ok || v < 0 {
+			return 2
+		}
+		err = storage.MigrateTo(*databaseURI, uint(v))
+
+	case "force":
+		v, ok := version()
+		if !ok || v < -1 {
+			return 2
+		}
+		err = storage.ForceMigrationVersion(*databaseURI, v)
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", command, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", command, err)
+		return 1
+	}
+
+	// После изменения схемы показываем, в каком состоянии она осталась
+	if command != "status" {
+		status, err := storage.GetMigrationStatus(*databaseURI)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "status failed: %v\n", err)
+			return 1
+		}
+		printMigrationStatus(status)
+	}
+	return 0
+}
+
+// printMigrationStatus выводит состояние схемы в одну строку
+func printMigrationStatus(status *storage.MigrationStatus) {
+	switch {
+	case status.Dirty:
+		fmt.Printf("version %d (dirty), latest %d\n", status.Version, status.Latest)
+	case status.Version == 0:
+		fmt.Printf("no migrations applied, latest %d\n", status.Latest)
+	default:
+		fmt.Printf("version %d, latest %d\n", status.Version, status.Latest)
+	}
+}
@@ -0,0 +1,190 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Client определяет операции системы начислений, которые оборачивает выключатель
+type Client interface {
+	GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.Order, error)
+}
+
+// BreakerConfig содержит настройки автоматического выключателя
+type BreakerConfig struct {
+	// FailureThreshold - число ошибок подряд, после которого выключатель размыкается
+	FailureThreshold int
+	// OpenTimeout - время, в течение которого запросы блокируются
+	OpenTimeout time.Duration
+	// HalfOpenRequests - число успешных пробных запросов, необходимых для замыкания
+	HalfOpenRequests int
+}
+
+// CircuitBreaker блокирует запросы к системе начислений во время её недоступности
+type CircuitBreaker struct {
+	next Client
+	cfg  BreakerConfig
+
+	mu        sync.Mutex
+	state     domain.CircuitState
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+	lastError string
+	now       func() time.Time
+}
+
+// NewCircuitBreaker создает выключатель вокруг next
+func NewCircuitBreaker(next Client, cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+
+	return &CircuitBreaker{
+		next:  next,
+		cfg:   cfg,
+		state: domain.CircuitClosed,
+		now:   time.Now,
+	}
+}
+
+// GetOrderAccrual выполняет запрос, если выключатель его пропускает
+func (b *CircuitBreaker) GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.Order, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	order, err := b.next.GetOrderAccrual(ctx, orderNumber)
+	b.record(err)
+	return order, err
+}
+
+// Status возвращает текущее состояние выключателя
+func (b *CircuitBreaker) Status() domain.CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := domain.CircuitBreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != domain.CircuitClosed {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(b.cfg.OpenTimeout)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// allow решает, можно ли выполнить запрос, и резервирует пробный запрос в полуоткрытом состоянии
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case domain.CircuitOpen:
+		retryAt := b.openedAt.Add(b.cfg.OpenTimeout)
+		if b.now().Before(retryAt) {
+			return domain.NewCircuitOpenError(retryAt.Sub(b.now()))
+		}
+		b.transition(domain.CircuitHalfOpen)
+		b.probes = 1
+		return nil
+
+	case domain.CircuitHalfOpen:
+		// Одновременно выполняется не больше пробных запросов, чем требуется для замыкания
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return domain.NewCircuitOpenError(b.cfg.OpenTimeout)
+		}
+		b.probes++
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// record учитывает результат запроса
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBreakerFailure(err) {
+		switch b.state {
+		case domain.CircuitClosed:
+			b.failures = 0
+		case domain.CircuitHalfOpen:
+			b.successes++
+			if b.successes >= b.cfg.HalfOpenRequests {
+				b.transition(domain.CircuitClosed)
+			}
+		}
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+
+	switch b.state {
+	case domain.CircuitHalfOpen:
+		// Любая ошибка пробного запроса снова размыкает выключатель
+		b.transition(domain.CircuitOpen)
+	case domain.CircuitClosed:
+		if b.failures >= b.cfg.FailureThreshold {
+			b.transition(domain.CircuitOpen)
+		}
+	}
+}
+
+// transition переводит выключатель в новое состояние; вызывается под мьютексом
+func (b *CircuitBreaker) transition(state domain.CircuitState) {
+	from := b.state
+	b.state = state
+	b.probes = 0
+	b.successes = 0
+
+	switch state {
+	case domain.CircuitOpen:
+		b.openedAt = b.now()
+		logger.Warn("Accrual circuit breaker opened",
+			zap.String("from", string(from)),
+			zap.Int("consecutive_failures", b.failures),
+			zap.Duration("open_timeout", b.cfg.OpenTimeout),
+			zap.String("last_error", b.lastError))
+	case domain.CircuitHalfOpen:
+		logger.Info("Accrual circuit breaker half-open, probing accrual system",
+			zap.String("from", string(from)))
+	case domain.CircuitClosed:
+		b.failures = 0
+		logger.Info("Accrual circuit breaker closed",
+			zap.String("from", string(from)))
+	}
+}
+
+// isBreakerFailure сообщает, говорит ли ошибка о недоступности системы начислений.
+// Ответ 429, некорректный ответ по отдельному заказу и отмена контекста означают,
+// что система доступна.
+func isBreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, domain.ErrInvalidAccrualResponse) {
+		return false
+	}
+
+	var tooManyRequestsErr *domain.TooManyRequestsError
+	return !errors.As(err, &tooManyRequestsErr)
+}
@@ -0,0 +1,106 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+)
+
+func init() {
+	if err := logger.Initialize("error"); err != nil {
+		panic(err)
+	}
+}
+
+// clientFunc адаптирует функцию к интерфейсу Client
+type clientFunc func(ctx context.Context, orderNumber string) (*domain.Order, error)
+
+func (f clientFunc) GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.Order, error) {
+	return f(ctx, orderNumber)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int
+	var fail bool
+	next := clientFunc(func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+		calls++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &domain.Order{Number: orderNumber, Status: domain.StatusProcessing}, nil
+	})
+
+	now := time.Now()
+	breaker := NewCircuitBreaker(next, BreakerConfig{
+		FailureThreshold: 3,
+		OpenTimeout:      time.Minute,
+		HalfOpenRequests: 1,
+	})
+	breaker.now = func() time.Time { return now }
+
+	// Ошибки подряд размыкают выключатель
+	fail = true
+	for i := 0; i < 3; i++ {
+		if _, err := breaker.GetOrderAccrual(context.Background(), "1"); err == nil {
+			t.Fatal("expected error from accrual system")
+		}
+	}
+	if state := breaker.Status().State; state != domain.CircuitOpen {
+		t.Fatalf("expected state %s, got %s", domain.CircuitOpen, state)
+	}
+
+	// В разомкнутом состоянии запросы не доходят до системы начислений
+	_, err := breaker.GetOrderAccrual(context.Background(), "1")
+	var circuitOpenErr *domain.CircuitOpenError
+	if !errors.As(err, &circuitOpenErr) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+	if circuitOpenErr.RetryAfter != time.Minute {
+		t.Errorf("expected retry after %v, got %v", time.Minute, circuitOpenErr.RetryAfter)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls to accrual system, got %d", calls)
+	}
+
+	// После таймаута неудачный пробный запрос снова размыкает выключатель
+	now = now.Add(time.Minute)
+	if _, err := breaker.GetOrderAccrual(context.Background(), "1"); err == nil {
+		t.Fatal("expected probe to fail")
+	}
+	if state := breaker.Status().State; state != domain.CircuitOpen {
+		t.Fatalf("expected state %s after failed probe, got %s", domain.CircuitOpen, state)
+	}
+
+	// Успешный пробный запрос замыкает выключатель
+	now = now.Add(time.Minute)
+	fail = false
+	if _, err := breaker.GetOrderAccrual(context.Background(), "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status := breaker.Status()
+	if status.State != domain.CircuitClosed {
+		t.Fatalf("expected state %s, got %s", domain.CircuitClosed, status.State)
+	}
+	if status.ConsecutiveFailures != 0 {
+		t.Errorf("expected failures to be reset, got %d", status.ConsecutiveFailures)
+	}
+}
+
+func TestCircuitBreaker_IgnoresThrottling(t *testing.T) {
+	next := clientFunc(func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+		return nil, domain.NewTooManyRequestsError(time.Second)
+	})
+
+	breaker := NewCircuitBreaker(next, BreakerConfig{FailureThreshold: 1})
+	for i := 0; i < 5; i++ {
+		breaker.GetOrderAccrual(context.Background(), "1")
+	}
+
+	if state := breaker.Status().State; state != domain.CircuitClosed {
+		t.Errorf("expected 429 not to open breaker, got state %s", state)
+	}
+}
@@ -0,0 +1,78 @@
+package accrual
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+const (
+	// TimestampHeader содержит время отправки уведомления в секундах Unix
+	TimestampHeader = "X-Accrual-Timestamp"
+	// SignatureHeader содержит HMAC-SHA256 от "<timestamp>.<body>" в hex
+	SignatureHeader = "X-Accrual-Signature"
+)
+
+// CallbackDecoder проверяет подпись уведомлений системы начислений и разбирает их
+type CallbackDecoder struct {
+	secret       []byte
+	replayWindow time.Duration
+	now          func() time.Time
+}
+
+// NewCallbackDecoder создает декодер уведомлений с общим секретом.
+// Уведомления с временем отправки дальше replayWindow от текущего отклоняются.
+func NewCallbackDecoder(secret []byte, replayWindow time.Duration) *CallbackDecoder {
+	return &CallbackDecoder{
+		secret:       secret,
+		replayWindow: replayWindow,
+		now:          time.Now,
+	}
+}
+
+// Decode проверяет подпись и возвращает результат расчёта из уведомления.
+// Возвращает domain.ErrInvalidSignature или ошибку, соответствующую domain.ErrInvalidAccrualResponse.
+func (d *CallbackDecoder) Decode(header http.Header, body []byte) (*domain.Order, error) {
+	timestamp := header.Get(TimestampHeader)
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad timestamp", domain.ErrInvalidSignature)
+	}
+
+	age := d.now().Sub(time.Unix(sentAt, 0))
+	if age > d.replayWindow || age < -d.replayWindow {
+		return nil, fmt.Errorf("%w: timestamp outside replay window", domain.ErrInvalidSignature)
+	}
+
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil || !hmac.Equal(signature, Sign(d.secret, timestamp, body)) {
+		return nil, fmt.Errorf("%w: signature mismatch", domain.ErrInvalidSignature)
+	}
+
+	var resp accrualResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, domain.NewAccrualResponseError("", domain.ErrAccrualMalformedBody, err.Error())
+	}
+
+	if resp.Order == "" {
+		return nil, domain.NewAccrualResponseError("", domain.ErrAccrualMalformedBody, "missing order number")
+	}
+
+	return mapAccrualResponse(resp.Order, resp)
+}
+
+// Sign вычисляет подпись уведомления
+func Sign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
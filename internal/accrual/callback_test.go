@@ -0,0 +1,96 @@
+package accrual
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+func TestCallbackDecoder_Decode(t *testing.T) {
+	secret := []byte("callback-secret")
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`)
+
+	signed := func(sentAt time.Time, key []byte, payload []byte) http.Header {
+		timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+		header := http.Header{}
+		header.Set(TimestampHeader, timestamp)
+		header.Set(SignatureHeader, hex.EncodeToString(Sign(key, timestamp, payload)))
+		return header
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "Корректное уведомление",
+			header: signed(now, secret, body),
+			body:   body,
+		},
+		{
+			name:    "Чужой секрет",
+			header:  signed(now, []byte("other"), body),
+			body:    body,
+			wantErr: domain.ErrInvalidSignature,
+		},
+		{
+			name:    "Изменённое тело",
+			header:  signed(now, secret, body),
+			body:    []byte(`{"order":"12345678903","status":"PROCESSED","accrual":5000}`),
+			wantErr: domain.ErrInvalidSignature,
+		},
+		{
+			name:    "Просроченная подпись",
+			header:  signed(now.Add(-10*time.Minute), secret, body),
+			body:    body,
+			wantErr: domain.ErrInvalidSignature,
+		},
+		{
+			name:    "Нет заголовков",
+			header:  http.Header{},
+			body:    body,
+			wantErr: domain.ErrInvalidSignature,
+		},
+		{
+			name:    "Неизвестный статус",
+			header:  signed(now, secret, []byte(`{"order":"12345678903","status":"DONE"}`)),
+			body:    []byte(`{"order":"12345678903","status":"DONE"}`),
+			wantErr: domain.ErrAccrualUnknownStatus,
+		},
+		{
+			name:    "Без номера заказа",
+			header:  signed(now, secret, []byte(`{"status":"PROCESSING"}`)),
+			body:    []byte(`{"status":"PROCESSING"}`),
+			wantErr: domain.ErrAccrualMalformedBody,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewCallbackDecoder(secret, 5*time.Minute)
+			decoder.now = func() time.Time { return now }
+
+			order, err := decoder.Decode(tt.header, tt.body)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if order.Number != "12345678903" || order.Status != domain.StatusProcessed || order.Accrual != domain.Rubles(500) {
+				t.Errorf("unexpected order: %+v", order)
+			}
+		})
+	}
+}
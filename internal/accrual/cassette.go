@@ -0,0 +1,247 @@
+package accrual
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CassetteMode - режим работы клиента с кассетой записанных ответов
+type CassetteMode string
+
+const (
+	// CassetteOff - запросы идут в систему начислений без записи
+	CassetteOff CassetteMode = ""
+	// CassetteRecord - запросы идут в систему начислений, пары запрос-ответ записываются в кассету
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay - ответы берутся из кассеты, сеть не используется
+	CassetteReplay CassetteMode = "replay"
+)
+
+// Cassette - записанный обмен с системой начислений
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction - одна пара запрос-ответ. Запрос сохраняется без адреса и заголовков,
+// поэтому кассета не содержит секретов и воспроизводится с любым адресом.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest - запрос, по которому подбирается ответ при воспроизведении
+type RecordedRequest struct {
+	Method string `json:"method"`
+	// Path - путь запроса вместе со строкой запроса
+	Path string `json:"path"`
+}
+
+// RecordedResponse - записанный ответ системы начислений
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// LoadCassette читает кассету из файла
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("error decoding cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save атомарно записывает кассету в файл
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding cassette: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating cassette: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	return nil
+}
+
+// requestKey возвращает ключ, по которому запрос сопоставляется с записью
+func requestKey(method, path string) string {
+	return method + " " + path
+}
+
+// RecordingTransport выполняет запросы через next и дописывает каждую пару
+// запрос-ответ в кассету. Кассета накапливается в памяти и сохраняется в файл вызовом Close.
+type RecordingTransport struct {
+	next http.RoundTripper
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+	// recorded - число пар, записанных с последнего сохранения
+	recorded int
+}
+
+// NewRecordingTransport создает записывающий транспорт. Существующая кассета по пути path
+// дополняется, а не перезаписывается.
+func NewRecordingTransport(next http.RoundTripper, path string) (*RecordingTransport, error) {
+	t := &RecordingTransport{next: next, path: path}
+
+	cassette, err := LoadCassette(path)
+	switch {
+	case err == nil:
+		t.cassette = *cassette
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	return t, nil
+}
+
+// RoundTrip реализует http.RoundTripper
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response for cassette: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.RequestURI(),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     recordedHeader(resp.Header),
+			Body:       string(body),
+		},
+	}
+
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	t.recorded++
+	t.mu.Unlock()
+
+	return resp, nil
+}
+
+// Close сохраняет записанный обмен в файл кассеты. Если с последнего сохранения
+// ничего не записано, файл не изменяется.
+func (t *RecordingTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.recorded == 0 {
+		return nil
+	}
+	if err := t.cassette.Save(t.path); err != nil {
+		return err
+	}
+	t.recorded = 0
+	return nil
+}
+
+// recordedHeader оставляет заголовки ответа, влияющие на его обработку
+func recordedHeader(header http.Header) http.Header {
+	recorded := make(http.Header)
+	for _, name := range []string{"Content-Type", "Retry-After"} {
+		if v := header.Values(name); len(v) > 0 {
+			recorded[name] = v
+		}
+	}
+	if len(recorded) == 0 {
+		return nil
+	}
+	return recorded
+}
+
+// ReplayTransport отвечает на запросы из кассеты, не обращаясь к сети. Ответы на одинаковые
+// запросы выдаются в порядке записи, последний повторяется. Запрос без записи завершается ошибкой.
+type ReplayTransport struct {
+	mu        sync.Mutex
+	responses map[string][]RecordedResponse
+	cursors   map[string]int
+}
+
+// NewReplayTransport создает воспроизводящий транспорт по кассете
+func NewReplayTransport(cassette *Cassette) *ReplayTransport {
+	t := &ReplayTransport{
+		responses: make(map[string][]RecordedResponse),
+		cursors:   make(map[string]int),
+	}
+	for _, interaction := range cassette.Interactions {
+		key := requestKey(interaction.Request.Method, interaction.Request.Path)
+		t.responses[key] = append(t.responses[key], interaction.Response)
+	}
+	return t
+}
+
+// RoundTrip реализует http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	key := requestKey(req.Method, req.URL.RequestURI())
+
+	t.mu.Lock()
+	responses := t.responses[key]
+	if len(responses) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("no recorded interaction for %s", key)
+	}
+	cursor := t.cursors[key]
+	if cursor < len(responses)-1 {
+		t.cursors[key] = cursor + 1
+	}
+	recorded := responses[cursor]
+	t.mu.Unlock()
+
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
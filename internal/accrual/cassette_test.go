@@ -0,0 +1,142 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gophermart/internal/accrualstub"
+	"gophermart/internal/domain"
+)
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accrual.json")
+
+	stub, ts := accrualstub.NewTestServer(accrualstub.Scenario{})
+	stub.SetOrder("12345678903", accrualstub.Sequence(500, "REGISTERED", "PROCESSING"))
+	stub.SetOrder("4561261212345467", accrualstub.OrderScenario{Steps: []accrualstub.Step{
+		{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 42},
+	}})
+
+	recordClient, err := NewHTTPClient(TransportConfig{CassetteMode: CassetteRecord, CassettePath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder := NewServiceWithClient(recordClient, ts.URL)
+
+	var recorded []domain.OrderStatus
+	for i := 0; i < 3; i++ {
+		order, err := recorder.GetOrderAccrual(context.Background(), "12345678903")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		recorded = append(recorded, order.Status)
+	}
+	recorder.GetOrderAccrual(context.Background(), "4561261212345467")
+	ts.Close()
+
+	// Кассета пишется в файл при закрытии клиента, а не после каждого ответа
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected cassette to be saved on close, stat error = %v", err)
+	}
+	if err := CloseHTTPClient(recordClient); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	replayClient, err := NewHTTPClient(TransportConfig{CassetteMode: CassetteReplay, CassettePath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Адрес при воспроизведении не используется и может отличаться от записанного
+	replayer := NewServiceWithClient(replayClient, "http://accrual.invalid")
+
+	for i, want := range recorded {
+		order, err := replayer.GetOrderAccrual(context.Background(), "12345678903")
+		if err != nil {
+			t.Fatalf("replay %d: unexpected error: %v", i, err)
+		}
+		if order.Status != want {
+			t.Errorf("replay %d: expected status %s, got %s", i, want, order.Status)
+		}
+	}
+
+	// Последний записанный ответ повторяется
+	order, err := replayer.GetOrderAccrual(context.Background(), "12345678903")
+	if err != nil || order.Status != domain.StatusProcessed || order.Accrual != domain.Rubles(500) {
+		t.Errorf("expected repeated PROCESSED with accrual 500, got %+v, %v", order, err)
+	}
+
+	_, err = replayer.GetOrderAccrual(context.Background(), "4561261212345467")
+	var tooManyRequestsErr *domain.TooManyRequestsError
+	if !errors.As(err, &tooManyRequestsErr) || tooManyRequestsErr.RetryAfter != 42*time.Second {
+		t.Errorf("expected replayed 429 with retry after 42s, got %v", err)
+	}
+
+	if _, err := replayer.GetOrderAccrual(context.Background(), "79927398713"); err == nil {
+		t.Error("expected error for request missing from cassette")
+	}
+}
+
+func TestCassette_RecordAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accrual.json")
+
+	stub, ts := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer ts.Close()
+	stub.SetOrder("12345678903", accrualstub.Processed(100))
+
+	for i := 0; i < 2; i++ {
+		client, err := NewHTTPClient(TransportConfig{CassetteMode: CassetteRecord, CassettePath: path})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := NewServiceWithClient(client, ts.URL).GetOrderAccrual(context.Background(), "12345678903"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := CloseHTTPClient(client); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cassette.Interactions) != 2 {
+		t.Fatalf("expected 2 interactions, got %d", len(cassette.Interactions))
+	}
+	if got := cassette.Interactions[0].Request.Path; got != "/api/orders/12345678903" {
+		t.Errorf("expected request path without host, got %q", got)
+	}
+}
+
+func TestNewHTTPClient_CassetteConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TransportConfig
+	}{
+		{
+			name: "режим без файла",
+			cfg:  TransportConfig{CassetteMode: CassetteReplay},
+		},
+		{
+			name: "неизвестный режим",
+			cfg:  TransportConfig{CassetteMode: "rewind", CassettePath: "cassette.json"},
+		},
+		{
+			name: "воспроизведение несуществующей кассеты",
+			cfg:  TransportConfig{CassetteMode: CassetteReplay, CassettePath: filepath.Join(t.TempDir(), "missing.json")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPClient(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package accrual
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// healthCheckOrder - номер заказа для проверки доступности; система начислений
+// отвечает на него как на любой незарегистрированный заказ
+const healthCheckOrder = "0"
+
+// endpointMetrics хранит счетчики по адресам системы начислений. Карта не публикуется
+// в реестре expvar, чтобы вместе с ней не раскрывались cmdline и memstats.
+var endpointMetrics = new(expvar.Map).Init()
+
+// endpointMetricsMu защищает создание вложенных карт в endpointMetrics
+var endpointMetricsMu sync.Mutex
+
+// endpoint - один адрес системы начислений с признаком доступности и метриками
+type endpoint struct {
+	baseURL string
+	healthy atomic.Bool
+	metrics *expvar.Map
+	// healthyGauge - признак доступности в метриках: 1 или 0
+	healthyGauge *expvar.Int
+}
+
+// newEndpoint создает адрес, считая его доступным до первой ошибки
+func newEndpoint(baseURL string) *endpoint {
+	e := &endpoint{
+		baseURL:      baseURL,
+		metrics:      metricsFor(baseURL),
+		healthyGauge: new(expvar.Int),
+	}
+	e.metrics.Set("healthy", e.healthyGauge)
+	e.setHealthy(true)
+	return e
+}
+
+// EndpointMetrics возвращает счетчики по адресам системы начислений
+func EndpointMetrics() expvar.Var {
+	return endpointMetrics
+}
+
+// metricsFor возвращает карту метрик адреса, создавая её при первом обращении
+func metricsFor(baseURL string) *expvar.Map {
+	endpointMetricsMu.Lock()
+	defer endpointMetricsMu.Unlock()
+
+	if m, ok := endpointMetrics.Get(baseURL).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	endpointMetrics.Set(baseURL, m)
+	return m
+}
+
+// setHealthy меняет признак доступности и сообщает, изменился ли он
+func (e *endpoint) setHealthy(healthy bool) bool {
+	var v int64
+	if healthy {
+		v = 1
+	}
+	e.healthyGauge.Set(v)
+
+	return e.healthy.Swap(healthy) != healthy
+}
+
+// markFailed помечает адрес недоступным после ошибки запроса
+func (e *endpoint) markFailed(err error) {
+	e.metrics.Add("failures", 1)
+	if e.setHealthy(false) {
+		logger.Warn("Accrual endpoint marked unhealthy",
+			zap.String("endpoint", e.baseURL),
+			zap.Error(err))
+	}
+}
+
+// markHealthy помечает адрес доступным
+func (e *endpoint) markHealthy() {
+	if e.setHealthy(true) {
+		logger.Info("Accrual endpoint is healthy again", zap.String("endpoint", e.baseURL))
+	}
+}
+
+// candidates возвращает адреса в порядке попыток: сначала доступные в порядке
+// конфигурации, так что основной адрес предпочитается всегда, когда он доступен,
+// затем недоступные - на случай, если проверка ещё не заметила восстановления
+func (s *Service) candidates() []*endpoint {
+	ordered := make([]*endpoint, 0, len(s.endpoints))
+	for _, e := range s.endpoints {
+		if e.healthy.Load() {
+			ordered = append(ordered, e)
+		}
+	}
+	for _, e := range s.endpoints {
+		if !e.healthy.Load() {
+			ordered = append(ordered, e)
+		}
+	}
+	return ordered
+}
+
+// StartHealthChecks периодически проверяет все адреса через client до отмены ctx.
+// Восстановившийся основной адрес снова получает запросы после первой успешной проверки.
+// Проверки идут в обход транспорта основного клиента, например через NewProbeHTTPClient,
+// чтобы не попадать в кассету и не расходовать его соединения.
+func (s *Service) StartHealthChecks(ctx context.Context, client *http.Client, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkEndpoints(ctx, client)
+			}
+		}
+	}()
+}
+
+// checkEndpoints проверяет доступность каждого адреса
+func (s *Service) checkEndpoints(ctx context.Context, client *http.Client) {
+	for _, e := range s.endpoints {
+		e.metrics.Add("health_checks", 1)
+		if err := probe(ctx, client, e); err != nil {
+			e.markFailed(err)
+			continue
+		}
+		e.markHealthy()
+	}
+}
+
+// probe запрашивает заведомо незарегистрированный заказ: любой ответ, кроме 5xx,
+// означает, что адрес обслуживает запросы
+func probe(ctx context.Context, client *http.Client, e *endpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+"/api/orders/"+healthCheckOrder, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to do request: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrInjectedFault - ошибка, внесенная FaultInjector вместо обращения к системе начислений
+var ErrInjectedFault = errors.New("injected accrual fault")
+
+// FaultConfig задает сбои, вносимые в ответы системы начислений. Доли задаются от 0 до 1
+// и в сумме не превышают 1; для каждого запроса выбирается не больше одного сбоя.
+type FaultConfig struct {
+	// Latency - наименьшая добавочная задержка каждого запроса
+	Latency time.Duration
+	// LatencyJitter - наибольшая случайная добавка к Latency
+	LatencyJitter time.Duration
+	// ErrorRate - доля запросов, завершающихся ошибкой недоступности
+	ErrorRate float64
+	// TooManyRequestsRate - доля запросов, получающих ответ 429
+	TooManyRequestsRate float64
+	// MaxRetryAfter - наибольшее значение Retry-After для ответов 429, по умолчанию 60 секунд
+	MaxRetryAfter time.Duration
+	// MalformedRate - доля запросов с некорректным телом ответа
+	MalformedRate float64
+}
+
+// Enabled сообщает, вносит ли конфигурация хотя бы один сбой
+func (c FaultConfig) Enabled() bool {
+	return c.Latency > 0 || c.LatencyJitter > 0 ||
+		c.ErrorRate > 0 || c.TooManyRequestsRate > 0 || c.MalformedRate > 0
+}
+
+// Validate проверяет доли сбоев
+func (c FaultConfig) Validate() error {
+	for _, rate := range []float64{c.ErrorRate, c.TooManyRequestsRate, c.MalformedRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("accrual fault rates must be between 0 and 1")
+		}
+	}
+	if c.ErrorRate+c.TooManyRequestsRate+c.MalformedRate > 1 {
+		return fmt.Errorf("accrual fault rates must not add up to more than 1")
+	}
+	if c.Latency < 0 || c.LatencyJitter < 0 || c.MaxRetryAfter < 0 {
+		return fmt.Errorf("accrual fault durations must be positive")
+	}
+	return nil
+}
+
+// FaultInjector вносит задержки и сбои в ответы системы начислений. Предназначен
+// для проверки опроса, начислений и остановки сервиса при отказах в dev- и staging-окружениях.
+type FaultInjector struct {
+	next Client
+	cfg  FaultConfig
+	// random возвращает случайное число в [0, 1)
+	random func() float64
+}
+
+// NewFaultInjector создает обертку над next, вносящую сбои по cfg
+func NewFaultInjector(next Client, cfg FaultConfig) *FaultInjector {
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = time.Minute
+	}
+
+	return &FaultInjector{
+		next:   next,
+		cfg:    cfg,
+		random: rand.Float64,
+	}
+}
+
+// GetOrderAccrual выполняет запрос через next либо возвращает внесенный сбой
+func (f *FaultInjector) GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.Order, error) {
+	if err := f.delay(ctx); err != nil {
+		return nil, err
+	}
+
+	roll := f.random()
+	switch {
+	case roll < f.cfg.ErrorRate:
+		logger.Warn("Injected accrual error", zap.String("order", orderNumber))
+		return nil, fmt.Errorf("failed to do request: %w", ErrInjectedFault)
+
+	case roll < f.cfg.ErrorRate+f.cfg.TooManyRequestsRate:
+		seconds := 1 + int(f.random()*f.cfg.MaxRetryAfter.Seconds())
+		retryAfter := time.Duration(seconds) * time.Second
+		if retryAfter > f.cfg.MaxRetryAfter {
+			retryAfter = f.cfg.MaxRetryAfter
+		}
+		logger.Warn("Injected accrual 429",
+			zap.String("order", orderNumber),
+			zap.Duration("retry_after", retryAfter))
+		return nil, domain.NewTooManyRequestsError(retryAfter)
+
+	case roll < f.cfg.ErrorRate+f.cfg.TooManyRequestsRate+f.cfg.MalformedRate:
+		logger.Warn("Injected malformed accrual response", zap.String("order", orderNumber))
+		return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualMalformedBody, ErrInjectedFault.Error())
+	}
+
+	return f.next.GetOrderAccrual(ctx, orderNumber)
+}
+
+// delay выдерживает добавочную задержку, прерываясь при отмене ctx
+func (f *FaultInjector) delay(ctx context.Context) error {
+	latency := f.cfg.Latency
+	if f.cfg.LatencyJitter > 0 {
+		latency += time.Duration(f.random() * float64(f.cfg.LatencyJitter))
+	}
+	if latency <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
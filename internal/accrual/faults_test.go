@@ -0,0 +1,126 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+func TestFaultInjector(t *testing.T) {
+	next := clientFunc(func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+		return &domain.Order{Number: orderNumber, Status: domain.StatusProcessed, Accrual: domain.Rubles(100)}, nil
+	})
+	cfg := FaultConfig{
+		ErrorRate:           0.1,
+		TooManyRequestsRate: 0.2,
+		MalformedRate:       0.3,
+		MaxRetryAfter:       10 * time.Second,
+	}
+
+	tests := []struct {
+		name  string
+		rolls []float64
+		check func(t *testing.T, order *domain.Order, err error)
+	}{
+		{
+			name:  "ошибка недоступности",
+			rolls: []float64{0.05},
+			check: func(t *testing.T, order *domain.Order, err error) {
+				if !errors.Is(err, ErrInjectedFault) {
+					t.Errorf("expected injected fault, got %v", err)
+				}
+			},
+		},
+		{
+			name:  "ответ 429 со случайным Retry-After",
+			rolls: []float64{0.2, 0.45},
+			check: func(t *testing.T, order *domain.Order, err error) {
+				var tooManyRequestsErr *domain.TooManyRequestsError
+				if !errors.As(err, &tooManyRequestsErr) {
+					t.Fatalf("expected TooManyRequestsError, got %v", err)
+				}
+				if tooManyRequestsErr.RetryAfter != 5*time.Second {
+					t.Errorf("expected retry after 5s, got %v", tooManyRequestsErr.RetryAfter)
+				}
+			},
+		},
+		{
+			name:  "некорректное тело ответа",
+			rolls: []float64{0.5},
+			check: func(t *testing.T, order *domain.Order, err error) {
+				if !errors.Is(err, domain.ErrAccrualMalformedBody) || !errors.Is(err, domain.ErrInvalidAccrualResponse) {
+					t.Errorf("expected malformed body error, got %v", err)
+				}
+			},
+		},
+		{
+			name:  "запрос без сбоя",
+			rolls: []float64{0.7},
+			check: func(t *testing.T, order *domain.Order, err error) {
+				if err != nil || order == nil || order.Accrual != domain.Rubles(100) {
+					t.Errorf("expected response from next, got %+v, %v", order, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector := NewFaultInjector(next, cfg)
+			rolls := tt.rolls
+			injector.random = func() float64 {
+				roll := rolls[0]
+				rolls = rolls[1:]
+				return roll
+			}
+
+			order, err := injector.GetOrderAccrual(context.Background(), "12345678903")
+			tt.check(t, order, err)
+		})
+	}
+}
+
+func TestFaultInjector_LatencyRespectsContext(t *testing.T) {
+	next := clientFunc(func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+		t.Error("next must not be called after context cancellation")
+		return nil, nil
+	})
+	injector := NewFaultInjector(next, FaultConfig{Latency: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := injector.GetOrderAccrual(ctx, "12345678903")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected latency to be interrupted by context")
+	}
+}
+
+func TestFaultConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     FaultConfig
+		wantErr bool
+	}{
+		{name: "без сбоев", cfg: FaultConfig{}},
+		{name: "допустимые доли", cfg: FaultConfig{ErrorRate: 0.5, TooManyRequestsRate: 0.5}},
+		{name: "доля больше единицы", cfg: FaultConfig{ErrorRate: 1.5}, wantErr: true},
+		{name: "сумма долей больше единицы", cfg: FaultConfig{ErrorRate: 0.6, MalformedRate: 0.6}, wantErr: true},
+		{name: "отрицательная задержка", cfg: FaultConfig{Latency: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
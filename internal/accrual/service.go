@@ -3,6 +3,7 @@ package accrual
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -14,37 +15,96 @@ import (
 	"go.uber.org/zap"
 )
 
+// Service опрашивает систему начислений. Если адресов несколько, запросы идут на первый
+// доступный по порядку, а при сетевой ошибке или ответе 5xx - на следующий.
 type Service struct {
-	baseURL    string
+	endpoints  []*endpoint
 	httpClient *http.Client
 }
 
-func NewService(baseURL string) *Service {
+// NewService создает клиент системы начислений с транспортом по умолчанию.
+// Первый адрес считается основным, остальные - резервными.
+func NewService(baseURLs ...string) *Service {
+	return NewServiceWithClient(&http.Client{Timeout: defaultRequestTimeout}, baseURLs...)
+}
+
+// NewServiceWithClient создает клиент системы начислений, выполняющий запросы через httpClient,
+// например созданный NewHTTPClient
+func NewServiceWithClient(httpClient *http.Client, baseURLs ...string) *Service {
+	endpoints := make([]*endpoint, 0, len(baseURLs))
+	for _, baseURL := range baseURLs {
+		endpoints = append(endpoints, newEndpoint(baseURL))
+	}
+
 	return &Service{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		endpoints:  endpoints,
+		httpClient: httpClient,
 	}
 }
 
 type accrualResponse struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual,omitempty"`
+	Order  string `json:"order"`
+	Status string `json:"status"`
+	// Accrual читается как десятичная запись, чтобы округлить её до копейки без потери точности
+	Accrual *json.Number `json:"accrual,omitempty"`
 }
 
+// statusMapping сопоставляет статусы системы начислений статусам заказов
+var statusMapping = map[string]domain.OrderStatus{
+	// Заказ зарегистрирован, но расчёт ещё не начат
+	"REGISTERED": domain.StatusNew,
+	"PROCESSING": domain.StatusProcessing,
+	"INVALID":    domain.StatusInvalid,
+	"PROCESSED":  domain.StatusProcessed,
+}
+
+// endpointError - ошибка адреса, после которой запрос повторяется на следующем адресе
+type endpointError struct {
+	err error
+}
+
+func (e *endpointError) Error() string { return e.err.Error() }
+
+func (e *endpointError) Unwrap() error { return e.err }
+
 func (s *Service) GetOrderAccrual(ctx context.Context, orderNumber string) (*domain.Order, error) {
-	url := fmt.Sprintf("%s/api/orders/%s", s.baseURL, orderNumber)
+	var lastErr error
+	for _, e := range s.candidates() {
+		order, err := s.getOrderAccrual(ctx, e, orderNumber)
+
+		var failoverErr *endpointError
+		if !errors.As(err, &failoverErr) {
+			e.markHealthy()
+			return order, err
+		}
+
+		lastErr = failoverErr.err
+		if ctx.Err() != nil {
+			return nil, lastErr
+		}
+		e.markFailed(lastErr)
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("no accrual endpoints configured")
+	}
+	return nil, lastErr
+}
+
+// getOrderAccrual выполняет запрос к одному адресу. Сетевые ошибки и ответы 5xx
+// возвращаются как *endpointError.
+func (s *Service) getOrderAccrual(ctx context.Context, e *endpoint, orderNumber string) (*domain.Order, error) {
+	url := fmt.Sprintf("%s/api/orders/%s", e.baseURL, orderNumber)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	e.metrics.Add("requests", 1)
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to do request: %w", err)
+		return nil, &endpointError{err: fmt.Errorf("failed to do request to %s: %w", e.baseURL, err)}
 	}
 	defer resp.Body.Close()
 
@@ -52,19 +112,23 @@ func (s *Service) GetOrderAccrual(ctx context.Context, orderNumber string) (*dom
 	case http.StatusOK:
 		var accrualResp accrualResponse
 		if err := json.NewDecoder(resp.Body).Decode(&accrualResp); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
+			return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualMalformedBody, err.Error())
 		}
 
-		order := &domain.Order{
-			Number:  accrualResp.Order,
-			Status:  domain.OrderStatus(accrualResp.Status),
-			Accrual: accrualResp.Accrual,
+		order, err := mapAccrualResponse(orderNumber, accrualResp)
+		if err != nil {
+			logger.Error("Rejected accrual response",
+				zap.String("order", orderNumber),
+				zap.String("endpoint", e.baseURL),
+				zap.Error(err))
+			return nil, err
 		}
 
 		logger.Info("Got accrual response",
 			zap.String("order", orderNumber),
+			zap.String("endpoint", e.baseURL),
 			zap.String("status", string(order.Status)),
-			zap.Float64("accrual", order.Accrual))
+			zap.Stringer("accrual", order.Accrual))
 
 		return order, nil
 
@@ -82,18 +146,64 @@ func (s *Service) GetOrderAccrual(ctx context.Context, orderNumber string) (*dom
 		retryAfter := time.Duration(retryAfterSec) * time.Second
 		logger.Warn("Too many requests to accrual service",
 			zap.String("order", orderNumber),
+			zap.String("endpoint", e.baseURL),
 			zap.Duration("retry_after", retryAfter))
 
+		e.metrics.Add("throttled", 1)
 		return nil, domain.NewTooManyRequestsError(retryAfter)
 
 	case http.StatusNoContent:
-		logger.Info("Order not registered in accrual system", zap.String("order", orderNumber))
+		logger.Info("Order not registered in accrual system",
+			zap.String("order", orderNumber),
+			zap.String("endpoint", e.baseURL))
 		return nil, nil
 
 	default:
 		logger.Error("Unexpected response from accrual service",
 			zap.String("order", orderNumber),
+			zap.String("endpoint", e.baseURL),
 			zap.Int("status", resp.StatusCode))
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err := fmt.Errorf("unexpected status code from %s: %d", e.baseURL, resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return nil, &endpointError{err: err}
+		}
+		return nil, err
+	}
+}
+
+// mapAccrualResponse проверяет ответ системы начислений и переводит его в заказ
+func mapAccrualResponse(orderNumber string, resp accrualResponse) (*domain.Order, error) {
+	if resp.Order != orderNumber {
+		return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualOrderMismatch,
+			fmt.Sprintf("got %q", resp.Order))
+	}
+
+	status, ok := statusMapping[resp.Status]
+	if !ok {
+		return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualUnknownStatus,
+			fmt.Sprintf("got %q", resp.Status))
 	}
+
+	order := &domain.Order{
+		Number: orderNumber,
+		Status: status,
+	}
+
+	if resp.Accrual == nil {
+		return order, nil
+	}
+
+	// Система начислений может прислать доли копейки: округляем половиной от нуля
+	accrual, err := domain.RoundMoney(resp.Accrual.String())
+	if err != nil || accrual < 0 {
+		return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualInvalidAmount,
+			fmt.Sprintf("got %s", resp.Accrual))
+	}
+	if status != domain.StatusProcessed {
+		return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualUnexpectedAccrual,
+			fmt.Sprintf("got %v with status %s", accrual, resp.Status))
+	}
+
+	order.Accrual = accrual
+	return order, nil
 }
@@ -0,0 +1,244 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gophermart/internal/accrualstub"
+	"gophermart/internal/domain"
+)
+
+func TestService_GetOrderAccrual(t *testing.T) {
+	stub, ts := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer ts.Close()
+
+	service := NewService(ts.URL)
+
+	t.Run("processed", func(t *testing.T) {
+		stub.SetOrder("12345678903", accrualstub.Processed(500))
+
+		order, err := service.GetOrderAccrual(context.Background(), "12345678903")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if order.Status != domain.StatusProcessed || order.Accrual != domain.Rubles(500) {
+			t.Errorf("expected PROCESSED with accrual 500, got %+v", order)
+		}
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		order, err := service.GetOrderAccrual(context.Background(), "79927398713")
+		if err != nil || order != nil {
+			t.Errorf("expected nil order and error, got %+v, %v", order, err)
+		}
+	})
+
+	t.Run("too many requests", func(t *testing.T) {
+		stub.SetOrder("4561261212345467", accrualstub.OrderScenario{Steps: []accrualstub.Step{
+			{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 42},
+		}})
+
+		_, err := service.GetOrderAccrual(context.Background(), "4561261212345467")
+		var tooManyRequestsErr *domain.TooManyRequestsError
+		if !errors.As(err, &tooManyRequestsErr) {
+			t.Fatalf("expected TooManyRequestsError, got %v", err)
+		}
+		if tooManyRequestsErr.RetryAfter != 42*time.Second {
+			t.Errorf("expected retry after 42s, got %v", tooManyRequestsErr.RetryAfter)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		stub.SetOrder("49927398716", accrualstub.OrderScenario{Steps: []accrualstub.Step{
+			{HTTPStatus: http.StatusInternalServerError},
+		}})
+
+		if _, err := service.GetOrderAccrual(context.Background(), "49927398716"); err == nil {
+			t.Error("expected error on 500")
+		}
+	})
+}
+
+func TestService_GetOrderAccrual_Validation(t *testing.T) {
+	stub, ts := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer ts.Close()
+
+	service := NewService(ts.URL)
+	negative := -1.0
+	accrual := 10.0
+
+	tests := []struct {
+		name        string
+		step        accrualstub.Step
+		wantStatus  domain.OrderStatus
+		wantAccrual domain.Money
+		wantReason  error
+	}{
+		{
+			name:       "REGISTERED сопоставляется с NEW",
+			step:       accrualstub.Step{Status: "REGISTERED"},
+			wantStatus: domain.StatusNew,
+		},
+		{
+			name:       "PROCESSED без начисления",
+			step:       accrualstub.Step{Status: "PROCESSED"},
+			wantStatus: domain.StatusProcessed,
+		},
+		{
+			name:       "неизвестный статус",
+			step:       accrualstub.Step{Status: "DONE"},
+			wantReason: domain.ErrAccrualUnknownStatus,
+		},
+		{
+			name:       "номер заказа не совпадает",
+			step:       accrualstub.Step{Body: `{"order":"79927398713","status":"PROCESSED","accrual":10}`},
+			wantReason: domain.ErrAccrualOrderMismatch,
+		},
+		{
+			name:       "отрицательное начисление",
+			step:       accrualstub.Step{Status: "PROCESSED", Accrual: &negative},
+			wantReason: domain.ErrAccrualInvalidAmount,
+		},
+		{
+			name:       "начисление для незавершенного расчёта",
+			step:       accrualstub.Step{Status: "PROCESSING", Accrual: &accrual},
+			wantReason: domain.ErrAccrualUnexpectedAccrual,
+		},
+		{
+			name:        "доли копейки округляются половиной вверх",
+			step:        accrualstub.Step{Body: `{"order":"12345678903","status":"PROCESSED","accrual":729.985}`},
+			wantStatus:  domain.StatusProcessed,
+			wantAccrual: 72999,
+		},
+		{
+			name:        "доли копейки меньше половины отбрасываются",
+			step:        accrualstub.Step{Body: `{"order":"12345678903","status":"PROCESSED","accrual":0.1049}`},
+			wantStatus:  domain.StatusProcessed,
+			wantAccrual: 10,
+		},
+		{
+			name:        "сумма, неточная в float64, читается точно",
+			step:        accrualstub.Step{Body: `{"order":"12345678903","status":"PROCESSED","accrual":1.005}`},
+			wantStatus:  domain.StatusProcessed,
+			wantAccrual: 101,
+		},
+		{
+			name:       "начисление вне допустимого диапазона",
+			step:       accrualstub.Step{Body: `{"order":"12345678903","status":"PROCESSED","accrual":1e400}`},
+			wantReason: domain.ErrAccrualInvalidAmount,
+		},
+		{
+			name:       "повреждённое тело ответа",
+			step:       accrualstub.Step{Body: `{"order":`},
+			wantReason: domain.ErrAccrualMalformedBody,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub.SetOrder("12345678903", accrualstub.OrderScenario{Steps: []accrualstub.Step{tt.step}})
+
+			order, err := service.GetOrderAccrual(context.Background(), "12345678903")
+			if tt.wantReason != nil {
+				if !errors.Is(err, tt.wantReason) || !errors.Is(err, domain.ErrInvalidAccrualResponse) {
+					t.Fatalf("expected %v, got %v", tt.wantReason, err)
+				}
+				var responseErr *domain.AccrualResponseError
+				if !errors.As(err, &responseErr) || responseErr.Order != "12345678903" {
+					t.Errorf("expected AccrualResponseError for order, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if order.Status != tt.wantStatus {
+				t.Errorf("expected status %s, got %s", tt.wantStatus, order.Status)
+			}
+			if order.Accrual != tt.wantAccrual {
+				t.Errorf("expected accrual %s, got %s", tt.wantAccrual, order.Accrual)
+			}
+		})
+	}
+}
+
+func TestService_Failover(t *testing.T) {
+	primary, primaryTS := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer primaryTS.Close()
+	dr, drTS := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer drTS.Close()
+
+	service := NewService(primaryTS.URL, drTS.URL)
+	unavailable := accrualstub.OrderScenario{Steps: []accrualstub.Step{
+		{HTTPStatus: http.StatusServiceUnavailable},
+	}}
+
+	primary.SetOrder("12345678903", unavailable)
+	primary.SetOrder(healthCheckOrder, unavailable)
+	dr.SetOrder("12345678903", accrualstub.Processed(500))
+
+	// Основной адрес отвечает 503 - запрос обслуживает резервный
+	order, err := service.GetOrderAccrual(context.Background(), "12345678903")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != domain.StatusProcessed || order.Accrual != domain.Rubles(500) {
+		t.Errorf("expected PROCESSED with accrual 500 from dr, got %+v", order)
+	}
+	if service.endpoints[0].healthy.Load() {
+		t.Error("expected primary to be marked unhealthy")
+	}
+
+	// Пока основной недоступен, запросы сразу идут на резервный
+	primaryCalls := primary.Calls("12345678903")
+	if _, err := service.GetOrderAccrual(context.Background(), "12345678903"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := primary.Calls("12345678903"); calls != primaryCalls {
+		t.Errorf("expected unhealthy primary to be skipped, got %d calls", calls-primaryCalls)
+	}
+
+	// Проверка видит восстановление основного, и запросы возвращаются на него
+	primary.SetOrder("12345678903", accrualstub.Processed(700))
+	primary.SetOrder(healthCheckOrder, accrualstub.OrderScenario{Steps: []accrualstub.Step{
+		{HTTPStatus: http.StatusNoContent},
+	}})
+	service.checkEndpoints(context.Background(), &http.Client{})
+
+	order, err = service.GetOrderAccrual(context.Background(), "12345678903")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Accrual != domain.Rubles(700) {
+		t.Errorf("expected response from recovered primary, got %+v", order)
+	}
+}
+
+func TestService_FailoverNotOnClientErrors(t *testing.T) {
+	primary, primaryTS := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer primaryTS.Close()
+	dr, drTS := accrualstub.NewTestServer(accrualstub.Scenario{})
+	defer drTS.Close()
+
+	service := NewService(primaryTS.URL, drTS.URL)
+	primary.SetOrder("4561261212345467", accrualstub.OrderScenario{Steps: []accrualstub.Step{
+		{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 5},
+	}})
+
+	// 429 относится к лимиту клиента, а не к доступности адреса
+	_, err := service.GetOrderAccrual(context.Background(), "4561261212345467")
+	var tooManyRequestsErr *domain.TooManyRequestsError
+	if !errors.As(err, &tooManyRequestsErr) {
+		t.Fatalf("expected TooManyRequestsError, got %v", err)
+	}
+	if calls := dr.Calls("4561261212345467"); calls != 0 {
+		t.Errorf("expected no requests to dr, got %d", calls)
+	}
+	if !service.endpoints[0].healthy.Load() {
+		t.Error("expected primary to stay healthy")
+	}
+}
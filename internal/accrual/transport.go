@@ -0,0 +1,198 @@
+package accrual
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRequestTimeout - таймаут запроса к системе начислений по умолчанию
+	defaultRequestTimeout = 10 * time.Second
+	// probeTimeout - таймаут проверки доступности адреса
+	probeTimeout = 5 * time.Second
+)
+
+// TransportConfig содержит настройки HTTP-клиента системы начислений
+type TransportConfig struct {
+	// CAFile - PEM-файл с сертификатами центров, которым доверяет клиент;
+	// пустое значение - системные корневые сертификаты
+	CAFile string
+	// CertFile и KeyFile - клиентский сертификат и ключ для взаимной аутентификации TLS
+	CertFile string
+	KeyFile  string
+	// AuthHeader - заголовок, добавляемый к каждому запросу, вида "Authorization: Bearer token"
+	AuthHeader string
+	// RequestTimeout - общий таймаут одного запроса
+	RequestTimeout time.Duration
+	// MaxIdleConns - наибольшее число простаивающих соединений со всеми адресами
+	MaxIdleConns int
+	// MaxIdleConnsPerHost - наибольшее число простаивающих соединений с одним адресом
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost - наибольшее число соединений с одним адресом, 0 - без ограничения
+	MaxConnsPerHost int
+	// IdleConnTimeout - время, после которого простаивающее соединение закрывается
+	IdleConnTimeout time.Duration
+	// CassetteMode включает запись обмена в кассету или воспроизведение из неё
+	CassetteMode CassetteMode
+	// CassettePath - файл кассеты
+	CassettePath string
+}
+
+// NewHTTPClient создает HTTP-клиент системы начислений по настройкам cfg
+func NewHTTPClient(cfg TransportConfig) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+
+	roundTripper, err := withAuthHeader(transport, cfg.AuthHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CassetteMode != CassetteOff && cfg.CassettePath == "" {
+		return nil, fmt.Errorf("accrual cassette path is required in %s mode", cfg.CassetteMode)
+	}
+	switch cfg.CassetteMode {
+	case CassetteOff:
+	case CassetteRecord:
+		if roundTripper, err = NewRecordingTransport(roundTripper, cfg.CassettePath); err != nil {
+			return nil, err
+		}
+	case CassetteReplay:
+		cassette, err := LoadCassette(cfg.CassettePath)
+		if err != nil {
+			return nil, err
+		}
+		roundTripper = NewReplayTransport(cassette)
+	default:
+		return nil, fmt.Errorf("unknown accrual cassette mode %q", cfg.CassetteMode)
+	}
+
+	timeout := cfg.RequestTimeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   timeout,
+	}, nil
+}
+
+// CloseHTTPClient завершает работу клиента, созданного NewHTTPClient: в режиме записи
+// сохраняет кассету и закрывает простаивающие соединения
+func CloseHTTPClient(client *http.Client) error {
+	defer client.CloseIdleConnections()
+
+	if recorder, ok := client.Transport.(*RecordingTransport); ok {
+		return recorder.Close()
+	}
+	return nil
+}
+
+// NewProbeHTTPClient создает HTTP-клиент для проверок доступности адресов. Он использует
+// те же настройки TLS и заголовок авторизации, что и NewHTTPClient, но не делит с основным
+// клиентом пул соединений и не пишет обмен в кассету.
+func NewProbeHTTPClient(cfg TransportConfig) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	roundTripper, err := withAuthHeader(transport, cfg.AuthHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   probeTimeout,
+	}, nil
+}
+
+// withAuthHeader оборачивает transport так, чтобы к каждому запросу добавлялся заголовок
+// header вида "Name: value"; пустой header оставляет transport без изменений
+func withAuthHeader(transport http.RoundTripper, header string) (http.RoundTripper, error) {
+	if header == "" {
+		return transport, nil
+	}
+
+	name, value, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("invalid accrual auth header: expected \"Name: value\"")
+	}
+	return &headerTransport{
+		next:  transport,
+		name:  name,
+		value: strings.TrimSpace(value),
+	}, nil
+}
+
+// newTLSConfig загружает доверенные центры и клиентский сертификат
+func newTLSConfig(cfg TransportConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading accrual CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in accrual CA bundle %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("accrual client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading accrual client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// headerTransport добавляет к каждому запросу постоянный заголовок авторизации
+type headerTransport struct {
+	next  http.RoundTripper
+	name  string
+	value string
+}
+
+// RoundTrip реализует http.RoundTripper. Исходный запрос не изменяется.
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.name, t.value)
+	return t.next.RoundTrip(req)
+}
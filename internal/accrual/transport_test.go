@@ -0,0 +1,300 @@
+package accrual
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// testPKI - центр сертификации и выпущенные им сертификаты сервера и клиента
+type testPKI struct {
+	caPool     *x509.CertPool
+	caFile     string
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test accrual CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, ips []net.IP) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "gophermart"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  ips,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	pki := &testPKI{
+		caPool:     x509.NewCertPool(),
+		caFile:     filepath.Join(dir, "ca.pem"),
+		clientCert: filepath.Join(dir, "client.pem"),
+		clientKey:  filepath.Join(dir, "client-key.pem"),
+	}
+	pki.caPool.AddCert(caCert)
+
+	serverCertPEM, serverKeyPEM := issue(2, x509.ExtKeyUsageServerAuth, []net.IP{net.ParseIP("127.0.0.1")})
+	if pki.serverCert, err = tls.X509KeyPair(serverCertPEM, serverKeyPEM); err != nil {
+		t.Fatal(err)
+	}
+
+	clientCertPEM, clientKeyPEM := issue(3, x509.ExtKeyUsageClientAuth, nil)
+	files := map[string][]byte{
+		pki.caFile:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pki.clientCert: clientCertPEM,
+		pki.clientKey:  clientKeyPEM,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return pki
+}
+
+// newMTLSServer запускает TLS-сервер, требующий клиентский сертификат и токен
+func newMTLSServer(t *testing.T, pki *testPKI, token string) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientCAs:    pki.caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestNewHTTPClient_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	ts := newMTLSServer(t, pki, "secret")
+
+	tests := []struct {
+		name    string
+		cfg     TransportConfig
+		wantErr bool
+	}{
+		{
+			name: "сертификат клиента и токен",
+			cfg: TransportConfig{
+				CAFile:     pki.caFile,
+				CertFile:   pki.clientCert,
+				KeyFile:    pki.clientKey,
+				AuthHeader: "Authorization: Bearer secret",
+			},
+		},
+		{
+			name: "без сертификата клиента",
+			cfg: TransportConfig{
+				CAFile:     pki.caFile,
+				AuthHeader: "Authorization: Bearer secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "без доверенного центра",
+			cfg: TransportConfig{
+				CertFile:   pki.clientCert,
+				KeyFile:    pki.clientKey,
+				AuthHeader: "Authorization: Bearer secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "неверный токен",
+			cfg: TransportConfig{
+				CAFile:     pki.caFile,
+				CertFile:   pki.clientCert,
+				KeyFile:    pki.clientKey,
+				AuthHeader: "Authorization: Bearer wrong",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			order, err := NewServiceWithClient(client, ts.URL).GetOrderAccrual(context.Background(), "12345678903")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", order)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if order.Status != domain.StatusProcessed || order.Accrual != domain.Rubles(500) {
+				t.Errorf("expected PROCESSED with accrual 500, got %+v", order)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClient_Config(t *testing.T) {
+	pki := newTestPKI(t)
+
+	tests := []struct {
+		name    string
+		cfg     TransportConfig
+		wantErr bool
+	}{
+		{
+			name: "настройки пула соединений",
+			cfg: TransportConfig{
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 4,
+				MaxConnsPerHost:     8,
+				IdleConnTimeout:     time.Minute,
+				RequestTimeout:      time.Second,
+			},
+		},
+		{
+			name:    "сертификат без ключа",
+			cfg:     TransportConfig{CertFile: pki.clientCert},
+			wantErr: true,
+		},
+		{
+			name:    "несуществующий файл центров",
+			cfg:     TransportConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "файл центров без сертификатов",
+			cfg:     TransportConfig{CAFile: pki.clientKey},
+			wantErr: true,
+		},
+		{
+			name:    "заголовок без имени",
+			cfg:     TransportConfig{AuthHeader: "Bearer secret"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			transport := client.Transport.(*http.Transport)
+			if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 4 ||
+				transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != time.Minute {
+				t.Errorf("pool settings not applied: %+v", transport)
+			}
+			if client.Timeout != time.Second {
+				t.Errorf("expected timeout 1s, got %v", client.Timeout)
+			}
+		})
+	}
+}
+
+func TestNewProbeHTTPClient(t *testing.T) {
+	var gotAuth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	client, err := NewProbeHTTPClient(TransportConfig{
+		AuthHeader:   "Authorization: Bearer secret",
+		CassetteMode: CassetteRecord,
+		CassettePath: cassettePath,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Проверка доступности несет заголовок авторизации, но не попадает в кассету
+	if err := probe(context.Background(), client, newEndpoint(ts.URL)); err != nil {
+		t.Fatalf("unexpected probe error: %v", err)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("expected auth header on probe, got %q", gotAuth)
+	}
+	if _, err := os.Stat(cassettePath); !os.IsNotExist(err) {
+		t.Errorf("expected probe not to be recorded, stat error = %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package accrualstub
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Scenario описывает поведение заглушки для всех заказов
+type Scenario struct {
+	// Default применяется к заказам, для которых нет отдельного сценария
+	Default *OrderScenario `json:"default,omitempty"`
+	// Orders содержит сценарии по номерам заказов
+	Orders map[string]OrderScenario `json:"orders,omitempty"`
+}
+
+// OrderScenario описывает последовательность ответов на запросы по одному заказу.
+// Каждый запрос переходит к следующему шагу, последний шаг повторяется.
+type OrderScenario struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step описывает один ответ заглушки
+type Step struct {
+	// HTTPStatus - код ответа, по умолчанию 200
+	HTTPStatus int `json:"http_status,omitempty"`
+	// Status - статус расчёта в теле ответа 200
+	Status string `json:"status,omitempty"`
+	// Accrual - начисление в теле ответа 200; при отсутствии поле не передается
+	Accrual *float64 `json:"accrual,omitempty"`
+	// RetryAfter - значение заголовка Retry-After в секундах для ответа 429
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Latency - задержка перед ответом
+	Latency Duration `json:"latency,omitempty"`
+	// Body - произвольное тело ответа вместо сформированного по полям шага
+	Body string `json:"body,omitempty"`
+}
+
+// Duration - длительность, записываемая в JSON строкой вида "150ms"
+type Duration time.Duration
+
+// UnmarshalJSON разбирает длительность из строки или числа наносекунд
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON записывает длительность строкой
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadScenario читает сценарий из JSON-файла
+func LoadScenario(path string) (Scenario, error) {
+	var scenario Scenario
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scenario, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return scenario, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+
+	return scenario, nil
+}
+
+// Processed возвращает сценарий, в котором заказ сразу рассчитан с начислением accrual
+func Processed(accrual float64) OrderScenario {
+	return OrderScenario{Steps: []Step{{Status: "PROCESSED", Accrual: &accrual}}}
+}
+
+// Sequence возвращает сценарий, проходящий по статусам и завершающийся начислением accrual
+func Sequence(accrual float64, statuses ...string) OrderScenario {
+	steps := make([]Step, 0, len(statuses)+1)
+	for _, status := range statuses {
+		steps = append(steps, Step{Status: status})
+	}
+	steps = append(steps, Step{Status: "PROCESSED", Accrual: &accrual})
+	return OrderScenario{Steps: steps}
+}
@@ -0,0 +1,229 @@
+// Package accrualstub реализует заглушку системы расчёта начислений
+// с протоколом GET /api/orders/{number} и управляемым поведением по заказам.
+package accrualstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Server - заглушка системы начислений. Реализует http.Handler, поэтому может
+// использоваться как отдельный сервер или внутри httptest.Server.
+type Server struct {
+	mu       sync.Mutex
+	scenario Scenario
+	cursors  map[string]int
+	calls    map[string]int
+	router   chi.Router
+}
+
+// NewServer создает заглушку с начальным сценарием
+func NewServer(scenario Scenario) *Server {
+	s := &Server{
+		cursors: make(map[string]int),
+		calls:   make(map[string]int),
+	}
+	s.load(scenario)
+
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", s.getOrder)
+
+	// Управляющее API
+	r.Put("/control/scenario", s.putScenario)
+	r.Put("/control/orders/{number}", s.putOrder)
+	r.Delete("/control/orders/{number}", s.deleteOrder)
+	r.Get("/control/orders/{number}/calls", s.getCalls)
+	r.Post("/control/reset", s.reset)
+	s.router = r
+
+	return s
+}
+
+// NewTestServer запускает заглушку на локальном httptest.Server.
+// Вызывающий отвечает за закрытие возвращенного сервера.
+func NewTestServer(scenario Scenario) (*Server, *httptest.Server) {
+	s := NewServer(scenario)
+	return s, httptest.NewServer(s)
+}
+
+// ServeHTTP реализует http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// SetOrder задает сценарий для заказа и сбрасывает его прогресс
+func (s *Server) SetOrder(number string, scenario OrderScenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scenario.Orders == nil {
+		s.scenario.Orders = make(map[string]OrderScenario)
+	}
+	s.scenario.Orders[number] = scenario
+	delete(s.cursors, number)
+}
+
+// DeleteOrder удаляет сценарий заказа, после чего к нему применяется сценарий по умолчанию
+func (s *Server) DeleteOrder(number string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.scenario.Orders, number)
+	delete(s.cursors, number)
+}
+
+// Calls возвращает число запросов по заказу
+func (s *Server) Calls(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[number]
+}
+
+// Reset заменяет сценарий и сбрасывает прогресс и счетчики
+func (s *Server) Reset(scenario Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load(scenario)
+}
+
+// load устанавливает сценарий; вызывается под мьютексом или при создании
+func (s *Server) load(scenario Scenario) {
+	if scenario.Orders == nil {
+		scenario.Orders = make(map[string]OrderScenario)
+	}
+	s.scenario = scenario
+	s.cursors = make(map[string]int)
+	s.calls = make(map[string]int)
+}
+
+// nextStep возвращает очередной шаг сценария заказа
+func (s *Server) nextStep(number string) (Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[number]++
+
+	scenario, ok := s.scenario.Orders[number]
+	if !ok {
+		if s.scenario.Default == nil {
+			return Step{}, false
+		}
+		scenario = *s.scenario.Default
+	}
+	if len(scenario.Steps) == 0 {
+		return Step{}, false
+	}
+
+	i := s.cursors[number]
+	if i >= len(scenario.Steps) {
+		i = len(scenario.Steps) - 1
+	}
+	s.cursors[number] = i + 1
+
+	return scenario.Steps[i], true
+}
+
+// orderResponse повторяет формат ответа системы начислений
+type orderResponse struct {
+	Order   string   `json:"order"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+// getOrder отвечает по протоколу GET /api/orders/{number}
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	step, ok := s.nextStep(number)
+	if !ok {
+		// Заказ без сценария не зарегистрирован в системе расчёта
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if step.Latency > 0 {
+		select {
+		case <-time.After(time.Duration(step.Latency)):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	code := step.HTTPStatus
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	if step.Body != "" {
+		w.WriteHeader(code)
+		w.Write([]byte(step.Body))
+		return
+	}
+
+	switch code {
+	case http.StatusOK:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orderResponse{
+			Order:   number,
+			Status:  step.Status,
+			Accrual: step.Accrual,
+		})
+	case http.StatusTooManyRequests:
+		w.Header().Set("Content-Type", "text/plain")
+		if step.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(step.RetryAfter))
+		}
+		w.WriteHeader(code)
+		w.Write([]byte("No more than N requests per minute allowed"))
+	default:
+		w.WriteHeader(code)
+	}
+}
+
+// putScenario заменяет весь сценарий
+func (s *Server) putScenario(w http.ResponseWriter, r *http.Request) {
+	var scenario Scenario
+	if err := json.NewDecoder(r.Body).Decode(&scenario); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Reset(scenario)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// putOrder задает сценарий одного заказа
+func (s *Server) putOrder(w http.ResponseWriter, r *http.Request) {
+	var scenario OrderScenario
+	if err := json.NewDecoder(r.Body).Decode(&scenario); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.SetOrder(chi.URLParam(r, "number"), scenario)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteOrder удаляет сценарий заказа
+func (s *Server) deleteOrder(w http.ResponseWriter, r *http.Request) {
+	s.DeleteOrder(chi.URLParam(r, "number"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getCalls возвращает число запросов по заказу
+func (s *Server) getCalls(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"calls": s.Calls(chi.URLParam(r, "number")),
+	})
+}
+
+// reset сбрасывает сценарий, прогресс и счетчики
+func (s *Server) reset(w http.ResponseWriter, r *http.Request) {
+	s.Reset(Scenario{})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package accrualstub
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_Steps(t *testing.T) {
+	accrual := 500.0
+	stub, ts := NewTestServer(Scenario{
+		Orders: map[string]OrderScenario{
+			"12345678903": {Steps: []Step{
+				{HTTPStatus: http.StatusTooManyRequests, RetryAfter: 7},
+				{Status: "PROCESSING", Latency: Duration(10 * time.Millisecond)},
+				{Status: "PROCESSED", Accrual: &accrual},
+			}},
+		},
+	})
+	defer ts.Close()
+
+	get := func() *http.Response {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/orders/12345678903")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := get()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "7" {
+		t.Errorf("expected 429 with Retry-After 7, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	resp = get()
+	var body orderResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body.Status != "PROCESSING" || body.Accrual != nil {
+		t.Errorf("expected PROCESSING without accrual, got %+v", body)
+	}
+
+	// Последний шаг повторяется
+	for i := 0; i < 2; i++ {
+		resp = get()
+		body = orderResponse{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if body.Order != "12345678903" || body.Status != "PROCESSED" || body.Accrual == nil || *body.Accrual != 500 {
+			t.Errorf("expected PROCESSED with accrual 500, got %+v", body)
+		}
+	}
+
+	if calls := stub.Calls("12345678903"); calls != 4 {
+		t.Errorf("expected 4 calls, got %d", calls)
+	}
+}
+
+func TestServer_UnknownOrder(t *testing.T) {
+	stub := NewServer(Scenario{})
+
+	w := httptest.NewRecorder()
+	stub.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/1", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestServer_ControlAPI(t *testing.T) {
+	stub := NewServer(Scenario{})
+
+	scenario := `{"steps":[{"http_status":500},{"status":"INVALID"}]}`
+	w := httptest.NewRecorder()
+	stub.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/control/orders/42", bytes.NewBufferString(scenario)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	stub.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/42", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	stub.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/control/reset", nil))
+	if stub.Calls("42") != 0 {
+		t.Errorf("expected calls to be reset")
+	}
+
+	w = httptest.NewRecorder()
+	stub.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/42", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d after reset, got %d", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario("../../cmd/accrual-stub/scenario.example.json")
+	if err != nil {
+		t.Fatalf("LoadScenario() error = %v", err)
+	}
+
+	if scenario.Default == nil || len(scenario.Default.Steps) != 3 {
+		t.Fatalf("expected default scenario with 3 steps, got %+v", scenario.Default)
+	}
+	if scenario.Default.Steps[1].Latency != Duration(200*time.Millisecond) {
+		t.Errorf("expected latency 200ms, got %v", time.Duration(scenario.Default.Steps[1].Latency))
+	}
+}
@@ -0,0 +1,135 @@
+package accrualsystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler реализует HTTP-протокол системы начислений поверх System
+type Handler struct {
+	system *System
+	router chi.Router
+
+	// Ограничение числа запросов информации о заказах в минуту, 0 - без ограничения
+	requestsPerMinute int
+	mu                sync.Mutex
+	windowStart       time.Time
+	windowCount       int
+}
+
+// NewHandler создает HTTP-обработчик; requestsPerMinute ограничивает GET /api/orders/{number}
+func NewHandler(system *System, requestsPerMinute int) *Handler {
+	h := &Handler{
+		system:            system,
+		requestsPerMinute: requestsPerMinute,
+	}
+
+	r := chi.NewRouter()
+	r.Post("/api/orders", h.registerOrder)
+	r.Post("/api/goods", h.registerReward)
+	r.Get("/api/orders/{number}", h.getOrder)
+	h.router = r
+
+	return h
+}
+
+// ServeHTTP реализует http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// registerOrder обрабатывает POST /api/orders
+func (h *Handler) registerOrder(w http.ResponseWriter, r *http.Request) {
+	var reg OrderRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.system.RegisterOrder(reg); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// registerReward обрабатывает POST /api/goods
+func (h *Handler) registerReward(w http.ResponseWriter, r *http.Request) {
+	var reward Reward
+	if err := json.NewDecoder(r.Body).Decode(&reward); err != nil {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.system.RegisterReward(reward); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getOrder обрабатывает GET /api/orders/{number}
+func (h *Handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	if retryAfter, ok := h.allow(); !ok {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(w, "No more than %d requests per minute allowed", h.requestsPerMinute)
+		return
+	}
+
+	info, ok := h.system.GetOrder(chi.URLParam(r, "number"))
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// allow учитывает запрос в текущем минутном окне и возвращает
+// время до начала следующего окна в секундах, если лимит исчерпан
+func (h *Handler) allow() (int, bool) {
+	if h.requestsPerMinute <= 0 {
+		return 0, true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.windowStart) >= time.Minute {
+		h.windowStart = now
+		h.windowCount = 0
+	}
+
+	if h.windowCount >= h.requestsPerMinute {
+		wait := h.windowStart.Add(time.Minute).Sub(now)
+		return int(wait.Seconds()) + 1, false
+	}
+
+	h.windowCount++
+	return 0, true
+}
+
+// writeError переводит ошибку System в код ответа
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrOrderRegistered), errors.Is(err, ErrMatchRegistered):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,259 @@
+// Package accrualsystem содержит локальную эталонную реализацию системы расчёта
+// начислений: регистрацию заказов с товарами, правила вознаграждения и переходы
+// REGISTERED -> PROCESSING -> PROCESSED/INVALID.
+package accrualsystem
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Статусы расчёта начислений в протоколе системы начислений
+const (
+	StatusRegistered = "REGISTERED"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+	StatusInvalid    = "INVALID"
+)
+
+// Типы вознаграждения
+const (
+	// RewardPercent - процент от стоимости товара
+	RewardPercent = "%"
+	// RewardPoints - фиксированное число баллов за товар
+	RewardPoints = "pt"
+)
+
+var (
+	// ErrInvalidRequest возвращается при неверном формате запроса
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrOrderRegistered возвращается при повторной регистрации заказа
+	ErrOrderRegistered = errors.New("order already registered")
+	// ErrMatchRegistered возвращается при повторной регистрации правила
+	ErrMatchRegistered = errors.New("match already registered")
+)
+
+// Good представляет товар в составе заказа
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+// OrderRegistration представляет запрос на регистрацию заказа
+type OrderRegistration struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+// Reward представляет правило вознаграждения за товары, описание которых содержит Match
+type Reward struct {
+	Match      string  `json:"match"`
+	Reward     float64 `json:"reward"`
+	RewardType string  `json:"reward_type"`
+}
+
+// OrderInfo представляет состояние расчёта по заказу
+type OrderInfo struct {
+	Order   string   `json:"order"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+// Config содержит настройки эталонной системы начислений
+type Config struct {
+	// ProcessingDelay - время пребывания заказа в каждом промежуточном статусе
+	ProcessingDelay time.Duration
+}
+
+// order - внутреннее состояние зарегистрированного заказа
+type order struct {
+	goods   []Good
+	status  string
+	accrual *float64
+}
+
+// System хранит заказы и правила вознаграждения и рассчитывает начисления
+type System struct {
+	cfg Config
+
+	mu      sync.Mutex
+	orders  map[string]*order
+	rewards []Reward
+	timers  map[string]*time.Timer
+	closed  bool
+}
+
+// NewSystem создает эталонную систему начислений
+func NewSystem(cfg Config) *System {
+	return &System{
+		cfg:    cfg,
+		orders: make(map[string]*order),
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// Close останавливает фоновые переходы статусов
+func (s *System) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for number, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, number)
+	}
+}
+
+// RegisterReward добавляет правило вознаграждения
+func (s *System) RegisterReward(reward Reward) error {
+	if reward.Match == "" || reward.Reward <= 0 || math.IsInf(reward.Reward, 0) || math.IsNaN(reward.Reward) {
+		return ErrInvalidRequest
+	}
+	if reward.RewardType != RewardPercent && reward.RewardType != RewardPoints {
+		return ErrInvalidRequest
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.rewards {
+		if r.Match == reward.Match {
+			return ErrMatchRegistered
+		}
+	}
+	s.rewards = append(s.rewards, reward)
+	return nil
+}
+
+// RegisterOrder регистрирует заказ и запускает расчёт начисления
+func (s *System) RegisterOrder(reg OrderRegistration) error {
+	if !validOrderNumber(reg.Order) || len(reg.Goods) == 0 {
+		return ErrInvalidRequest
+	}
+	for _, good := range reg.Goods {
+		if good.Description == "" || good.Price < 0 || math.IsInf(good.Price, 0) || math.IsNaN(good.Price) {
+			return ErrInvalidRequest
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[reg.Order]; ok {
+		return ErrOrderRegistered
+	}
+
+	s.orders[reg.Order] = &order{
+		goods:  append([]Good(nil), reg.Goods...),
+		status: StatusRegistered,
+	}
+	s.scheduleLocked(reg.Order)
+	return nil
+}
+
+// GetOrder возвращает состояние расчёта; false, если заказ не зарегистрирован
+func (s *System) GetOrder(number string) (OrderInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[number]
+	if !ok {
+		return OrderInfo{}, false
+	}
+
+	info := OrderInfo{Order: number, Status: o.status}
+	if o.accrual != nil {
+		accrual := *o.accrual
+		info.Accrual = &accrual
+	}
+	return info, true
+}
+
+// scheduleLocked планирует следующий переход статуса заказа; вызывается под мьютексом
+func (s *System) scheduleLocked(number string) {
+	if s.closed {
+		return
+	}
+	s.timers[number] = time.AfterFunc(s.cfg.ProcessingDelay, func() {
+		s.advance(number)
+	})
+}
+
+// advance переводит заказ в следующий статус
+func (s *System) advance(number string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.timers, number)
+	o, ok := s.orders[number]
+	if !ok || s.closed {
+		return
+	}
+
+	switch o.status {
+	case StatusRegistered:
+		o.status = StatusProcessing
+		s.scheduleLocked(number)
+	case StatusProcessing:
+		accrual, matched := calculateAccrual(o.goods, s.rewards)
+		if !matched {
+			// Ни один товар не подпадает под правила: вознаграждение не будет начислено
+			o.status = StatusInvalid
+			return
+		}
+		o.status = StatusProcessed
+		o.accrual = &accrual
+	}
+}
+
+// calculateAccrual суммирует вознаграждение по товарам. К каждому товару применяется
+// первое зарегистрированное правило, чей Match входит в описание товара.
+func calculateAccrual(goods []Good, rewards []Reward) (float64, bool) {
+	var total float64
+	matched := false
+
+	for _, good := range goods {
+		for _, reward := range rewards {
+			if !strings.Contains(good.Description, reward.Match) {
+				continue
+			}
+
+			matched = true
+			switch reward.RewardType {
+			case RewardPercent:
+				total += good.Price * reward.Reward / 100
+			case RewardPoints:
+				total += reward.Reward
+			}
+			break
+		}
+	}
+
+	return math.Round(total*100) / 100, matched
+}
+
+// validOrderNumber проверяет, что номер состоит из цифр и проходит проверку Луна
+func validOrderNumber(number string) bool {
+	if _, err := strconv.ParseUint(number, 10, 64); err != nil {
+		return false
+	}
+
+	sum := 0
+	isSecond := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if isSecond {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		isSecond = !isSecond
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,137 @@
+package accrualsystem
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCalculateAccrual(t *testing.T) {
+	rewards := []Reward{
+		{Match: "Bork", Reward: 10, RewardType: RewardPercent},
+		{Match: "Чайник", Reward: 15, RewardType: RewardPoints},
+	}
+
+	tests := []struct {
+		name        string
+		goods       []Good
+		wantAccrual float64
+		wantMatched bool
+	}{
+		{
+			name:        "процент от стоимости",
+			goods:       []Good{{Description: "Миксер Bork", Price: 7000.55}},
+			wantAccrual: 700.06,
+			wantMatched: true,
+		},
+		{
+			name:        "применяется первое подходящее правило",
+			goods:       []Good{{Description: "Чайник Bork", Price: 1000}},
+			wantAccrual: 100,
+			wantMatched: true,
+		},
+		{
+			name: "сумма по нескольким товарам",
+			goods: []Good{
+				{Description: "Чайник Tefal", Price: 3000},
+				{Description: "Утюг Bork", Price: 500},
+				{Description: "Стол", Price: 9000},
+			},
+			wantAccrual: 65,
+			wantMatched: true,
+		},
+		{
+			name:        "нет подходящих правил",
+			goods:       []Good{{Description: "Стол", Price: 9000}},
+			wantMatched: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accrual, matched := calculateAccrual(tt.goods, rewards)
+			if matched != tt.wantMatched {
+				t.Errorf("matched = %v, want %v", matched, tt.wantMatched)
+			}
+			if accrual != tt.wantAccrual {
+				t.Errorf("accrual = %v, want %v", accrual, tt.wantAccrual)
+			}
+		})
+	}
+}
+
+func TestSystem_Transitions(t *testing.T) {
+	system := NewSystem(Config{ProcessingDelay: 10 * time.Millisecond})
+	defer system.Close()
+
+	if err := system.RegisterReward(Reward{Match: "Bork", Reward: 10, RewardType: RewardPercent}); err != nil {
+		t.Fatalf("RegisterReward() error = %v", err)
+	}
+	if err := system.RegisterOrder(OrderRegistration{Order: "12345678903", Goods: []Good{{Description: "Bork", Price: 500}}}); err != nil {
+		t.Fatalf("RegisterOrder() error = %v", err)
+	}
+	if err := system.RegisterOrder(OrderRegistration{Order: "79927398713", Goods: []Good{{Description: "Стол", Price: 500}}}); err != nil {
+		t.Fatalf("RegisterOrder() error = %v", err)
+	}
+
+	if info, _ := system.GetOrder("12345678903"); info.Status != StatusRegistered {
+		t.Errorf("expected status %s, got %s", StatusRegistered, info.Status)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		processed, _ := system.GetOrder("12345678903")
+		invalid, _ := system.GetOrder("79927398713")
+		if processed.Status == StatusProcessed && invalid.Status == StatusInvalid {
+			if processed.Accrual == nil || *processed.Accrual != 50 {
+				t.Errorf("expected accrual 50, got %v", processed.Accrual)
+			}
+			if invalid.Accrual != nil {
+				t.Errorf("expected no accrual for invalid order, got %v", *invalid.Accrual)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("orders did not reach final statuses")
+}
+
+func TestHandler(t *testing.T) {
+	system := NewSystem(Config{ProcessingDelay: time.Hour})
+	defer system.Close()
+	handler := NewHandler(system, 2)
+
+	do := func(method, path, body string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return w.Code
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"регистрация правила", http.MethodPost, "/api/goods", `{"match":"Bork","reward":10,"reward_type":"%"}`, http.StatusOK},
+		{"повторное правило", http.MethodPost, "/api/goods", `{"match":"Bork","reward":5,"reward_type":"pt"}`, http.StatusConflict},
+		{"неверный тип вознаграждения", http.MethodPost, "/api/goods", `{"match":"Tefal","reward":5,"reward_type":"x"}`, http.StatusBadRequest},
+		{"регистрация заказа", http.MethodPost, "/api/orders", `{"order":"12345678903","goods":[{"description":"Bork","price":100}]}`, http.StatusAccepted},
+		{"повторный заказ", http.MethodPost, "/api/orders", `{"order":"12345678903","goods":[{"description":"Bork","price":100}]}`, http.StatusConflict},
+		{"неверный номер заказа", http.MethodPost, "/api/orders", `{"order":"12345678902","goods":[{"description":"Bork","price":100}]}`, http.StatusBadRequest},
+		{"зарегистрированный заказ", http.MethodGet, "/api/orders/12345678903", "", http.StatusOK},
+		{"незарегистрированный заказ", http.MethodGet, "/api/orders/79927398713", "", http.StatusNoContent},
+		{"превышен лимит запросов", http.MethodGet, "/api/orders/12345678903", "", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := do(tt.method, tt.path, tt.body); code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
@@ -4,15 +4,104 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	// StoragePostgres - данные хранятся в PostgreSQL по адресу DatabaseURI
+	StoragePostgres = "postgres"
+	// StorageMemory - данные хранятся в памяти процесса
+	StorageMemory = "memory"
+)
+
 // Config содержит все настройки приложения
 type Config struct {
-	RunAddress           string
-	DatabaseURI          string
+	RunAddress  string
+	DatabaseURI string
+	// Storage - хранилище данных: postgres или memory. В памяти данные не переживают
+	// перезапуск, режим предназначен для локальной разработки.
+	Storage string
+	// SkipMigrations отключает применение миграций при запуске сервера; схема
+	// в этом случае обновляется отдельно командой gophermart migrate
+	SkipMigrations bool
+	// AccrualSystemAddress - адрес системы начислений или список адресов через запятую,
+	// первый из которых основной
 	AccrualSystemAddress string
-	JWT                  JWTConfig
+	// InstanceID идентифицирует реплику сервиса, например при аренде заданий
+	InstanceID string
+	// AdminToken защищает административные операции; пустое значение их отключает
+	AdminToken string
+	JWT        JWTConfig
+	Accrual    AccrualConfig
+}
+
+// AccrualConfig содержит настройки опроса системы начислений
+type AccrualConfig struct {
+	// Workers - число заказов, опрашиваемых одновременно
+	Workers int
+	// RequestsPerSecond - общий лимит запросов к системе начислений, 0 - без ограничения
+	RequestsPerSecond float64
+	// LeaseTTL - срок аренды задания на опрос одной репликой
+	LeaseTTL time.Duration
+	// BreakerFailures - число ошибок подряд, после которого опрос приостанавливается
+	BreakerFailures int
+	// BreakerTimeout - длительность приостановки опроса после размыкания выключателя
+	BreakerTimeout time.Duration
+	// BreakerHalfOpenRequests - число успешных пробных запросов для возобновления опроса
+	BreakerHalfOpenRequests int
+	// CallbackSecret - общий секрет подписи уведомлений; пустое значение отключает уведомления
+	CallbackSecret string
+	// CallbackReplayWindow - допустимое расхождение времени отправки уведомления с текущим
+	CallbackReplayWindow time.Duration
+	// CallbackTimeout - ожидание уведомления перед первым опросом нового заказа
+	CallbackTimeout time.Duration
+	// ReconcileInterval - период сверки обработанных заказов с системой начислений
+	ReconcileInterval time.Duration
+	// ReconcileWindow - глубина сверки: сверяются заказы, обработанные за этот срок
+	ReconcileWindow time.Duration
+	// PollSchedule - расписание опроса по возрасту заказа вида "0s:5s,5m:30s,24h:1h"
+	PollSchedule string
+	// HealthInterval - период проверки доступности адресов системы начислений
+	HealthInterval time.Duration
+	// CAFile - PEM-файл доверенных центров сертификации системы начислений
+	CAFile string
+	// CertFile и KeyFile - клиентский сертификат и ключ для взаимной аутентификации TLS
+	CertFile string
+	KeyFile  string
+	// AuthHeader - постоянный заголовок запросов вида "Authorization: Bearer token"
+	AuthHeader string
+	// RequestTimeout - таймаут одного запроса к системе начислений
+	RequestTimeout time.Duration
+	// MaxIdleConns и MaxIdleConnsPerHost ограничивают пул простаивающих соединений
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost ограничивает число соединений с одним адресом, 0 - без ограничения
+	MaxConnsPerHost int
+	// IdleConnTimeout - время жизни простаивающего соединения
+	IdleConnTimeout time.Duration
+	// CassetteMode - запись обмена с системой начислений в кассету (record)
+	// или воспроизведение из неё (replay); пустое значение - обычная работа
+	CassetteMode string
+	// CassettePath - файл кассеты
+	CassettePath string
+	// Faults - сбои, вносимые в ответы системы начислений для учений в dev и staging
+	Faults AccrualFaultConfig
+}
+
+// AccrualFaultConfig содержит настройки внесения сбоев; нулевые значения их отключают
+type AccrualFaultConfig struct {
+	// Latency и LatencyJitter - постоянная и наибольшая случайная добавочная задержка
+	Latency       time.Duration
+	LatencyJitter time.Duration
+	// ErrorRate, TooManyRequestsRate и MalformedRate - доли запросов с ошибкой,
+	// ответом 429 и некорректным телом
+	ErrorRate           float64
+	TooManyRequestsRate float64
+	MalformedRate       float64
+	// MaxRetryAfter - наибольший случайный Retry-After для внесенных ответов 429
+	MaxRetryAfter time.Duration
 }
 
 // JWTConfig содержит настройки JWT
@@ -31,7 +120,41 @@ func NewConfig() (*Config, error) {
 	// Чтение флагов командной строки
 	flags.StringVar(&cfg.RunAddress, "a", "", "address and port to run server")
 	flags.StringVar(&cfg.DatabaseURI, "d", "", "database connection string")
-	flags.StringVar(&cfg.AccrualSystemAddress, "r", "", "Accrual system address")
+	flags.StringVar(&cfg.Storage, "storage", "", "storage backend: postgres|memory")
+	flags.BoolVar(&cfg.SkipMigrations, "skip-migrations", false, "do not apply database migrations on start")
+	flags.StringVar(&cfg.AccrualSystemAddress, "r", "", "Accrual system address, comma-separated list for failover")
+	flags.IntVar(&cfg.Accrual.Workers, "accrual-workers", 0, "number of concurrent accrual workers")
+	flags.Float64Var(&cfg.Accrual.RequestsPerSecond, "accrual-rps", 0, "max requests per second to accrual system")
+	flags.DurationVar(&cfg.Accrual.LeaseTTL, "accrual-lease-ttl", 0, "accrual job lease duration")
+	flags.IntVar(&cfg.Accrual.BreakerFailures, "accrual-breaker-failures", 0, "consecutive accrual failures that open the circuit breaker")
+	flags.DurationVar(&cfg.Accrual.BreakerTimeout, "accrual-breaker-timeout", 0, "how long the accrual circuit breaker stays open")
+	flags.IntVar(&cfg.Accrual.BreakerHalfOpenRequests, "accrual-breaker-half-open", 0, "successful probes required to close the accrual circuit breaker")
+	flags.StringVar(&cfg.Accrual.CallbackSecret, "accrual-callback-secret", "", "shared secret for signed accrual callbacks")
+	flags.DurationVar(&cfg.Accrual.CallbackReplayWindow, "accrual-callback-replay-window", 0, "max accrual callback timestamp skew")
+	flags.DurationVar(&cfg.Accrual.CallbackTimeout, "accrual-callback-timeout", 0, "how long to wait for a callback before polling a new order")
+	flags.DurationVar(&cfg.Accrual.ReconcileInterval, "accrual-reconcile-interval", 0, "how often processed orders are reconciled with accrual system")
+	flags.DurationVar(&cfg.Accrual.ReconcileWindow, "accrual-reconcile-window", 0, "how far back processed orders are reconciled")
+	flags.StringVar(&cfg.Accrual.PollSchedule, "accrual-poll-schedule", "", "poll interval by order age, e.g. 0s:5s,5m:30s,24h:1h")
+	flags.DurationVar(&cfg.Accrual.HealthInterval, "accrual-health-interval", 0, "how often accrual system endpoints are health-checked")
+	flags.StringVar(&cfg.Accrual.CAFile, "accrual-ca-file", "", "PEM bundle of CAs trusted for accrual system")
+	flags.StringVar(&cfg.Accrual.CertFile, "accrual-cert-file", "", "client certificate for accrual system mTLS")
+	flags.StringVar(&cfg.Accrual.KeyFile, "accrual-key-file", "", "client key for accrual system mTLS")
+	flags.StringVar(&cfg.Accrual.AuthHeader, "accrual-auth-header", "", "static header sent to accrual system, e.g. \"Authorization: Bearer token\"")
+	flags.DurationVar(&cfg.Accrual.RequestTimeout, "accrual-request-timeout", 0, "accrual system request timeout")
+	flags.IntVar(&cfg.Accrual.MaxIdleConns, "accrual-max-idle-conns", 0, "max idle connections to accrual system")
+	flags.IntVar(&cfg.Accrual.MaxIdleConnsPerHost, "accrual-max-idle-conns-per-host", 0, "max idle connections per accrual endpoint")
+	flags.IntVar(&cfg.Accrual.MaxConnsPerHost, "accrual-max-conns-per-host", 0, "max connections per accrual endpoint")
+	flags.DurationVar(&cfg.Accrual.IdleConnTimeout, "accrual-idle-conn-timeout", 0, "how long idle accrual connections are kept")
+	flags.StringVar(&cfg.Accrual.CassetteMode, "accrual-cassette-mode", "", "record accrual traffic to cassette or replay it: record|replay")
+	flags.StringVar(&cfg.Accrual.CassettePath, "accrual-cassette", "", "accrual cassette file")
+	flags.DurationVar(&cfg.Accrual.Faults.Latency, "accrual-fault-latency", 0, "latency injected into every accrual request")
+	flags.DurationVar(&cfg.Accrual.Faults.LatencyJitter, "accrual-fault-latency-jitter", 0, "max random latency added to injected latency")
+	flags.Float64Var(&cfg.Accrual.Faults.ErrorRate, "accrual-fault-error-rate", 0, "share of accrual requests failing with injected error")
+	flags.Float64Var(&cfg.Accrual.Faults.TooManyRequestsRate, "accrual-fault-429-rate", 0, "share of accrual requests answered with injected 429")
+	flags.Float64Var(&cfg.Accrual.Faults.MalformedRate, "accrual-fault-malformed-rate", 0, "share of accrual requests with injected malformed body")
+	flags.DurationVar(&cfg.Accrual.Faults.MaxRetryAfter, "accrual-fault-max-retry-after", 0, "max Retry-After of injected 429 responses")
+	flags.StringVar(&cfg.InstanceID, "instance-id", "", "unique id of this service replica")
+	flags.StringVar(&cfg.AdminToken, "admin-token", "", "bearer token for admin api")
 
 	// Парсим флаги только если не в тестовом режиме
 	if len(os.Args) > 0 {
@@ -45,9 +168,156 @@ func NewConfig() (*Config, error) {
 	if cfg.DatabaseURI == "" {
 		cfg.DatabaseURI = os.Getenv("DATABASE_URI")
 	}
+	if cfg.Storage == "" {
+		cfg.Storage = os.Getenv("STORAGE")
+	}
+	if err := envBool(&cfg.SkipMigrations, "SKIP_MIGRATIONS"); err != nil {
+		return nil, err
+	}
 	if cfg.AccrualSystemAddress == "" {
 		cfg.AccrualSystemAddress = os.Getenv("ACCRUAL_SYSTEM_ADDRESS")
 	}
+	if err := envInt(&cfg.Accrual.Workers, "ACCRUAL_WORKERS"); err != nil {
+		return nil, err
+	}
+	if err := envFloat(&cfg.Accrual.RequestsPerSecond, "ACCRUAL_RPS"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.LeaseTTL, "ACCRUAL_LEASE_TTL"); err != nil {
+		return nil, err
+	}
+	if err := envInt(&cfg.Accrual.BreakerFailures, "ACCRUAL_BREAKER_FAILURES"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.BreakerTimeout, "ACCRUAL_BREAKER_TIMEOUT"); err != nil {
+		return nil, err
+	}
+	if err := envInt(&cfg.Accrual.BreakerHalfOpenRequests, "ACCRUAL_BREAKER_HALF_OPEN"); err != nil {
+		return nil, err
+	}
+	if cfg.Accrual.CallbackSecret == "" {
+		cfg.Accrual.CallbackSecret = os.Getenv("ACCRUAL_CALLBACK_SECRET")
+	}
+	if err := envDuration(&cfg.Accrual.CallbackReplayWindow, "ACCRUAL_CALLBACK_REPLAY_WINDOW"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.CallbackTimeout, "ACCRUAL_CALLBACK_TIMEOUT"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.ReconcileInterval, "ACCRUAL_RECONCILE_INTERVAL"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.ReconcileWindow, "ACCRUAL_RECONCILE_WINDOW"); err != nil {
+		return nil, err
+	}
+	if cfg.Accrual.PollSchedule == "" {
+		cfg.Accrual.PollSchedule = os.Getenv("ACCRUAL_POLL_SCHEDULE")
+	}
+	if err := envDuration(&cfg.Accrual.HealthInterval, "ACCRUAL_HEALTH_INTERVAL"); err != nil {
+		return nil, err
+	}
+	if cfg.Accrual.CAFile == "" {
+		cfg.Accrual.CAFile = os.Getenv("ACCRUAL_CA_FILE")
+	}
+	if cfg.Accrual.CertFile == "" {
+		cfg.Accrual.CertFile = os.Getenv("ACCRUAL_CERT_FILE")
+	}
+	if cfg.Accrual.KeyFile == "" {
+		cfg.Accrual.KeyFile = os.Getenv("ACCRUAL_KEY_FILE")
+	}
+	if cfg.Accrual.AuthHeader == "" {
+		cfg.Accrual.AuthHeader = os.Getenv("ACCRUAL_AUTH_HEADER")
+	}
+	if err := envDuration(&cfg.Accrual.RequestTimeout, "ACCRUAL_REQUEST_TIMEOUT"); err != nil {
+		return nil, err
+	}
+	if err := envInt(&cfg.Accrual.MaxIdleConns, "ACCRUAL_MAX_IDLE_CONNS"); err != nil {
+		return nil, err
+	}
+	if err := envInt(&cfg.Accrual.MaxIdleConnsPerHost, "ACCRUAL_MAX_IDLE_CONNS_PER_HOST"); err != nil {
+		return nil, err
+	}
+	if err := envInt(&cfg.Accrual.MaxConnsPerHost, "ACCRUAL_MAX_CONNS_PER_HOST"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.IdleConnTimeout, "ACCRUAL_IDLE_CONN_TIMEOUT"); err != nil {
+		return nil, err
+	}
+	if cfg.Accrual.CassetteMode == "" {
+		cfg.Accrual.CassetteMode = os.Getenv("ACCRUAL_CASSETTE_MODE")
+	}
+	if cfg.Accrual.CassettePath == "" {
+		cfg.Accrual.CassettePath = os.Getenv("ACCRUAL_CASSETTE_PATH")
+	}
+	if err := envDuration(&cfg.Accrual.Faults.Latency, "ACCRUAL_FAULT_LATENCY"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.Faults.LatencyJitter, "ACCRUAL_FAULT_LATENCY_JITTER"); err != nil {
+		return nil, err
+	}
+	if err := envFloat(&cfg.Accrual.Faults.ErrorRate, "ACCRUAL_FAULT_ERROR_RATE"); err != nil {
+		return nil, err
+	}
+	if err := envFloat(&cfg.Accrual.Faults.TooManyRequestsRate, "ACCRUAL_FAULT_429_RATE"); err != nil {
+		return nil, err
+	}
+	if err := envFloat(&cfg.Accrual.Faults.MalformedRate, "ACCRUAL_FAULT_MALFORMED_RATE"); err != nil {
+		return nil, err
+	}
+	if err := envDuration(&cfg.Accrual.Faults.MaxRetryAfter, "ACCRUAL_FAULT_MAX_RETRY_AFTER"); err != nil {
+		return nil, err
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = os.Getenv("INSTANCE_ID")
+	}
+	if cfg.AdminToken == "" {
+		cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+	}
+
+	// Настройки опроса системы начислений по умолчанию
+	if cfg.Accrual.Workers == 0 {
+		cfg.Accrual.Workers = 4
+	}
+	if cfg.Accrual.LeaseTTL == 0 {
+		cfg.Accrual.LeaseTTL = 30 * time.Second
+	}
+	if cfg.Accrual.BreakerFailures == 0 {
+		cfg.Accrual.BreakerFailures = 5
+	}
+	if cfg.Accrual.BreakerTimeout == 0 {
+		cfg.Accrual.BreakerTimeout = 30 * time.Second
+	}
+	if cfg.Accrual.BreakerHalfOpenRequests == 0 {
+		cfg.Accrual.BreakerHalfOpenRequests = 1
+	}
+	if cfg.Accrual.CallbackReplayWindow == 0 {
+		cfg.Accrual.CallbackReplayWindow = 5 * time.Minute
+	}
+	// Без уведомлений опрос начинается сразу, с ними служит запасным путём
+	if cfg.Accrual.CallbackTimeout == 0 && cfg.Accrual.CallbackSecret != "" {
+		cfg.Accrual.CallbackTimeout = time.Minute
+	}
+	if cfg.Accrual.ReconcileInterval == 0 {
+		cfg.Accrual.ReconcileInterval = time.Hour
+	}
+	if cfg.Accrual.ReconcileWindow == 0 {
+		cfg.Accrual.ReconcileWindow = 7 * 24 * time.Hour
+	}
+	if cfg.Accrual.HealthInterval == 0 {
+		cfg.Accrual.HealthInterval = 10 * time.Second
+	}
+	if cfg.Accrual.RequestTimeout == 0 {
+		cfg.Accrual.RequestTimeout = 10 * time.Second
+	}
+	if cfg.Accrual.MaxIdleConnsPerHost == 0 {
+		cfg.Accrual.MaxIdleConnsPerHost = cfg.Accrual.Workers
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = defaultInstanceID()
+	}
+	if cfg.Storage == "" {
+		cfg.Storage = StoragePostgres
+	}
 
 	// Настройки JWT по умолчанию
 	cfg.JWT = JWTConfig{
@@ -68,11 +338,131 @@ func (c *Config) validate() error {
 	if c.RunAddress == "" {
 		return fmt.Errorf("server address is required (use -a flag or RUN_ADDRESS env)")
 	}
-	if c.DatabaseURI == "" {
-		return fmt.Errorf("database URI is required (use -d flag or DATABASE_URI env)")
+	switch c.Storage {
+	case StoragePostgres:
+		if c.DatabaseURI == "" {
+			return fmt.Errorf("database URI is required (use -d flag or DATABASE_URI env)")
+		}
+	case StorageMemory:
+	default:
+		return fmt.Errorf("storage must be postgres or memory (use -storage flag or STORAGE env)")
 	}
-	if c.AccrualSystemAddress == "" {
+	if len(c.AccrualAddresses()) == 0 {
 		return fmt.Errorf("accrual system address is required (use -r flag or ACCRUAL_SYSTEM_ADDRESS env)")
 	}
+	if c.Accrual.Workers < 0 {
+		return fmt.Errorf("accrual workers must be positive (use -accrual-workers flag or ACCRUAL_WORKERS env)")
+	}
+	if c.Accrual.LeaseTTL < 0 {
+		return fmt.Errorf("accrual lease ttl must be positive (use -accrual-lease-ttl flag or ACCRUAL_LEASE_TTL env)")
+	}
+	if c.Accrual.BreakerFailures < 0 || c.Accrual.BreakerTimeout < 0 || c.Accrual.BreakerHalfOpenRequests < 0 {
+		return fmt.Errorf("accrual circuit breaker settings must be positive")
+	}
+	if c.Accrual.CallbackReplayWindow < 0 || c.Accrual.CallbackTimeout < 0 {
+		return fmt.Errorf("accrual callback settings must be positive")
+	}
+	if c.Accrual.ReconcileInterval < 0 || c.Accrual.ReconcileWindow < 0 {
+		return fmt.Errorf("accrual reconciliation settings must be positive")
+	}
+	if c.Accrual.HealthInterval < 0 {
+		return fmt.Errorf("accrual health interval must be positive (use -accrual-health-interval flag or ACCRUAL_HEALTH_INTERVAL env)")
+	}
+	if (c.Accrual.CertFile == "") != (c.Accrual.KeyFile == "") {
+		return fmt.Errorf("accrual client certificate and key must be set together (use -accrual-cert-file and -accrual-key-file flags)")
+	}
+	if c.Accrual.RequestTimeout < 0 || c.Accrual.IdleConnTimeout < 0 ||
+		c.Accrual.MaxIdleConns < 0 || c.Accrual.MaxIdleConnsPerHost < 0 || c.Accrual.MaxConnsPerHost < 0 {
+		return fmt.Errorf("accrual transport settings must be positive")
+	}
+	switch c.Accrual.CassetteMode {
+	case "":
+	case "record", "replay":
+		if c.Accrual.CassettePath == "" {
+			return fmt.Errorf("accrual cassette path is required (use -accrual-cassette flag or ACCRUAL_CASSETTE_PATH env)")
+		}
+	default:
+		return fmt.Errorf("accrual cassette mode must be record or replay (use -accrual-cassette-mode flag or ACCRUAL_CASSETTE_MODE env)")
+	}
+	if c.Accrual.RequestsPerSecond < 0 {
+		return fmt.Errorf("accrual rps must not be negative (use -accrual-rps flag or ACCRUAL_RPS env)")
+	}
+	return nil
+}
+
+// AccrualAddresses возвращает адреса системы начислений в порядке предпочтения
+func (c *Config) AccrualAddresses() []string {
+	var addresses []string
+	for _, address := range strings.Split(c.AccrualSystemAddress, ",") {
+		address = strings.TrimRight(strings.TrimSpace(address), "/")
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// defaultInstanceID строит идентификатор реплики из имени хоста и PID процесса
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// envInt заполняет dst из переменной окружения name, если значение не задано флагом
+func envInt(dst *int, name string) error {
+	v := os.Getenv(name)
+	if *dst != 0 || v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = n
+	return nil
+}
+
+// envBool заполняет dst из переменной окружения name, если значение не задано флагом
+func envBool(dst *bool, name string) error {
+	v := os.Getenv(name)
+	if *dst || v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = b
+	return nil
+}
+
+// envFloat заполняет dst из переменной окружения name, если значение не задано флагом
+func envFloat(dst *float64, name string) error {
+	v := os.Getenv(name)
+	if *dst != 0 || v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = f
+	return nil
+}
+
+// envDuration заполняет dst из переменной окружения name, если значение не задано флагом
+func envDuration(dst *time.Duration, name string) error {
+	v := os.Getenv(name)
+	if *dst != 0 || v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*dst = d
 	return nil
 }
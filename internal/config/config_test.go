@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestConfig(t *testing.T) {
@@ -11,6 +12,8 @@ func TestConfig(t *testing.T) {
 		os.Unsetenv("RUN_ADDRESS")
 		os.Unsetenv("DATABASE_URI")
 		os.Unsetenv("ACCRUAL_SYSTEM_ADDRESS")
+		os.Unsetenv("STORAGE")
+		os.Unsetenv("SKIP_MIGRATIONS")
 	}()
 
 	tests := []struct {
@@ -43,6 +46,65 @@ func TestConfig(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "memory storage without database uri",
+			envVars: map[string]string{
+				"RUN_ADDRESS":            "localhost:8080",
+				"ACCRUAL_SYSTEM_ADDRESS": "http://localhost:8081",
+				"STORAGE":                "memory",
+			},
+			wantError: false,
+		},
+		{
+			name: "unknown storage",
+			envVars: map[string]string{
+				"RUN_ADDRESS":            "localhost:8080",
+				"DATABASE_URI":           "postgres://localhost:5432/db",
+				"ACCRUAL_SYSTEM_ADDRESS": "http://localhost:8081",
+				"STORAGE":                "sqlite",
+			},
+			wantError: true,
+		},
+		{
+			name: "skip migrations",
+			envVars: map[string]string{
+				"RUN_ADDRESS":            "localhost:8080",
+				"DATABASE_URI":           "postgres://localhost:5432/db",
+				"ACCRUAL_SYSTEM_ADDRESS": "http://localhost:8081",
+				"SKIP_MIGRATIONS":        "true",
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid skip migrations",
+			envVars: map[string]string{
+				"RUN_ADDRESS":            "localhost:8080",
+				"DATABASE_URI":           "postgres://localhost:5432/db",
+				"ACCRUAL_SYSTEM_ADDRESS": "http://localhost:8081",
+				"SKIP_MIGRATIONS":        "sometimes",
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid accrual workers",
+			envVars: map[string]string{
+				"RUN_ADDRESS":            "localhost:8080",
+				"DATABASE_URI":           "postgres://localhost:5432/db",
+				"ACCRUAL_SYSTEM_ADDRESS": "http://localhost:8081",
+				"ACCRUAL_WORKERS":        "many",
+			},
+			wantError: true,
+		},
+		{
+			name: "negative accrual rps",
+			envVars: map[string]string{
+				"RUN_ADDRESS":            "localhost:8080",
+				"DATABASE_URI":           "postgres://localhost:5432/db",
+				"ACCRUAL_SYSTEM_ADDRESS": "http://localhost:8081",
+				"ACCRUAL_RPS":            "-1",
+			},
+			wantError: true,
+		},
 		{
 			name: "missing accrual address",
 			envVars: map[string]string{
@@ -81,6 +143,9 @@ func TestConfig(t *testing.T) {
 				if v, ok := tt.envVars["ACCRUAL_SYSTEM_ADDRESS"]; ok && cfg.AccrualSystemAddress != v {
 					t.Errorf("expected AccrualSystemAddress %s, got %s", v, cfg.AccrualSystemAddress)
 				}
+				if _, ok := tt.envVars["SKIP_MIGRATIONS"]; ok != cfg.SkipMigrations {
+					t.Errorf("expected SkipMigrations %v, got %v", ok, cfg.SkipMigrations)
+				}
 			}
 
 			// Очистка переменных окружения после каждого теста
@@ -90,3 +155,73 @@ func TestConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_AccrualDefaults(t *testing.T) {
+	t.Setenv("RUN_ADDRESS", "localhost:8080")
+	t.Setenv("DATABASE_URI", "postgres://localhost:5432/db")
+	t.Setenv("ACCRUAL_SYSTEM_ADDRESS", "http://localhost:8081")
+	t.Setenv("ACCRUAL_RPS", "25")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Accrual.Workers != 4 {
+		t.Errorf("expected default Accrual.Workers 4, got %d", cfg.Accrual.Workers)
+	}
+	if cfg.Accrual.RequestsPerSecond != 25 {
+		t.Errorf("expected Accrual.RequestsPerSecond 25, got %v", cfg.Accrual.RequestsPerSecond)
+	}
+	if cfg.Accrual.LeaseTTL != 30*time.Second {
+		t.Errorf("expected default Accrual.LeaseTTL 30s, got %v", cfg.Accrual.LeaseTTL)
+	}
+	if cfg.InstanceID == "" {
+		t.Error("expected default InstanceID to be generated")
+	}
+}
+
+func TestConfig_AccrualCallbackDefaults(t *testing.T) {
+	t.Setenv("RUN_ADDRESS", "localhost:8080")
+	t.Setenv("DATABASE_URI", "postgres://localhost:5432/db")
+	t.Setenv("ACCRUAL_SYSTEM_ADDRESS", "http://localhost:8081")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Accrual.CallbackTimeout != 0 {
+		t.Errorf("expected no callback timeout without secret, got %v", cfg.Accrual.CallbackTimeout)
+	}
+
+	t.Setenv("ACCRUAL_CALLBACK_SECRET", "secret")
+	cfg, err = NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Accrual.CallbackTimeout != time.Minute {
+		t.Errorf("expected default callback timeout 1m, got %v", cfg.Accrual.CallbackTimeout)
+	}
+	if cfg.Accrual.CallbackReplayWindow != 5*time.Minute {
+		t.Errorf("expected default replay window 5m, got %v", cfg.Accrual.CallbackReplayWindow)
+	}
+}
+
+func TestConfig_AccrualAddresses(t *testing.T) {
+	t.Setenv("RUN_ADDRESS", "localhost:8080")
+	t.Setenv("DATABASE_URI", "postgres://localhost:5432/db")
+	t.Setenv("ACCRUAL_SYSTEM_ADDRESS", "http://primary:8081, http://dr:8081/,")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	addresses := cfg.AccrualAddresses()
+	if len(addresses) != 2 || addresses[0] != "http://primary:8081" || addresses[1] != "http://dr:8081" {
+		t.Errorf("expected primary and dr addresses, got %v", addresses)
+	}
+	if cfg.Accrual.HealthInterval != 10*time.Second {
+		t.Errorf("expected default health interval 10s, got %v", cfg.Accrual.HealthInterval)
+	}
+}
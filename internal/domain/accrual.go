@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+// AccrualErrorClass классифицирует причину повторного опроса системы начислений
+type AccrualErrorClass string
+
+const (
+	// AccrualErrorUnavailable - сетевая ошибка или ответ 5xx системы начислений
+	AccrualErrorUnavailable AccrualErrorClass = "unavailable"
+	// AccrualErrorNotRegistered - заказ не зарегистрирован в системе начислений (204)
+	AccrualErrorNotRegistered AccrualErrorClass = "not_registered"
+	// AccrualErrorInvalidResponse - ответ системы начислений не прошел проверку
+	AccrualErrorInvalidResponse AccrualErrorClass = "invalid_response"
+	// AccrualErrorStorage - ошибка сохранения результата в хранилище
+	AccrualErrorStorage AccrualErrorClass = "storage"
+	// AccrualPending - расчёт ещё не окончен, ошибки нет
+	AccrualPending AccrualErrorClass = "pending"
+	// AccrualThrottled - система начислений ответила 429, попытка не расходуется
+	AccrualThrottled AccrualErrorClass = "throttled"
+)
+
+// AccrualJob представляет задание на опрос системы начислений по заказу
+type AccrualJob struct {
+	OrderNumber   string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	// ErrorClass - класс последней неудачной попытки
+	ErrorClass AccrualErrorClass
+	// ClassAttempts - число подряд идущих попыток с классом ErrorClass
+	ClassAttempts int
+	// CreatedAt - момент постановки задания в очередь
+	CreatedAt time.Time
+	// UploadedAt - момент загрузки заказа, от него отсчитывается расписание опроса
+	UploadedAt time.Time
+	// LockedBy - идентификатор реплики, арендовавшей задание
+	LockedBy string
+	// LockedUntil - момент истечения аренды
+	LockedUntil time.Time
+}
+
+// CircuitState представляет состояние автоматического выключателя
+type CircuitState string
+
+const (
+	// CircuitClosed - запросы проходят, ошибки подсчитываются
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen - запросы блокируются до истечения таймаута
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen - пропускается ограниченное число пробных запросов
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerStatus представляет текущее состояние выключателя системы начислений
+type CircuitBreakerStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
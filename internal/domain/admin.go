@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// AdminAction определяет вид административной операции
+type AdminAction string
+
+const (
+	// AdminActionRequeue - заказ возвращен в очередь опроса
+	AdminActionRequeue AdminAction = "requeue"
+	// AdminActionBulkRequeue - заказы, выбранные фильтром, возвращены в очередь опроса
+	AdminActionBulkRequeue AdminAction = "bulk_requeue"
+	// AdminActionForceStatus - статус заказа установлен вручную
+	AdminActionForceStatus AdminAction = "force_status"
+)
+
+// AdminAuditEntry представляет запись журнала административных операций
+type AdminAuditEntry struct {
+	ID          int64       `json:"id"`
+	Actor       string      `json:"actor"`
+	Action      AdminAction `json:"action"`
+	OrderNumber string      `json:"order,omitempty"`
+	// Details - параметры операции в свободной форме
+	Details   string    `json:"details,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Affected  int       `json:"affected"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RequeueFilter отбирает заказы для массового возврата в очередь.
+// Нулевые границы интервала загрузки не ограничивают выборку.
+type RequeueFilter struct {
+	Status       OrderStatus
+	UploadedFrom time.Time
+	UploadedTo   time.Time
+}
@@ -4,19 +4,26 @@ import "time"
 
 // Balance представляет баланс пользователя
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   Money `json:"current"`
+	Withdrawn Money `json:"withdrawn"`
 }
 
 // WithdrawalRequest представляет запрос на списание баллов
 type WithdrawalRequest struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string `json:"order"`
+	Sum   Money  `json:"sum"`
 }
 
+// IdempotencyKeyTTL - время, в течение которого повтор запроса на списание с тем же
+// ключом идемпотентности возвращает исходный результат
+const IdempotencyKeyTTL = 24 * time.Hour
+
 // Withdrawal представляет информацию о списании
 type Withdrawal struct {
+	ID          int64     `json:"id"`
 	OrderNumber string    `json:"order"`
-	Sum         float64   `json:"sum"`
+	Sum         Money     `json:"sum"`
 	ProcessedAt time.Time `json:"processed_at"`
+	// Replayed - списание проведено раньше, запрос повторный
+	Replayed bool `json:"-"`
 }
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -21,6 +22,12 @@ var (
 	// ErrInsufficientFunds возвращается при недостаточном балансе
 	ErrInsufficientFunds = errors.New("insufficient funds")
 
+	// ErrWithdrawalConflict возвращается, если по заказу уже проведено списание с другими параметрами
+	ErrWithdrawalConflict = errors.New("withdrawal for order already exists")
+
+	// ErrIdempotencyKeyReused возвращается, если ключ идемпотентности использован для другого запроса
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with different request")
+
 	// ErrOrderNotFound возвращается, когда заказ не найден
 	ErrOrderNotFound = errors.New("order not found")
 
@@ -38,8 +45,79 @@ var (
 
 	// ErrInvalidAmount возвращается при неверной сумме операции
 	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrOrderNotRegistered возвращается, когда заказ не зарегистрирован в системе начислений
+	ErrOrderNotRegistered = errors.New("order not registered in accrual system")
+
+	// ErrInvalidSignature возвращается при неверной или просроченной подписи уведомления
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrLeaseLost возвращается, если аренда задания опроса истекла и задание забрала другая реплика
+	ErrLeaseLost = errors.New("accrual job lease lost")
+
+	// ErrAccrualChanged возвращается, если начисление по заказу изменилось во время сверки
+	ErrAccrualChanged = errors.New("order accrual changed concurrently")
+
+	// ErrReconciliationLocked возвращается, если сверку уже выполняет другая реплика
+	ErrReconciliationLocked = errors.New("reconciliation is running on another replica")
+
+	// ErrReconciliationReportNotFound возвращается, если ни один проход сверки еще не завершен
+	ErrReconciliationReportNotFound = errors.New("reconciliation report not found")
+
+	// ErrReasonRequired возвращается, если для ручного изменения статуса не указана причина
+	ErrReasonRequired = errors.New("reason is required")
+
+	// ErrInvalidOrderStatus возвращается при недопустимом статусе заказа в запросе
+	ErrInvalidOrderStatus = errors.New("invalid order status")
+
+	// ErrOrderAlreadyProcessed возвращается при попытке изменить статус уже начисленного заказа
+	ErrOrderAlreadyProcessed = errors.New("order already processed")
+
+	// ErrInvalidAccrualResponse - общая причина для всех ответов системы начислений, не прошедших проверку
+	ErrInvalidAccrualResponse = errors.New("invalid accrual response")
+
+	// ErrAccrualMalformedBody - тело ответа не удалось разобрать
+	ErrAccrualMalformedBody = errors.New("malformed response body")
+
+	// ErrAccrualUnknownStatus - статус расчёта не входит в протокол системы начислений
+	ErrAccrualUnknownStatus = errors.New("unknown accrual status")
+
+	// ErrAccrualOrderMismatch - номер заказа в ответе не совпадает с запрошенным
+	ErrAccrualOrderMismatch = errors.New("order number mismatch")
+
+	// ErrAccrualInvalidAmount - начисление отрицательное или не является конечным числом
+	ErrAccrualInvalidAmount = errors.New("invalid accrual amount")
+
+	// ErrAccrualUnexpectedAccrual - начисление передано для статуса, отличного от PROCESSED
+	ErrAccrualUnexpectedAccrual = errors.New("accrual for non-processed order")
 )
 
+// AccrualResponseError описывает ответ системы начислений, не прошедший проверку.
+// Reason - одна из ошибок ErrAccrual*, сама ошибка также соответствует ErrInvalidAccrualResponse.
+type AccrualResponseError struct {
+	Order  string
+	Reason error
+	Detail string
+}
+
+func (e *AccrualResponseError) Error() string {
+	return fmt.Sprintf("%v for order %s: %v: %s", ErrInvalidAccrualResponse, e.Order, e.Reason, e.Detail)
+}
+
+// Unwrap позволяет проверять причину через errors.Is
+func (e *AccrualResponseError) Unwrap() []error {
+	return []error{ErrInvalidAccrualResponse, e.Reason}
+}
+
+// NewAccrualResponseError создает ошибку некорректного ответа системы начислений
+func NewAccrualResponseError(order string, reason error, detail string) *AccrualResponseError {
+	return &AccrualResponseError{
+		Order:  order,
+		Reason: reason,
+		Detail: detail,
+	}
+}
+
 // TooManyRequestsError ошибка превышения лимита запросов
 type TooManyRequestsError struct {
 	RetryAfter time.Duration
@@ -55,3 +133,20 @@ func NewTooManyRequestsError(retryAfter time.Duration) *TooManyRequestsError {
 		RetryAfter: retryAfter,
 	}
 }
+
+// CircuitOpenError возвращается, когда запросы к системе начислений временно
+// заблокированы автоматическим выключателем
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return "accrual circuit breaker is open"
+}
+
+// NewCircuitOpenError создает новую ошибку открытого выключателя
+func NewCircuitOpenError(retryAfter time.Duration) *CircuitOpenError {
+	return &CircuitOpenError{
+		RetryAfter: retryAfter,
+	}
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// LedgerEntryKind определяет вид операции в журнале баллов
+type LedgerEntryKind string
+
+const (
+	// LedgerAccrual - зачисление начисления по обработанному заказу
+	LedgerAccrual LedgerEntryKind = "accrual"
+	// LedgerWithdrawal - списание баллов в счёт оплаты заказа
+	LedgerWithdrawal LedgerEntryKind = "withdrawal"
+	// LedgerAdjustment - доначисление по итогам сверки
+	LedgerAdjustment LedgerEntryKind = "adjustment"
+	// LedgerReversal - отмена части начисления по итогам сверки
+	LedgerReversal LedgerEntryKind = "reversal"
+)
+
+// LedgerAccount - счёт, по которому проводится запись журнала. Записи одной операции
+// проводятся по нескольким счетам и в сумме дают ноль.
+type LedgerAccount string
+
+const (
+	// AccountCurrent - доступные пользователю баллы
+	AccountCurrent LedgerAccount = "current"
+	// AccountWithdrawn - баллы, потраченные пользователем за всё время
+	AccountWithdrawn LedgerAccount = "withdrawn"
+	// AccountDebt - долг пользователя, остаток принимает отрицательное значение
+	AccountDebt LedgerAccount = "debt"
+	// AccountAccrualSystem - встречный счёт системы начислений, из которой приходят баллы
+	AccountAccrualSystem LedgerAccount = "accrual_system"
+)
+
+// Transaction представляет операцию в выписке пользователя
+type Transaction struct {
+	ID   int64           `json:"id"`
+	Kind LedgerEntryKind `json:"type"`
+	// OrderNumber - заказ, по которому проведена операция; пуст для корректировок,
+	// перенесенных из балансов при создании журнала
+	OrderNumber string `json:"order,omitempty"`
+	// Amount - изменение доступных баллов
+	Amount Money `json:"amount"`
+	// Debt - изменение долга: положительное при возникновении, отрицательное при погашении
+	Debt      Money     `json:"debt,omitempty"`
+	CreatedAt time.Time `json:"processed_at"`
+}
+
+// TransactionPage - страница выписки. NextCursor пуст на последней странице.
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+// ErrInvalidCursor возвращается при неверном курсоре постраничной выборки
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor возвращает непрозрачный курсор, указывающий на запись с идентификатором id
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor разбирает курсор, созданный EncodeCursor. Пустой курсор означает первую страницу.
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "testing"
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    int64
+		wantErr bool
+	}{
+		{name: "первая страница", cursor: "", want: 0},
+		{name: "курсор операции", cursor: EncodeCursor(42), want: 42},
+		{name: "не base64", cursor: "!!!", wantErr: true},
+		{name: "не число", cursor: "YWJj", wantErr: true},
+		{name: "неположительный идентификатор", cursor: EncodeCursor(0), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(tt.cursor)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Errorf("expected ErrInvalidCursor, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeCursor(%q) = %d, want %d", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
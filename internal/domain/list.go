@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// OrderFilter отбирает заказы пользователя для списка. Пустой Statuses и нулевые границы
+// интервалов не ограничивают выборку; нижняя граница интервала включается, верхняя - нет.
+type OrderFilter struct {
+	Statuses      []OrderStatus
+	UploadedFrom  time.Time
+	UploadedTo    time.Time
+	ProcessedFrom time.Time
+	ProcessedTo   time.Time
+}
+
+// WithdrawalFilter отбирает списания пользователя по времени проведения так же, как OrderFilter
+type WithdrawalFilter struct {
+	ProcessedFrom time.Time
+	ProcessedTo   time.Time
+}
+
+// OrderCursor указывает на последний заказ страницы. Заказы упорядочены от новых к старым
+// по времени загрузки, при равном времени - по убыванию номера.
+type OrderCursor struct {
+	UploadedAt time.Time `json:"t"`
+	Number     string    `json:"n"`
+}
+
+// WithdrawalCursor указывает на последнее списание страницы. Списания упорядочены от новых
+// к старым по времени проведения, при равном времени - по убыванию идентификатора.
+type WithdrawalCursor struct {
+	ProcessedAt time.Time `json:"t"`
+	ID          int64     `json:"i"`
+}
+
+// OrderPage - страница списка заказов. NextCursor пуст на последней странице.
+type OrderPage struct {
+	Orders     []Order
+	NextCursor string
+}
+
+// WithdrawalPage - страница списка списаний. NextCursor пуст на последней странице.
+type WithdrawalPage struct {
+	Withdrawals []Withdrawal
+	NextCursor  string
+}
+
+// EncodeOrderCursor возвращает непрозрачный курсор страницы, следующей за заказом order
+func EncodeOrderCursor(order Order) string {
+	return encodeListCursor(OrderCursor{UploadedAt: order.UploadedAt, Number: order.Number})
+}
+
+// DecodeOrderCursor разбирает курсор, созданный EncodeOrderCursor. Пустой курсор означает
+// первую страницу, для него возвращается nil.
+func DecodeOrderCursor(cursor string) (*OrderCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	var c OrderCursor
+	if err := decodeListCursor(cursor, &c); err != nil || c.UploadedAt.IsZero() || c.Number == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// EncodeWithdrawalCursor возвращает непрозрачный курсор страницы, следующей за списанием w
+func EncodeWithdrawalCursor(w Withdrawal) string {
+	return encodeListCursor(WithdrawalCursor{ProcessedAt: w.ProcessedAt, ID: w.ID})
+}
+
+// DecodeWithdrawalCursor разбирает курсор, созданный EncodeWithdrawalCursor. Пустой курсор
+// означает первую страницу, для него возвращается nil.
+func DecodeWithdrawalCursor(cursor string) (*WithdrawalCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	var c WithdrawalCursor
+	if err := decodeListCursor(cursor, &c); err != nil || c.ProcessedAt.IsZero() || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// encodeListCursor кодирует позицию в списке в base64url от JSON
+func encodeListCursor(position interface{}) string {
+	raw, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeListCursor разбирает курсор, созданный encodeListCursor, в position
+func decodeListCursor(cursor string, position interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, position)
+}
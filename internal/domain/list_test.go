@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestDecodeOrderCursor(t *testing.T) {
+	uploadedAt := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    *OrderCursor
+		wantErr bool
+	}{
+		{name: "первая страница", cursor: ""},
+		{
+			name:   "курсор заказа",
+			cursor: EncodeOrderCursor(Order{Number: "12345678903", UploadedAt: uploadedAt}),
+			want:   &OrderCursor{UploadedAt: uploadedAt, Number: "12345678903"},
+		},
+		{name: "не base64", cursor: "!!!", wantErr: true},
+		{name: "не JSON", cursor: base64.RawURLEncoding.EncodeToString([]byte("42")), wantErr: true},
+		{name: "без номера", cursor: EncodeOrderCursor(Order{UploadedAt: uploadedAt}), wantErr: true},
+		{name: "курсор списания", cursor: EncodeWithdrawalCursor(Withdrawal{ID: 1, ProcessedAt: uploadedAt}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeOrderCursor(tt.cursor)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Errorf("expected ErrInvalidCursor, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (got == nil) != (tt.want == nil) ||
+				got != nil && (!got.UploadedAt.Equal(tt.want.UploadedAt) || got.Number != tt.want.Number) {
+				t.Errorf("DecodeOrderCursor(%q) = %+v, want %+v", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWithdrawalCursor(t *testing.T) {
+	processedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    *WithdrawalCursor
+		wantErr bool
+	}{
+		{name: "первая страница", cursor: ""},
+		{
+			name:   "курсор списания",
+			cursor: EncodeWithdrawalCursor(Withdrawal{ID: 7, ProcessedAt: processedAt}),
+			want:   &WithdrawalCursor{ProcessedAt: processedAt, ID: 7},
+		},
+		{name: "не base64", cursor: "!!!", wantErr: true},
+		{name: "без идентификатора", cursor: EncodeWithdrawalCursor(Withdrawal{ProcessedAt: processedAt}), wantErr: true},
+		{name: "курсор заказа", cursor: EncodeOrderCursor(Order{Number: "12345678903", UploadedAt: processedAt}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeWithdrawalCursor(tt.cursor)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Errorf("expected ErrInvalidCursor, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (got == nil) != (tt.want == nil) ||
+				got != nil && (!got.ProcessedAt.Equal(tt.want.ProcessedAt) || got.ID != tt.want.ID) {
+				t.Errorf("DecodeWithdrawalCursor(%q) = %+v, want %+v", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money - сумма баллов в копейках. Все расчёты ведутся в целых копейках, поэтому
+// сложение и сравнение сумм точны. В JSON сумма записывается числом в рублях,
+// как и раньше: 729.98, 500.
+type Money int64
+
+// moneyScale - число копеек в рубле
+const moneyScale = 100
+
+// ErrInvalidMoney возвращается при разборе суммы, которая не является числом
+// или содержит доли копейки
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Rubles возвращает сумму в целых рублях
+func Rubles(rubles int64) Money {
+	return Money(rubles * moneyScale)
+}
+
+// ParseMoney разбирает десятичную запись суммы в рублях. Доли копейки не допускаются:
+// сумма, присланная пользователем, должна быть точной.
+func ParseMoney(s string) (Money, error) {
+	value, err := parseRubles(s)
+	if err != nil {
+		return 0, err
+	}
+
+	value.Mul(value, big.NewRat(moneyScale, 1))
+	if !value.IsInt() {
+		return 0, fmt.Errorf("%w: %q has fractions of a kopeck", ErrInvalidMoney, s)
+	}
+	return moneyFromInt(value.Num(), s)
+}
+
+// RoundMoney разбирает десятичную запись суммы в рублях и округляет её до копейки
+// половиной от нуля: 0.005 -> 0.01, -0.005 -> -0.01. Применяется к суммам, пришедшим
+// от системы начислений, которая может прислать больше двух знаков после запятой.
+func RoundMoney(s string) (Money, error) {
+	value, err := parseRubles(s)
+	if err != nil {
+		return 0, err
+	}
+
+	value.Mul(value, big.NewRat(moneyScale, 1))
+	quo, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	// Остаток не меньше половины делителя округляется от нуля
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(int64(rem.Sign())))
+	}
+	return moneyFromInt(quo, s)
+}
+
+// maxMoneyExponent ограничивает порядок в экспоненциальной записи, чтобы
+// разбор "1e-999999999" не строил огромное число
+const maxMoneyExponent = 30
+
+// parseRubles разбирает десятичное число, в том числе в экспоненциальной записи
+func parseRubles(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	// big.Rat принимает и дроби вида "1/3", которые суммой не являются
+	if strings.Contains(s, "/") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > maxMoneyExponent || exp < -maxMoneyExponent {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+		}
+	}
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	return value, nil
+}
+
+// moneyFromInt проверяет, что число копеек помещается в Money
+func moneyFromInt(minor *big.Int, s string) (Money, error) {
+	if !minor.IsInt64() {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidMoney, s)
+	}
+	return Money(minor.Int64()), nil
+}
+
+// String возвращает сумму в рублях с двумя знаками после запятой: "729.98", "-0.50"
+func (m Money) String() string {
+	sign, minor := "", int64(m)
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/moneyScale, minor%moneyScale)
+}
+
+// MarshalJSON записывает сумму числом в рублях без незначащих нулей: 500, 729.9, 729.98
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := strings.TrimSuffix(strings.TrimRight(m.String(), "0"), ".")
+	return []byte(s), nil
+}
+
+// UnmarshalJSON разбирает сумму из JSON-числа без потери точности. Доли копейки
+// отклоняются, как и в ParseMoney.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
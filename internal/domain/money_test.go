@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{name: "целые рубли", value: "500", want: 50000},
+		{name: "рубли и копейки", value: "729.98", want: 72998},
+		{name: "один знак после запятой", value: "0.5", want: 50},
+		{name: "отрицательная сумма", value: "-12.30", want: -1230},
+		{name: "экспоненциальная запись", value: "1.5e2", want: 15000},
+		{name: "доли копейки", value: "751.123", wantErr: true},
+		{name: "не число", value: "abc", wantErr: true},
+		{name: "дробь", value: "1/3", wantErr: true},
+		{name: "слишком большой порядок", value: "1e-999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidMoney) {
+					t.Errorf("expected ErrInvalidMoney, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Money
+	}{
+		{value: "729.98", want: 72998},
+		{value: "729.985", want: 72999},
+		{value: "729.9849", want: 72998},
+		{value: "1.005", want: 101},
+		{value: "0.004", want: 0},
+		{value: "-0.005", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := RoundMoney(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RoundMoney(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: 50000, want: "500"},
+		{money: 72998, want: "729.98"},
+		{money: 72990, want: "729.9"},
+		{money: 5, want: "0.05"},
+		{money: 0, want: "0"},
+		{money: -1230, want: "-12.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			data, err := json.Marshal(tt.money)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal(%d) = %s, want %s", tt.money, data, tt.want)
+			}
+
+			var decoded Money
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decoded != tt.money {
+				t.Errorf("Unmarshal(%s) = %d, want %d", data, decoded, tt.money)
+			}
+		})
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`"500"`), &m); err == nil {
+		t.Error("expected error for string amount")
+	}
+	if err := json.Unmarshal([]byte(`751.123`), &m); err == nil {
+		t.Error("expected error for fractions of a kopeck")
+	}
+}
@@ -14,6 +14,8 @@ const (
 	StatusInvalid OrderStatus = "INVALID"
 	// StatusProcessed - данные по заказу проверены и информация о расчёте успешно получена
 	StatusProcessed OrderStatus = "PROCESSED"
+	// StatusStuck - попытки получить расчёт исчерпаны, заказ требует ручного разбора
+	StatusStuck OrderStatus = "STUCK"
 )
 
 // Order представляет информацию о заказе
@@ -21,7 +23,50 @@ type Order struct {
 	UserID      int64       `json:"-"`
 	Number      string      `json:"number"`
 	Status      OrderStatus `json:"status"`
-	Accrual     float64     `json:"accrual,omitempty"`
+	Accrual     Money       `json:"accrual,omitempty"`
 	UploadedAt  time.Time   `json:"uploaded_at"`
 	ProcessedAt *time.Time  `json:"-"`
+	// NextCheckAt - время следующего опроса системы начислений, если расчёт не окончен
+	NextCheckAt *time.Time `json:"next_check_at,omitempty"`
+	// LastError - причина, по которой заказ переведен в STUCK
+	LastError string `json:"-"`
+}
+
+// OrderStatusSource определяет, откуда пришло изменение статуса заказа
+type OrderStatusSource string
+
+const (
+	// StatusSourceUpload - заказ загружен пользователем
+	StatusSourceUpload OrderStatusSource = "upload"
+	// StatusSourcePoll - статус получен опросом системы начислений
+	StatusSourcePoll OrderStatusSource = "poll"
+	// StatusSourceCallback - статус получен из уведомления системы начислений
+	StatusSourceCallback OrderStatusSource = "callback"
+	// StatusSourceRetryPolicy - попытки опроса исчерпаны
+	StatusSourceRetryPolicy OrderStatusSource = "retry_policy"
+	// StatusSourceReconciliation - начисление исправлено при сверке
+	StatusSourceReconciliation OrderStatusSource = "reconciliation"
+	// StatusSourceAdmin - статус изменен администратором
+	StatusSourceAdmin OrderStatusSource = "admin"
+)
+
+// OrderStatusChange описывает применяемый к заказу результат расчёта
+type OrderStatusChange struct {
+	OrderNumber string
+	Status      OrderStatus
+	Accrual     Money
+	Source      OrderStatusSource
+	// Attempt - номер попытки опроса, на которой получен результат; 0, если не из опроса
+	Attempt int
+}
+
+// OrderStatusTransition представляет запись истории статусов заказа
+type OrderStatusTransition struct {
+	// From - предыдущий статус; пуст для первой записи
+	From      OrderStatus       `json:"from,omitempty"`
+	To        OrderStatus       `json:"to"`
+	Accrual   Money             `json:"accrual,omitempty"`
+	Source    OrderStatusSource `json:"source"`
+	Attempt   int               `json:"attempt,omitempty"`
+	ChangedAt time.Time         `json:"changed_at"`
 }
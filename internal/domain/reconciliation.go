@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// AccrualAdjustment представляет корректировку начисления по итогам сверки
+type AccrualAdjustment struct {
+	ID              int64
+	OrderNumber     string
+	UserID          int64
+	PreviousAccrual Money
+	NewAccrual      Money
+	// Amount - разница начислений: положительная зачисляется, отрицательная списывается
+	Amount Money
+	// Debt - часть списания, не покрытая балансом и учтенная как долг пользователя
+	Debt      Money
+	CreatedAt time.Time
+}
+
+// AccrualDiscrepancy описывает расхождение, найденное при сверке
+type AccrualDiscrepancy struct {
+	OrderNumber     string      `json:"order"`
+	UserID          int64       `json:"user_id"`
+	PreviousAccrual Money       `json:"previous_accrual"`
+	ReportedStatus  OrderStatus `json:"reported_status,omitempty"`
+	ReportedAccrual Money       `json:"reported_accrual"`
+	Adjustment      Money       `json:"adjustment"`
+	Debt            Money       `json:"debt,omitempty"`
+	// Note - почему расхождение не исправлено автоматически
+	Note string `json:"note,omitempty"`
+}
+
+// ProcessedOrderCursor указывает на последний заказ, прочитанный при сверке. Заказы сверяются
+// от новых к старым по времени обработки, при равном времени - по убыванию номера.
+type ProcessedOrderCursor struct {
+	ProcessedAt time.Time
+	Number      string
+}
+
+// ReconciliationReport представляет итоги одного прохода сверки начислений
+type ReconciliationReport struct {
+	StartedAt     time.Time            `json:"started_at"`
+	FinishedAt    time.Time            `json:"finished_at"`
+	Checked       int                  `json:"checked"`
+	Failed        int                  `json:"failed"`
+	Discrepancies []AccrualDiscrepancy `json:"discrepancies"`
+}
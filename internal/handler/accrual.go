@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// AccrualHandler обрабатывает служебные запросы интеграции с системой начислений
+type AccrualHandler struct {
+	status    AccrualStatusProvider
+	metrics   expvar.Var
+	decoder   AccrualCallbackDecoder
+	callbacks AccrualCallbackUseCase
+	reports   ReconciliationReporter
+}
+
+// maxCallbackBodySize ограничивает размер тела уведомления
+const maxCallbackBodySize = 64 << 10
+
+// NewAccrualHandler создает новый экземпляр AccrualHandler.
+// Если decoder равен nil, приём уведомлений отключен.
+func NewAccrualHandler(status AccrualStatusProvider, metrics expvar.Var, decoder AccrualCallbackDecoder, callbacks AccrualCallbackUseCase, reports ReconciliationReporter) *AccrualHandler {
+	return &AccrualHandler{
+		status:    status,
+		metrics:   metrics,
+		decoder:   decoder,
+		callbacks: callbacks,
+		reports:   reports,
+	}
+}
+
+// accrualStatusResponse представляет ответ о состоянии интеграции с системой начислений
+type accrualStatusResponse struct {
+	CircuitBreaker domain.CircuitBreakerStatus `json:"circuit_breaker"`
+}
+
+// GetStatus возвращает состояние автоматического выключателя системы начислений
+func (h *AccrualHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	resp := accrualStatusResponse{
+		CircuitBreaker: h.status.Status(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Failed to encode accrual status", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetMetrics возвращает счетчики по адресам системы начислений в формате expvar
+func (h *AccrualHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := "{}"
+	if h.metrics != nil {
+		metrics = h.metrics.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, "{\"accrual_endpoints\": %s}\n", metrics)
+}
+
+// Callback принимает уведомление системы начислений о результате расчёта
+func (h *AccrualHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if h.decoder == nil {
+		http.Error(w, "callbacks disabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBodySize))
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	order, err := h.decoder.Decode(r.Header, body)
+	if err != nil {
+		logger.Warn("Rejected accrual callback", zap.Error(err))
+		switch {
+		case errors.Is(err, domain.ErrInvalidSignature):
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		default:
+			http.Error(w, "invalid notification", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if err := h.callbacks.ApplyAccrualCallback(r.Context(), order); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrOrderNotFound):
+			http.Error(w, "order not found", http.StatusNotFound)
+		default:
+			logger.Error("Failed to apply accrual callback",
+				zap.Error(err),
+				zap.String("order", order.Number))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetReconciliationReport возвращает отчет последней сверки начислений
+func (h *AccrualHandler) GetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reports.LastReconciliationReport(r.Context())
+	if err != nil {
+		if errors.Is(err, domain.ErrReconciliationReportNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Error("Failed to encode reconciliation report", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gophermart/internal/domain"
+)
+
+// statusProviderFunc адаптирует функцию к интерфейсу AccrualStatusProvider
+type statusProviderFunc func() domain.CircuitBreakerStatus
+
+func (f statusProviderFunc) Status() domain.CircuitBreakerStatus {
+	return f()
+}
+
+// callbackDecoderFunc адаптирует функцию к интерфейсу AccrualCallbackDecoder
+type callbackDecoderFunc func(header http.Header, body []byte) (*domain.Order, error)
+
+func (f callbackDecoderFunc) Decode(header http.Header, body []byte) (*domain.Order, error) {
+	return f(header, body)
+}
+
+// callbackUseCaseFunc адаптирует функцию к интерфейсу AccrualCallbackUseCase
+type callbackUseCaseFunc func(ctx context.Context, order *domain.Order) error
+
+func (f callbackUseCaseFunc) ApplyAccrualCallback(ctx context.Context, order *domain.Order) error {
+	return f(ctx, order)
+}
+
+// reconciliationReporterFunc адаптирует функцию к интерфейсу ReconciliationReporter
+type reconciliationReporterFunc func(ctx context.Context) (*domain.ReconciliationReport, error)
+
+func (f reconciliationReporterFunc) LastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error) {
+	return f(ctx)
+}
+
+func TestAccrualHandler_GetStatus(t *testing.T) {
+	handler := NewAccrualHandler(statusProviderFunc(func() domain.CircuitBreakerStatus {
+		return domain.CircuitBreakerStatus{
+			State:               domain.CircuitOpen,
+			ConsecutiveFailures: 5,
+			LastError:           "connection refused",
+		}
+	}), nil, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/accrual/status", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetStatus(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp accrualStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	if resp.CircuitBreaker.State != domain.CircuitOpen {
+		t.Errorf("Expected state %s, got %s", domain.CircuitOpen, resp.CircuitBreaker.State)
+	}
+	if resp.CircuitBreaker.ConsecutiveFailures != 5 {
+		t.Errorf("Expected 5 failures, got %d", resp.CircuitBreaker.ConsecutiveFailures)
+	}
+}
+
+func TestAccrualHandler_GetMetrics(t *testing.T) {
+	metrics := new(expvar.Map).Init()
+	metrics.Add("http://primary", 3)
+
+	handler := NewAccrualHandler(nil, metrics, nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/accrual/metrics", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp map[string]map[string]int
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	if len(resp) != 1 || resp["accrual_endpoints"]["http://primary"] != 3 {
+		t.Errorf("Expected only accrual endpoint counters, got %v", resp)
+	}
+}
+func TestAccrualHandler_Callback(t *testing.T) {
+	processed := &domain.Order{Number: "12345678903", Status: domain.StatusProcessed, Accrual: domain.Rubles(500)}
+
+	tests := []struct {
+		name         string
+		decoder      AccrualCallbackDecoder
+		applyErr     error
+		expectedCode int
+		expectApply  bool
+	}{
+		{
+			name: "Успешное уведомление",
+			decoder: callbackDecoderFunc(func(header http.Header, body []byte) (*domain.Order, error) {
+				return processed, nil
+			}),
+			expectedCode: http.StatusOK,
+			expectApply:  true,
+		},
+		{
+			name:         "Уведомления отключены",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "Неверная подпись",
+			decoder: callbackDecoderFunc(func(header http.Header, body []byte) (*domain.Order, error) {
+				return nil, domain.ErrInvalidSignature
+			}),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Некорректное тело",
+			decoder: callbackDecoderFunc(func(header http.Header, body []byte) (*domain.Order, error) {
+				return nil, domain.NewAccrualResponseError("", domain.ErrAccrualMalformedBody, "bad json")
+			}),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Неизвестный заказ",
+			decoder: callbackDecoderFunc(func(header http.Header, body []byte) (*domain.Order, error) {
+				return processed, nil
+			}),
+			applyErr:     domain.ErrOrderNotFound,
+			expectedCode: http.StatusNotFound,
+			expectApply:  true,
+		},
+		{
+			name: "Ошибка хранилища",
+			decoder: callbackDecoderFunc(func(header http.Header, body []byte) (*domain.Order, error) {
+				return processed, nil
+			}),
+			applyErr:     errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectApply:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied bool
+			callbacks := callbackUseCaseFunc(func(ctx context.Context, order *domain.Order) error {
+				applied = true
+				return tt.applyErr
+			})
+			handler := NewAccrualHandler(nil, nil, tt.decoder, callbacks, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/internal/accrual/callback",
+				strings.NewReader(`{"order":"12345678903","status":"PROCESSED","accrual":500}`))
+			w := httptest.NewRecorder()
+
+			handler.Callback(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if applied != tt.expectApply {
+				t.Errorf("Expected apply called %v, got %v", tt.expectApply, applied)
+			}
+		})
+	}
+}
+
+func TestAccrualHandler_GetReconciliationReport(t *testing.T) {
+	tests := []struct {
+		name         string
+		report       *domain.ReconciliationReport
+		reportErr    error
+		expectedCode int
+	}{
+		{
+			name:         "Отчет последней сверки",
+			report:       &domain.ReconciliationReport{Checked: 3, Discrepancies: []domain.AccrualDiscrepancy{}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Сверка еще не выполнялась",
+			reportErr:    domain.ErrReconciliationReportNotFound,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Ошибка хранилища",
+			reportErr:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAccrualHandler(nil, nil, nil, nil,
+				reconciliationReporterFunc(func(ctx context.Context) (*domain.ReconciliationReport, error) {
+					return tt.report, tt.reportErr
+				}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/accrual/reconciliation", nil)
+			w := httptest.NewRecorder()
+
+			handler.GetReconciliationReport(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var resp domain.ReconciliationReport
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response body: %v", err)
+			}
+			if resp.Checked != tt.report.Checked {
+				t.Errorf("Expected %d checked orders, got %d", tt.report.Checked, resp.Checked)
+			}
+		})
+	}
+}
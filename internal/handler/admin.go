@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const adminActorKey contextKey = "admin_actor"
+
+// AdminActorHeader задает имя администратора для журнала операций
+const AdminActorHeader = "X-Admin-Actor"
+
+// AdminHandler обрабатывает административные операции с заказами
+type AdminHandler struct {
+	admin AdminUseCase
+	token string
+}
+
+// NewAdminHandler создает новый экземпляр AdminHandler.
+// Пустой token отключает административные операции.
+func NewAdminHandler(admin AdminUseCase, token string) *AdminHandler {
+	return &AdminHandler{
+		admin: admin,
+		token: token,
+	}
+}
+
+// AdminMiddleware проверяет административный токен и добавляет имя администратора в контекст
+func (h *AdminHandler) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			http.Error(w, "admin api disabled", http.StatusNotFound)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			logger.Warn("Invalid admin token", zap.String("path", r.URL.Path))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		actor := r.Header.Get(AdminActorHeader)
+		if actor == "" {
+			actor = "admin"
+		}
+
+		ctx := context.WithValue(r.Context(), adminActorKey, actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requeueRequest представляет запрос на возврат заказа в очередь
+type requeueRequest struct {
+	Reason string `json:"reason"`
+}
+
+// forceStatusRequest представляет запрос на ручную установку статуса
+type forceStatusRequest struct {
+	Status  domain.OrderStatus `json:"status"`
+	Accrual domain.Money       `json:"accrual"`
+	Reason  string             `json:"reason"`
+}
+
+// bulkRequeueRequest представляет запрос на массовый возврат заказов в очередь
+type bulkRequeueRequest struct {
+	Status domain.OrderStatus `json:"status"`
+	From   time.Time          `json:"from"`
+	To     time.Time          `json:"to"`
+	Reason string             `json:"reason"`
+}
+
+// bulkRequeueResponse представляет результат массового возврата в очередь
+type bulkRequeueResponse struct {
+	Requeued int `json:"requeued"`
+}
+
+// RequeueOrder возвращает заказ в очередь опроса
+func (h *AdminHandler) RequeueOrder(w http.ResponseWriter, r *http.Request) {
+	var req requeueRequest
+	if err := decodeOptionalJSON(r, &req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	err := h.admin.RequeueOrder(r.Context(), adminActor(r), chi.URLParam(r, "number"), req.Reason)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ForceOrderStatus вручную устанавливает окончательный статус заказа
+func (h *AdminHandler) ForceOrderStatus(w http.ResponseWriter, r *http.Request) {
+	var req forceStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	err := h.admin.ForceOrderStatus(r.Context(), adminActor(r), chi.URLParam(r, "number"), req.Status, req.Accrual, req.Reason)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RequeueOrders возвращает в очередь заказы по статусу и интервалу загрузки
+func (h *AdminHandler) RequeueOrders(w http.ResponseWriter, r *http.Request) {
+	var req bulkRequeueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	filter := domain.RequeueFilter{
+		Status:       req.Status,
+		UploadedFrom: req.From,
+		UploadedTo:   req.To,
+	}
+	count, err := h.admin.RequeueOrders(r.Context(), adminActor(r), filter, req.Reason)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(bulkRequeueResponse{Requeued: count}); err != nil {
+		logger.Error("Failed to encode requeue result", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// adminActor возвращает имя администратора, установленное AdminMiddleware
+func adminActor(r *http.Request) string {
+	actor, _ := r.Context().Value(adminActorKey).(string)
+	return actor
+}
+
+// decodeOptionalJSON разбирает тело запроса, допуская его отсутствие
+func decodeOptionalJSON(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// writeAdminError переводит ошибку административной операции в HTTP-ответ
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrOrderNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrOrderAlreadyProcessed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, domain.ErrReasonRequired),
+		errors.Is(err, domain.ErrInvalidOrderStatus),
+		errors.Is(err, domain.ErrInvalidAmount):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		logger.Error("Admin operation failed", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/handler/mocks"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newAdminRouter собирает маршруты административного API для тестов
+func newAdminRouter(h *AdminHandler) chi.Router {
+	r := chi.NewRouter()
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(h.AdminMiddleware)
+		r.Post("/orders/requeue", h.RequeueOrders)
+		r.Post("/orders/{number}/requeue", h.RequeueOrder)
+		r.Post("/orders/{number}/status", h.ForceOrderStatus)
+	})
+	return r
+}
+
+func TestAdminHandler_Middleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		header       string
+		expectedCode int
+	}{
+		{
+			name:         "Административный API отключен",
+			header:       "Bearer secret",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Без токена",
+			token:        "secret",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Неверный токен",
+			token:        "secret",
+			header:       "Bearer other",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Верный токен",
+			token:        "secret",
+			header:       "Bearer secret",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actor string
+			admin := &mocks.MockAdminUseCase{
+				RequeueOrderFunc: func(ctx context.Context, a, orderNumber, reason string) error {
+					actor = a
+					return nil
+				},
+			}
+			r := newAdminRouter(NewAdminHandler(admin, tt.token))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/orders/12345678903/requeue", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			req.Header.Set(AdminActorHeader, "support")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode == http.StatusOK && actor != "support" {
+				t.Errorf("Expected actor support, got %q", actor)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_Operations(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		forceErr     error
+		expectedCode int
+	}{
+		{
+			name:         "Ручная установка статуса",
+			path:         "/api/admin/orders/12345678903/status",
+			body:         `{"status":"PROCESSED","accrual":500,"reason":"confirmed by partner"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Ручная установка статуса без причины",
+			path:         "/api/admin/orders/12345678903/status",
+			body:         `{"status":"PROCESSED","accrual":500}`,
+			forceErr:     domain.ErrReasonRequired,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Ручная установка статуса начисленного заказа",
+			path:         "/api/admin/orders/12345678903/status",
+			body:         `{"status":"INVALID","reason":"fraud"}`,
+			forceErr:     domain.ErrOrderAlreadyProcessed,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Ручная установка статуса неизвестного заказа",
+			path:         "/api/admin/orders/12345678903/status",
+			body:         `{"status":"INVALID","reason":"fraud"}`,
+			forceErr:     domain.ErrOrderNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Массовый возврат в очередь",
+			path:         "/api/admin/orders/requeue",
+			body:         `{"status":"STUCK","from":"2024-01-01T00:00:00Z","reason":"accrual outage"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Некорректное тело",
+			path:         "/api/admin/orders/requeue",
+			body:         `{`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin := &mocks.MockAdminUseCase{
+				ForceOrderStatusFunc: func(ctx context.Context, actor, orderNumber string, status domain.OrderStatus, accrual domain.Money, reason string) error {
+					return tt.forceErr
+				},
+				RequeueOrdersFunc: func(ctx context.Context, actor string, filter domain.RequeueFilter, reason string) (int, error) {
+					if filter.Status != domain.StatusStuck || filter.UploadedFrom.IsZero() || !filter.UploadedTo.IsZero() {
+						t.Errorf("Unexpected filter %+v", filter)
+					}
+					return 3, nil
+				},
+			}
+			r := newAdminRouter(NewAdminHandler(admin, "secret"))
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.path == "/api/admin/orders/requeue" && w.Code == http.StatusOK {
+				var resp bulkRequeueResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Requeued != 3 {
+					t.Errorf("Expected 3 requeued orders, got %+v, %v", resp, err)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"gophermart/internal/domain"
@@ -46,7 +47,18 @@ func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Withdraw обрабатывает запрос на списание баллов
+// IdempotencyKeyHeader задает ключ идемпотентности запроса на списание
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader отмечает ответ, повторяющий результат проведенного ранее списания
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength - наибольшая длина ключа идемпотентности
+const maxIdempotencyKeyLength = 255
+
+// Withdraw обрабатывает запрос на списание баллов. Повтор запроса с тем же ключом
+// идемпотентности или по тому же заказу с теми же параметрами возвращает исходное списание.
+// Отказ 402 или 409 запоминается с ключом: новая попытка требует нового ключа.
 func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(int64)
 	if !ok {
@@ -55,6 +67,12 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		http.Error(w, "idempotency key too long", http.StatusBadRequest)
+		return
+	}
+
 	var withdrawal domain.WithdrawalRequest
 	if err := json.NewDecoder(r.Body).Decode(&withdrawal); err != nil {
 		logger.Error("Failed to decode withdrawal request", zap.Error(err))
@@ -62,13 +80,17 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.balanceUseCase.Withdraw(r.Context(), userID, withdrawal)
+	created, err := h.balanceUseCase.Withdraw(r.Context(), userID, withdrawal, idempotencyKey)
 	if err != nil {
 		switch err {
 		case domain.ErrInvalidOrderNumber:
 			http.Error(w, "invalid order number", http.StatusUnprocessableEntity)
 		case domain.ErrInsufficientFunds:
 			http.Error(w, "insufficient funds", http.StatusPaymentRequired)
+		case domain.ErrWithdrawalConflict:
+			http.Error(w, "withdrawal for order already exists", http.StatusConflict)
+		case domain.ErrIdempotencyKeyReused:
+			http.Error(w, "idempotency key reused with different request", http.StatusUnprocessableEntity)
 		default:
 			logger.Error("Failed to process withdrawal", zap.Error(err))
 			http.Error(w, "internal error", http.StatusInternalServerError)
@@ -76,10 +98,20 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	if created.Replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		logger.Error("Failed to encode withdrawal", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
 }
 
-// GetWithdrawals возвращает историю списаний
+// GetWithdrawals возвращает историю списаний постранично. Страница задается параметрами
+// limit и cursor, без них список отдается целиком; интервал времени проведения задают
+// processed_from и processed_to. Адрес следующей страницы возвращается в заголовке Link.
 func (h *BalanceHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(int64)
 	if !ok {
@@ -88,22 +120,86 @@ func (h *BalanceHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	withdrawals, err := h.balanceUseCase.GetWithdrawals(r.Context(), userID)
+	query := r.URL.Query()
+	limit, err := parseLimit(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var filter domain.WithdrawalFilter
+	err = parseTimeParams(query,
+		timeParam{name: "processed_from", dst: &filter.ProcessedFrom},
+		timeParam{name: "processed_to", dst: &filter.ProcessedTo},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.balanceUseCase.GetWithdrawals(r.Context(), userID, filter, query.Get("cursor"), limit)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
 		logger.Error("Failed to get withdrawals", zap.Error(err))
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	if len(withdrawals) == 0 {
+	if len(page.Withdrawals) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	if page.NextCursor != "" {
+		setNextPageLink(w, r, page.NextCursor)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(withdrawals); err != nil {
+	if err := json.NewEncoder(w).Encode(page.Withdrawals); err != nil {
 		logger.Error("Failed to encode withdrawals", zap.Error(err))
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 }
+
+// GetTransactions возвращает выписку операций с баллами постранично. Страница задается
+// параметрами limit и cursor; курсор следующей страницы возвращается в next_cursor.
+func (h *BalanceHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int64)
+	if !ok {
+		logger.Error("Failed to get user ID from context")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := parseLimit(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.balanceUseCase.GetTransactions(r.Context(), userID, query.Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		logger.Error("Failed to get transactions", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(page.Transactions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		logger.Error("Failed to encode transactions", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/handler/mocks"
+)
+
+func TestBalanceHandler_GetTransactions(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		page          *domain.TransactionPage
+		useCaseErr    error
+		wantCursor    string
+		wantLimit     int
+		expectedCode  int
+		expectedCount int
+		expectedNext  string
+	}{
+		{
+			name:  "Страница с продолжением",
+			query: "?limit=2&cursor=" + domain.EncodeCursor(10),
+			page: &domain.TransactionPage{
+				Transactions: []domain.Transaction{
+					{ID: 9, Kind: domain.LedgerAccrual, OrderNumber: "12345678903", Amount: domain.Rubles(500)},
+					{ID: 8, Kind: domain.LedgerWithdrawal, OrderNumber: "2377225624", Amount: domain.Rubles(-100)},
+				},
+				NextCursor: domain.EncodeCursor(8),
+			},
+			wantCursor:    domain.EncodeCursor(10),
+			wantLimit:     2,
+			expectedCode:  http.StatusOK,
+			expectedCount: 2,
+			expectedNext:  domain.EncodeCursor(8),
+		},
+		{
+			name:         "Нет операций",
+			page:         &domain.TransactionPage{},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Неверный размер страницы",
+			query:        "?limit=-1",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Неверный курсор",
+			query:        "?cursor=bad",
+			wantCursor:   "bad",
+			useCaseErr:   domain.ErrInvalidCursor,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Внутренняя ошибка",
+			useCaseErr:   errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := &mocks.MockBalanceUseCase{
+				GetTransactionsFunc: func(ctx context.Context, userID int64, cursor string, limit int) (*domain.TransactionPage, error) {
+					if userID != 1 || cursor != tt.wantCursor || limit != tt.wantLimit {
+						t.Errorf("Unexpected transactions request: user %d, cursor %q, limit %d", userID, cursor, limit)
+					}
+					return tt.page, tt.useCaseErr
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/transactions"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, int64(1)))
+			w := httptest.NewRecorder()
+
+			NewBalanceHandler(mockUseCase).GetTransactions(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var resp domain.TransactionPage
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response body: %v", err)
+			}
+			if len(resp.Transactions) != tt.expectedCount {
+				t.Errorf("Expected %d transactions, got %d", tt.expectedCount, len(resp.Transactions))
+			}
+			if resp.NextCursor != tt.expectedNext {
+				t.Errorf("Expected next cursor %q, got %q", tt.expectedNext, resp.NextCursor)
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_GetWithdrawals(t *testing.T) {
+	processedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	next := domain.EncodeWithdrawalCursor(domain.Withdrawal{ID: 5, ProcessedAt: processedAt})
+
+	tests := []struct {
+		name         string
+		query        string
+		page         *domain.WithdrawalPage
+		useCaseErr   error
+		wantFilter   domain.WithdrawalFilter
+		wantCursor   string
+		wantLimit    int
+		expectedCode int
+		expectedLink string
+	}{
+		{
+			name:  "Страница с продолжением",
+			query: "?limit=1&processed_from=2024-03-01",
+			page: &domain.WithdrawalPage{
+				Withdrawals: []domain.Withdrawal{{ID: 5, OrderNumber: "2377225624", Sum: domain.Rubles(100), ProcessedAt: processedAt}},
+				NextCursor:  next,
+			},
+			wantFilter:   domain.WithdrawalFilter{ProcessedFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+			wantLimit:    1,
+			expectedCode: http.StatusOK,
+			expectedLink: "</api/user/withdrawals?cursor=" + next + "&limit=1&processed_from=2024-03-01>; rel=\"next\"",
+		},
+		{
+			name:         "Нет списаний",
+			page:         &domain.WithdrawalPage{},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Неверная дата",
+			query:        "?processed_to=tomorrow",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Неверный курсор",
+			query:        "?cursor=bad",
+			wantCursor:   "bad",
+			useCaseErr:   domain.ErrInvalidCursor,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Внутренняя ошибка",
+			useCaseErr:   errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := &mocks.MockBalanceUseCase{
+				GetWithdrawalsFunc: func(ctx context.Context, userID int64, filter domain.WithdrawalFilter, cursor string, limit int) (*domain.WithdrawalPage, error) {
+					if userID != 1 || filter != tt.wantFilter || cursor != tt.wantCursor || limit != tt.wantLimit {
+						t.Errorf("Unexpected withdrawals request: filter %+v, cursor %q, limit %d", filter, cursor, limit)
+					}
+					return tt.page, tt.useCaseErr
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, int64(1)))
+			w := httptest.NewRecorder()
+
+			NewBalanceHandler(mockUseCase).GetWithdrawals(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if link := w.Header().Get("Link"); link != tt.expectedLink {
+				t.Errorf("Expected Link %q, got %q", tt.expectedLink, link)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var withdrawals []domain.Withdrawal
+			if err := json.NewDecoder(w.Body).Decode(&withdrawals); err != nil {
+				t.Fatalf("Failed to decode response body: %v", err)
+			}
+			if len(withdrawals) != len(tt.page.Withdrawals) {
+				t.Errorf("Expected %d withdrawals, got %d", len(tt.page.Withdrawals), len(withdrawals))
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_Withdraw(t *testing.T) {
+	tests := []struct {
+		name           string
+		idempotencyKey string
+		withdrawErr    error
+		replayed       bool
+		expectedCode   int
+		wantReplayed   bool
+	}{
+		{
+			name:           "Списание проведено",
+			idempotencyKey: "key-1",
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:           "Повтор запроса",
+			idempotencyKey: "key-1",
+			replayed:       true,
+			expectedCode:   http.StatusOK,
+			wantReplayed:   true,
+		},
+		{
+			name:         "Списание по заказу с другими параметрами",
+			withdrawErr:  domain.ErrWithdrawalConflict,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:           "Ключ использован для другого запроса",
+			idempotencyKey: "key-1",
+			withdrawErr:    domain.ErrIdempotencyKeyReused,
+			expectedCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Слишком длинный ключ",
+			idempotencyKey: strings.Repeat("k", maxIdempotencyKeyLength+1),
+			expectedCode:   http.StatusBadRequest,
+		},
+		{
+			name:         "Недостаточно средств",
+			withdrawErr:  domain.ErrInsufficientFunds,
+			expectedCode: http.StatusPaymentRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := &mocks.MockBalanceUseCase{
+				WithdrawFunc: func(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest, idempotencyKey string) (*domain.Withdrawal, error) {
+					if idempotencyKey != tt.idempotencyKey {
+						t.Errorf("Expected idempotency key %q, got %q", tt.idempotencyKey, idempotencyKey)
+					}
+					if tt.withdrawErr != nil {
+						return nil, tt.withdrawErr
+					}
+					return &domain.Withdrawal{
+						ID:          7,
+						OrderNumber: withdrawal.Order,
+						Sum:         withdrawal.Sum,
+						Replayed:    tt.replayed,
+					}, nil
+				},
+			}
+
+			body := strings.NewReader(`{"order":"2377225624","sum":751}`)
+			req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", body)
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, int64(1)))
+			if tt.idempotencyKey != "" {
+				req.Header.Set(IdempotencyKeyHeader, tt.idempotencyKey)
+			}
+			w := httptest.NewRecorder()
+
+			NewBalanceHandler(mockUseCase).Withdraw(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			if replayed := w.Header().Get(IdempotentReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("Expected replayed header %v, got %v", tt.wantReplayed, replayed)
+			}
+			var resp domain.Withdrawal
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response body: %v", err)
+			}
+			if resp.ID != 7 || resp.OrderNumber != "2377225624" || resp.Sum != domain.Rubles(751) {
+				t.Errorf("Unexpected withdrawal in response: %+v", resp)
+			}
+		})
+	}
+}
@@ -5,13 +5,17 @@ type Handler struct {
 	auth    *AuthHandler
 	order   *OrderHandler
 	balance *BalanceHandler
+	accrual *AccrualHandler
+	admin   *AdminHandler
 }
 
 // NewHandler создает новый экземпляр Handler
-func NewHandler(auth *AuthHandler, order *OrderHandler, balance *BalanceHandler) *Handler {
+func NewHandler(auth *AuthHandler, order *OrderHandler, balance *BalanceHandler, accrual *AccrualHandler, admin *AdminHandler) *Handler {
 	return &Handler{
 		auth:    auth,
 		order:   order,
 		balance: balance,
+		accrual: accrual,
+		admin:   admin,
 	}
 }
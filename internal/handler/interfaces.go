@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"net/http"
 
 	"gophermart/internal/domain"
 )
@@ -15,14 +16,44 @@ type UserUseCase interface {
 // OrderUseCase определяет интерфейс для бизнес-логики работы с заказами
 type OrderUseCase interface {
 	UploadOrder(ctx context.Context, userID int64, orderNumber string) error
-	GetUserOrders(ctx context.Context, userID int64) ([]domain.Order, error)
+	GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error)
+	GetUserOrder(ctx context.Context, userID int64, orderNumber string) (*domain.Order, error)
+	GetOrderHistory(ctx context.Context, userID int64, orderNumber string) ([]domain.OrderStatusTransition, error)
 }
 
 // BalanceUseCase определяет интерфейс для бизнес-логики работы с балансом
 type BalanceUseCase interface {
 	GetBalance(ctx context.Context, userID int64) (*domain.Balance, error)
-	Withdraw(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest) error
-	GetWithdrawals(ctx context.Context, userID int64) ([]domain.Withdrawal, error)
+	Withdraw(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest, idempotencyKey string) (*domain.Withdrawal, error)
+	GetWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, cursor string, limit int) (*domain.WithdrawalPage, error)
+	GetTransactions(ctx context.Context, userID int64, cursor string, limit int) (*domain.TransactionPage, error)
+}
+
+// AdminUseCase определяет административные операции с заказами
+type AdminUseCase interface {
+	RequeueOrder(ctx context.Context, actor, orderNumber, reason string) error
+	RequeueOrders(ctx context.Context, actor string, filter domain.RequeueFilter, reason string) (int, error)
+	ForceOrderStatus(ctx context.Context, actor, orderNumber string, status domain.OrderStatus, accrual domain.Money, reason string) error
+}
+
+// AccrualStatusProvider предоставляет состояние интеграции с системой начислений
+type AccrualStatusProvider interface {
+	Status() domain.CircuitBreakerStatus
+}
+
+// AccrualCallbackDecoder проверяет подпись уведомления системы начислений и разбирает его
+type AccrualCallbackDecoder interface {
+	Decode(header http.Header, body []byte) (*domain.Order, error)
+}
+
+// AccrualCallbackUseCase применяет результаты расчёта из уведомлений
+type AccrualCallbackUseCase interface {
+	ApplyAccrualCallback(ctx context.Context, order *domain.Order) error
+}
+
+// ReconciliationReporter предоставляет отчет последней сверки начислений
+type ReconciliationReporter interface {
+	LastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error)
 }
 
 // AuthMiddleware определяет интерфейс для middleware аутентификации
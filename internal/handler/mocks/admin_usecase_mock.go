@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"context"
+
+	"gophermart/internal/domain"
+)
+
+// MockAdminUseCase мок для AdminUseCase
+type MockAdminUseCase struct {
+	RequeueOrderFunc     func(ctx context.Context, actor, orderNumber, reason string) error
+	RequeueOrdersFunc    func(ctx context.Context, actor string, filter domain.RequeueFilter, reason string) (int, error)
+	ForceOrderStatusFunc func(ctx context.Context, actor, orderNumber string, status domain.OrderStatus, accrual domain.Money, reason string) error
+}
+
+func (m *MockAdminUseCase) RequeueOrder(ctx context.Context, actor, orderNumber, reason string) error {
+	if m.RequeueOrderFunc != nil {
+		return m.RequeueOrderFunc(ctx, actor, orderNumber, reason)
+	}
+	return nil
+}
+
+func (m *MockAdminUseCase) RequeueOrders(ctx context.Context, actor string, filter domain.RequeueFilter, reason string) (int, error) {
+	if m.RequeueOrdersFunc != nil {
+		return m.RequeueOrdersFunc(ctx, actor, filter, reason)
+	}
+	return 0, nil
+}
+
+func (m *MockAdminUseCase) ForceOrderStatus(ctx context.Context, actor, orderNumber string, status domain.OrderStatus, accrual domain.Money, reason string) error {
+	if m.ForceOrderStatusFunc != nil {
+		return m.ForceOrderStatusFunc(ctx, actor, orderNumber, status, accrual, reason)
+	}
+	return nil
+}
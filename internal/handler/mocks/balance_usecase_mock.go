@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"context"
+
+	"gophermart/internal/domain"
+)
+
+// MockBalanceUseCase мок для BalanceUseCase
+type MockBalanceUseCase struct {
+	GetBalanceFunc      func(ctx context.Context, userID int64) (*domain.Balance, error)
+	WithdrawFunc        func(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest, idempotencyKey string) (*domain.Withdrawal, error)
+	GetWithdrawalsFunc  func(ctx context.Context, userID int64, filter domain.WithdrawalFilter, cursor string, limit int) (*domain.WithdrawalPage, error)
+	GetTransactionsFunc func(ctx context.Context, userID int64, cursor string, limit int) (*domain.TransactionPage, error)
+}
+
+func (m *MockBalanceUseCase) GetBalance(ctx context.Context, userID int64) (*domain.Balance, error) {
+	if m.GetBalanceFunc != nil {
+		return m.GetBalanceFunc(ctx, userID)
+	}
+	return &domain.Balance{}, nil
+}
+
+func (m *MockBalanceUseCase) Withdraw(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest, idempotencyKey string) (*domain.Withdrawal, error) {
+	if m.WithdrawFunc != nil {
+		return m.WithdrawFunc(ctx, userID, withdrawal, idempotencyKey)
+	}
+	return &domain.Withdrawal{OrderNumber: withdrawal.Order, Sum: withdrawal.Sum}, nil
+}
+
+func (m *MockBalanceUseCase) GetWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, cursor string, limit int) (*domain.WithdrawalPage, error) {
+	if m.GetWithdrawalsFunc != nil {
+		return m.GetWithdrawalsFunc(ctx, userID, filter, cursor, limit)
+	}
+	return &domain.WithdrawalPage{}, nil
+}
+
+func (m *MockBalanceUseCase) GetTransactions(ctx context.Context, userID int64, cursor string, limit int) (*domain.TransactionPage, error) {
+	if m.GetTransactionsFunc != nil {
+		return m.GetTransactionsFunc(ctx, userID, cursor, limit)
+	}
+	return &domain.TransactionPage{}, nil
+}
@@ -7,12 +7,14 @@ import (
 
 // MockOrderUseCase мок для OrderUseCase
 type MockOrderUseCase struct {
-	UploadOrderFunc    func(ctx context.Context, userID int64, orderNumber string) error
-	GetUserOrdersFunc  func(ctx context.Context, userID int64) ([]domain.Order, error)
-	GetBalanceFunc     func(ctx context.Context, userID int64) (*domain.Balance, error)
-	WithdrawFunc       func(ctx context.Context, userID int64, orderNumber string, sum float64) error
-	GetWithdrawalsFunc func(ctx context.Context, userID int64) ([]domain.Withdrawal, error)
-	ShutdownFunc       func(ctx context.Context)
+	UploadOrderFunc     func(ctx context.Context, userID int64, orderNumber string) error
+	GetUserOrdersFunc   func(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error)
+	GetUserOrderFunc    func(ctx context.Context, userID int64, orderNumber string) (*domain.Order, error)
+	GetOrderHistoryFunc func(ctx context.Context, userID int64, orderNumber string) ([]domain.OrderStatusTransition, error)
+	GetBalanceFunc      func(ctx context.Context, userID int64) (*domain.Balance, error)
+	WithdrawFunc        func(ctx context.Context, userID int64, orderNumber string, sum domain.Money) error
+	GetWithdrawalsFunc  func(ctx context.Context, userID int64) ([]domain.Withdrawal, error)
+	ShutdownFunc        func(ctx context.Context)
 }
 
 func (m *MockOrderUseCase) UploadOrder(ctx context.Context, userID int64, orderNumber string) error {
@@ -22,9 +24,23 @@ func (m *MockOrderUseCase) UploadOrder(ctx context.Context, userID int64, orderN
 	return nil
 }
 
-func (m *MockOrderUseCase) GetUserOrders(ctx context.Context, userID int64) ([]domain.Order, error) {
+func (m *MockOrderUseCase) GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error) {
 	if m.GetUserOrdersFunc != nil {
-		return m.GetUserOrdersFunc(ctx, userID)
+		return m.GetUserOrdersFunc(ctx, userID, filter, cursor, limit)
+	}
+	return &domain.OrderPage{}, nil
+}
+
+func (m *MockOrderUseCase) GetUserOrder(ctx context.Context, userID int64, orderNumber string) (*domain.Order, error) {
+	if m.GetUserOrderFunc != nil {
+		return m.GetUserOrderFunc(ctx, userID, orderNumber)
+	}
+	return nil, domain.ErrOrderNotFound
+}
+
+func (m *MockOrderUseCase) GetOrderHistory(ctx context.Context, userID int64, orderNumber string) ([]domain.OrderStatusTransition, error) {
+	if m.GetOrderHistoryFunc != nil {
+		return m.GetOrderHistoryFunc(ctx, userID, orderNumber)
 	}
 	return nil, nil
 }
@@ -36,7 +52,7 @@ func (m *MockOrderUseCase) GetBalance(ctx context.Context, userID int64) (*domai
 	return nil, nil
 }
 
-func (m *MockOrderUseCase) Withdraw(ctx context.Context, userID int64, orderNumber string, sum float64) error {
+func (m *MockOrderUseCase) Withdraw(ctx context.Context, userID int64, orderNumber string, sum domain.Money) error {
 	if m.WithdrawFunc != nil {
 		return m.WithdrawFunc(ctx, userID, orderNumber, sum)
 	}
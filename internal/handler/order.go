@@ -2,12 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"gophermart/internal/domain"
 	"gophermart/internal/logger"
 
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
@@ -53,12 +57,12 @@ func (h *OrderHandler) UploadOrder(w http.ResponseWriter, r *http.Request) {
 	err = h.orderUseCase.UploadOrder(r.Context(), userID, orderNumber)
 	if err != nil {
 		logger.Error("Failed to upload order", zap.Error(err))
-		switch err {
-		case domain.ErrInvalidOrderNumber:
+		switch {
+		case errors.Is(err, domain.ErrInvalidOrderNumber):
 			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-		case domain.ErrOrderBelongsToUser:
+		case errors.Is(err, domain.ErrOrderBelongsToUser):
 			w.WriteHeader(http.StatusOK)
-		case domain.ErrOrderBelongsToAnotherUser:
+		case errors.Is(err, domain.ErrOrderBelongsToAnotherUser):
 			w.WriteHeader(http.StatusConflict)
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -69,7 +73,10 @@ func (h *OrderHandler) UploadOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// GetOrders возвращает список заказов пользователя
+// GetOrders возвращает заказы пользователя постранично. Страница задается параметрами limit
+// и cursor, без них список отдается целиком; выборку ограничивают status (через запятую),
+// uploaded_from и uploaded_to, processed_from и processed_to. Адрес следующей страницы
+// возвращается в заголовке Link.
 func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 	// Получаем ID пользователя из контекста
 	userID, ok := r.Context().Value(userIDKey).(int64)
@@ -79,27 +86,166 @@ func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем список заказов
-	orders, err := h.orderUseCase.GetUserOrders(r.Context(), userID)
+	// Разбираем параметры страницы и фильтра
+	query := r.URL.Query()
+	limit, err := parseLimit(query)
 	if err != nil {
-		logger.Error("Failed to get user orders", zap.Error(err))
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var filter domain.OrderFilter
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				filter.Statuses = append(filter.Statuses, domain.OrderStatus(status))
+			}
+		}
+	}
+	err = parseTimeParams(query,
+		timeParam{name: "uploaded_from", dst: &filter.UploadedFrom},
+		timeParam{name: "uploaded_to", dst: &filter.UploadedTo},
+		timeParam{name: "processed_from", dst: &filter.ProcessedFrom},
+		timeParam{name: "processed_to", dst: &filter.ProcessedTo},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Получаем страницу заказов
+	page, err := h.orderUseCase.GetUserOrders(r.Context(), userID, filter, query.Get("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidCursor):
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidOrderStatus):
+			http.Error(w, "invalid status", http.StatusBadRequest)
+		default:
+			logger.Error("Failed to get user orders", zap.Error(err))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	// Если заказов нет, возвращаем 204
-	if len(orders) == 0 {
+	if len(page.Orders) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	// Устанавливаем заголовок Content-Type
+	// Устанавливаем заголовки ответа
+	if page.NextCursor != "" {
+		setNextPageLink(w, r, page.NextCursor)
+	}
 	w.Header().Set("Content-Type", "application/json")
 
 	// Сериализуем заказы в JSON
-	if err := json.NewEncoder(w).Encode(orders); err != nil {
+	if err := json.NewEncoder(w).Encode(page.Orders); err != nil {
 		logger.Error("Failed to encode orders", zap.Error(err))
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 }
+
+// orderResponse представляет заказ в ответе на запрос одного заказа. В отличие от
+// элемента списка он содержит время окончательной обработки.
+type orderResponse struct {
+	Number      string             `json:"number"`
+	Status      domain.OrderStatus `json:"status"`
+	Accrual     domain.Money       `json:"accrual,omitempty"`
+	UploadedAt  time.Time          `json:"uploaded_at"`
+	ProcessedAt *time.Time         `json:"processed_at,omitempty"`
+	NextCheckAt *time.Time         `json:"next_check_at,omitempty"`
+}
+
+// newOrderResponse собирает ответ по заказу; processed_at отдается только для
+// окончательных статусов PROCESSED и INVALID
+func newOrderResponse(order *domain.Order) orderResponse {
+	resp := orderResponse{
+		Number:      order.Number,
+		Status:      order.Status,
+		Accrual:     order.Accrual,
+		UploadedAt:  order.UploadedAt,
+		NextCheckAt: order.NextCheckAt,
+	}
+	if order.Status == domain.StatusProcessed || order.Status == domain.StatusInvalid {
+		resp.ProcessedAt = order.ProcessedAt
+	}
+	return resp
+}
+
+// GetOrder возвращает заказ пользователя по номеру
+func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	// Получаем ID пользователя из контекста
+	userID, ok := r.Context().Value(userIDKey).(int64)
+	if !ok {
+		logger.Error("Failed to get user ID from context")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderNumber := chi.URLParam(r, "number")
+
+	order, err := h.orderUseCase.GetUserOrder(r.Context(), userID, orderNumber)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to get order", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newOrderResponse(order)); err != nil {
+		logger.Error("Failed to encode order", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// orderHistoryResponse представляет историю статусов заказа
+type orderHistoryResponse struct {
+	Number  string                         `json:"number"`
+	History []domain.OrderStatusTransition `json:"history"`
+}
+
+// GetOrderHistory возвращает историю статусов заказа пользователя
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	// Получаем ID пользователя из контекста
+	userID, ok := r.Context().Value(userIDKey).(int64)
+	if !ok {
+		logger.Error("Failed to get user ID from context")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	orderNumber := chi.URLParam(r, "number")
+
+	history, err := h.orderUseCase.GetOrderHistory(r.Context(), userID, orderNumber)
+	if err != nil {
+		if errors.Is(err, domain.ErrOrderNotFound) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to get order history", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := orderHistoryResponse{
+		Number:  orderNumber,
+		History: history,
+	}
+	if resp.History == nil {
+		resp.History = []domain.OrderStatusTransition{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("Failed to encode order history", zap.Error(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -5,14 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"gophermart/internal/domain"
 	"gophermart/internal/handler/mocks"
 	"gophermart/internal/logger"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func init() {
@@ -83,6 +88,19 @@ func TestOrderHandler(t *testing.T) {
 			withAuth:     true,
 			userID:       1,
 		},
+		{
+			name:        "Обернутая ошибка принадлежности заказа",
+			orderNumber: "12345678903",
+			mockBehavior: func(m *mocks.MockOrderUseCase) {
+				m.UploadOrderFunc = func(ctx context.Context, userID int64, orderNumber string) error {
+					return fmt.Errorf("upload order: %w", domain.ErrOrderBelongsToAnotherUser)
+				}
+				m.ShutdownFunc = func(ctx context.Context) {}
+			},
+			expectedCode: http.StatusConflict,
+			withAuth:     true,
+			userID:       1,
+		},
 		{
 			name:        "Внутренняя ошибка сервера при загрузке заказа",
 			orderNumber: "12345678903",
@@ -154,15 +172,15 @@ func TestOrderHandler(t *testing.T) {
 		{
 			name: "Успешное получение заказов",
 			mockBehavior: func(m *mocks.MockOrderUseCase) {
-				m.GetUserOrdersFunc = func(ctx context.Context, userID int64) ([]domain.Order, error) {
-					return []domain.Order{
+				m.GetUserOrdersFunc = func(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error) {
+					return &domain.OrderPage{Orders: []domain.Order{
 						{
 							Number:     "12345678903",
 							Status:     domain.StatusProcessed,
-							Accrual:    500,
+							Accrual:    domain.Rubles(500),
 							UploadedAt: time.Now(),
 						},
-					}, nil
+					}}, nil
 				}
 				m.ShutdownFunc = func(ctx context.Context) {}
 			},
@@ -171,7 +189,7 @@ func TestOrderHandler(t *testing.T) {
 				{
 					Number:     "12345678903",
 					Status:     domain.StatusProcessed,
-					Accrual:    500,
+					Accrual:    domain.Rubles(500),
 					UploadedAt: time.Now(),
 				},
 			},
@@ -181,8 +199,8 @@ func TestOrderHandler(t *testing.T) {
 		{
 			name: "Нет заказов",
 			mockBehavior: func(m *mocks.MockOrderUseCase) {
-				m.GetUserOrdersFunc = func(ctx context.Context, userID int64) ([]domain.Order, error) {
-					return nil, nil
+				m.GetUserOrdersFunc = func(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error) {
+					return &domain.OrderPage{}, nil
 				}
 				m.ShutdownFunc = func(ctx context.Context) {}
 			},
@@ -194,7 +212,7 @@ func TestOrderHandler(t *testing.T) {
 		{
 			name: "Внутренняя ошибка при получении заказов",
 			mockBehavior: func(m *mocks.MockOrderUseCase) {
-				m.GetUserOrdersFunc = func(ctx context.Context, userID int64) ([]domain.Order, error) {
+				m.GetUserOrdersFunc = func(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error) {
 					return nil, errors.New("internal error")
 				}
 				m.ShutdownFunc = func(ctx context.Context) {}
@@ -256,7 +274,7 @@ func TestOrderHandler(t *testing.T) {
 							tt.expectedBody[0].Status, response[0].Status)
 					}
 					if response[0].Accrual != tt.expectedBody[0].Accrual {
-						t.Errorf("Expected order accrual %f, got %f",
+						t.Errorf("Expected order accrual %s, got %s",
 							tt.expectedBody[0].Accrual, response[0].Accrual)
 					}
 				}
@@ -264,3 +282,269 @@ func TestOrderHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderHandler_GetOrder(t *testing.T) {
+	processedAt := time.Date(2024, 3, 1, 12, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		order        *domain.Order
+		lookupErr    error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Обработанный заказ",
+			order: &domain.Order{
+				UserID:      1,
+				Number:      "12345678903",
+				Status:      domain.StatusProcessed,
+				Accrual:     domain.Rubles(500),
+				UploadedAt:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				ProcessedAt: &processedAt,
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"number":"12345678903","status":"PROCESSED","accrual":500,` +
+				`"uploaded_at":"2024-03-01T12:00:00Z","processed_at":"2024-03-01T12:05:00Z"}`,
+		},
+		{
+			name: "Заказ в обработке",
+			order: &domain.Order{
+				UserID:      1,
+				Number:      "12345678903",
+				Status:      domain.StatusNew,
+				UploadedAt:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				NextCheckAt: &processedAt,
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"number":"12345678903","status":"NEW","uploaded_at":"2024-03-01T12:00:00Z",` +
+				`"next_check_at":"2024-03-01T12:05:00Z"}`,
+		},
+		{
+			name: "Время обработки незавершенного заказа не отдается",
+			order: &domain.Order{
+				UserID:      1,
+				Number:      "12345678903",
+				Status:      domain.StatusProcessing,
+				UploadedAt:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				ProcessedAt: &processedAt,
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"number":"12345678903","status":"PROCESSING","uploaded_at":"2024-03-01T12:00:00Z"}`,
+		},
+		{
+			name:         "Чужой или неизвестный заказ",
+			lookupErr:    domain.ErrOrderNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Внутренняя ошибка",
+			lookupErr:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := &mocks.MockOrderUseCase{
+				GetUserOrderFunc: func(ctx context.Context, userID int64, orderNumber string) (*domain.Order, error) {
+					if userID != 1 || orderNumber != "12345678903" {
+						t.Errorf("Unexpected order request for user %d, order %s", userID, orderNumber)
+					}
+					return tt.order, tt.lookupErr
+				},
+			}
+
+			r := chi.NewRouter()
+			r.Get("/api/user/orders/{number}", NewOrderHandler(mockUseCase).GetOrder)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders/12345678903", nil)
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, int64(1)))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			if body := strings.TrimSpace(w.Body.String()); body != tt.expectedBody {
+				t.Errorf("Expected body %s, got %s", tt.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestOrderHandler_GetOrderHistory(t *testing.T) {
+	tests := []struct {
+		name         string
+		historyErr   error
+		expectedCode int
+		expectedLen  int
+	}{
+		{
+			name:         "История заказа",
+			expectedCode: http.StatusOK,
+			expectedLen:  3,
+		},
+		{
+			name:         "Чужой или неизвестный заказ",
+			historyErr:   domain.ErrOrderNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Внутренняя ошибка",
+			historyErr:   errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := &mocks.MockOrderUseCase{
+				GetOrderHistoryFunc: func(ctx context.Context, userID int64, orderNumber string) ([]domain.OrderStatusTransition, error) {
+					if userID != 1 || orderNumber != "12345678903" {
+						t.Errorf("Unexpected history request for user %d, order %s", userID, orderNumber)
+					}
+					if tt.historyErr != nil {
+						return nil, tt.historyErr
+					}
+					return []domain.OrderStatusTransition{
+						{To: domain.StatusNew, Source: domain.StatusSourceUpload},
+						{From: domain.StatusNew, To: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 1},
+						{From: domain.StatusProcessing, To: domain.StatusProcessed, Accrual: domain.Rubles(500), Source: domain.StatusSourcePoll, Attempt: 4},
+					}, nil
+				},
+			}
+
+			r := chi.NewRouter()
+			r.Get("/api/user/orders/{number}/history", NewOrderHandler(mockUseCase).GetOrderHistory)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders/12345678903/history", nil)
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, int64(1)))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var resp orderHistoryResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response body: %v", err)
+			}
+			if len(resp.History) != tt.expectedLen {
+				t.Errorf("Expected %d transitions, got %d", tt.expectedLen, len(resp.History))
+			}
+		})
+	}
+}
+
+func TestOrderHandler_GetOrdersPage(t *testing.T) {
+	uploadedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	next := domain.EncodeOrderCursor(domain.Order{Number: "12345678903", UploadedAt: uploadedAt})
+
+	tests := []struct {
+		name         string
+		query        string
+		page         *domain.OrderPage
+		useCaseErr   error
+		wantFilter   domain.OrderFilter
+		wantCursor   string
+		wantLimit    int
+		expectedCode int
+		expectedLink string
+	}{
+		{
+			name:  "Страница с продолжением и фильтром",
+			query: "?limit=1&status=NEW,PROCESSED&status=INVALID&uploaded_from=2024-03-01&uploaded_to=2024-03-02T00:00:00Z",
+			page: &domain.OrderPage{
+				Orders:     []domain.Order{{Number: "12345678903", Status: domain.StatusNew, UploadedAt: uploadedAt}},
+				NextCursor: next,
+			},
+			wantFilter: domain.OrderFilter{
+				Statuses:     []domain.OrderStatus{domain.StatusNew, domain.StatusProcessed, domain.StatusInvalid},
+				UploadedFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				UploadedTo:   time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+			},
+			wantLimit:    1,
+			expectedCode: http.StatusOK,
+			expectedLink: "</api/user/orders?cursor=" + next +
+				"&limit=1&status=NEW%2CPROCESSED&status=INVALID&uploaded_from=2024-03-01&uploaded_to=2024-03-02T00%3A00%3A00Z>; rel=\"next\"",
+		},
+		{
+			name:         "Последняя страница",
+			query:        "?cursor=" + next,
+			page:         &domain.OrderPage{Orders: []domain.Order{{Number: "2377225624", UploadedAt: uploadedAt}}},
+			wantCursor:   next,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Неверный размер страницы",
+			query:        "?limit=abc",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Неверная дата",
+			query:        "?processed_from=01.03.2024",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Неизвестный статус",
+			query:        "?status=DONE",
+			wantFilter:   domain.OrderFilter{Statuses: []domain.OrderStatus{"DONE"}},
+			useCaseErr:   domain.ErrInvalidOrderStatus,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Неверный курсор",
+			query:        "?cursor=bad",
+			wantCursor:   "bad",
+			useCaseErr:   domain.ErrInvalidCursor,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUseCase := &mocks.MockOrderUseCase{
+				GetUserOrdersFunc: func(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error) {
+					if !reflect.DeepEqual(filter, tt.wantFilter) || cursor != tt.wantCursor || limit != tt.wantLimit {
+						t.Errorf("Unexpected orders request: filter %+v, cursor %q, limit %d", filter, cursor, limit)
+					}
+					return tt.page, tt.useCaseErr
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/orders"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, int64(1)))
+			w := httptest.NewRecorder()
+
+			NewOrderHandler(mockUseCase).GetOrders(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, w.Code)
+			}
+			if link := w.Header().Get("Link"); link != tt.expectedLink {
+				t.Errorf("Expected Link %q, got %q", tt.expectedLink, link)
+			}
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var orders []domain.Order
+			if err := json.NewDecoder(w.Body).Decode(&orders); err != nil {
+				t.Fatalf("Failed to decode response body: %v", err)
+			}
+			if len(orders) != len(tt.page.Orders) {
+				t.Errorf("Expected %d orders, got %d", len(tt.page.Orders), len(orders))
+			}
+		})
+	}
+}
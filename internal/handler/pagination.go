@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// timeParam связывает параметр запроса с границей интервала фильтра
+type timeParam struct {
+	name string
+	dst  *time.Time
+}
+
+// parseLimit разбирает размер страницы из параметра limit. Без параметра возвращается 0,
+// и размер страницы выбирает usecase.
+func parseLimit(query url.Values) (int, error) {
+	raw := query.Get("limit")
+	if raw == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit")
+	}
+	return limit, nil
+}
+
+// parseTimeParams заполняет границы интервалов из параметров запроса в формате RFC 3339
+// или YYYY-MM-DD. Отсутствующий параметр оставляет нулевое время.
+func parseTimeParams(query url.Values, params ...timeParam) error {
+	for _, p := range params {
+		raw := query.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, raw); err != nil {
+				return fmt.Errorf("invalid %s", p.name)
+			}
+		}
+		*p.dst = t
+	}
+	return nil
+}
+
+// setNextPageLink указывает в заголовке Link адрес следующей страницы списка:
+// тот же запрос с курсором cursor
+func setNextPageLink(w http.ResponseWriter, r *http.Request, cursor string) {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
@@ -19,6 +19,26 @@ func NewRouter(h *Handler) chi.Router {
 	r.Post("/api/user/register", h.auth.Register)
 	r.Post("/api/user/login", h.auth.Login)
 
+	// Internal routes
+	// Уведомления аутентифицируются подписью, а не токеном пользователя
+	r.Post("/api/internal/accrual/callback", h.accrual.Callback)
+
+	// Admin routes
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(h.admin.AdminMiddleware)
+
+		// Состояние выключателя системы начислений
+		r.Get("/accrual/status", h.accrual.GetStatus)
+		// Отчет о сверке содержит заказы и начисления всех пользователей
+		r.Get("/accrual/reconciliation", h.accrual.GetReconciliationReport)
+		// Счетчики по адресам системы начислений
+		r.Get("/accrual/metrics", h.accrual.GetMetrics)
+
+		r.Post("/orders/requeue", h.admin.RequeueOrders)
+		r.Post("/orders/{number}/requeue", h.admin.RequeueOrder)
+		r.Post("/orders/{number}/status", h.admin.ForceOrderStatus)
+	})
+
 	// Protected routes
 	r.Group(func(r chi.Router) {
 		r.Use(h.auth.AuthMiddleware)
@@ -26,11 +46,14 @@ func NewRouter(h *Handler) chi.Router {
 		// Orders
 		r.Post("/api/user/orders", h.order.UploadOrder)
 		r.Get("/api/user/orders", h.order.GetOrders)
+		r.Get("/api/user/orders/{number}", h.order.GetOrder)
+		r.Get("/api/user/orders/{number}/history", h.order.GetOrderHistory)
 
 		// Balance
 		r.Get("/api/user/balance", h.balance.GetBalance)
 		r.Post("/api/user/balance/withdraw", h.balance.Withdraw)
 		r.Get("/api/user/withdrawals", h.balance.GetWithdrawals)
+		r.Get("/api/user/transactions", h.balance.GetTransactions)
 	})
 
 	return r
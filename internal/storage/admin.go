@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gophermart/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// RequeueOrder возвращает заказ в очередь опроса с немедленной первой попыткой и записывает
+// операцию в журнал. Заказы в INVALID и STUCK переводятся в NEW; начисленный заказ
+// в очередь не возвращается.
+func (r *PostgresRepository) RequeueOrder(ctx context.Context, number string, entry domain.AdminAuditEntry) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var currentStatus domain.OrderStatus
+	err = tx.QueryRow(ctx,
+		`SELECT status FROM orders WHERE number = $1 FOR UPDATE`,
+		number,
+	).Scan(&currentStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrOrderNotFound
+		}
+		return fmt.Errorf("error locking order: %w", err)
+	}
+
+	if currentStatus == domain.StatusProcessed {
+		return domain.ErrOrderAlreadyProcessed
+	}
+
+	if err := requeueLockedOrder(ctx, tx, number, currentStatus); err != nil {
+		return err
+	}
+
+	entry.OrderNumber = number
+	entry.Affected = 1
+	if err := insertAuditEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RequeueOrders возвращает в очередь все заказы, подходящие под filter, и записывает
+// операцию в журнал одной записью. Возвращает число затронутых заказов.
+func (r *PostgresRepository) RequeueOrders(ctx context.Context, filter domain.RequeueFilter, entry domain.AdminAuditEntry) (int, error) {
+	if filter.Status == domain.StatusProcessed {
+		return 0, domain.ErrOrderAlreadyProcessed
+	}
+
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT number FROM orders
+		 WHERE status = $1
+		   AND ($2::timestamptz IS NULL OR uploaded_at >= $2)
+		   AND ($3::timestamptz IS NULL OR uploaded_at < $3)
+		 ORDER BY uploaded_at
+		 FOR UPDATE`,
+		filter.Status, nullTime(filter.UploadedFrom), nullTime(filter.UploadedTo),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error selecting orders to requeue: %w", err)
+	}
+
+	var numbers []string
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning order number: %w", err)
+		}
+		numbers = append(numbers, number)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating orders to requeue: %w", err)
+	}
+
+	for _, number := range numbers {
+		if err := requeueLockedOrder(ctx, tx, number, filter.Status); err != nil {
+			return 0, err
+		}
+	}
+
+	entry.Affected = len(numbers)
+	if err := insertAuditEntry(ctx, tx, entry); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(numbers), nil
+}
+
+// ForceOrderStatus вручную устанавливает заказу окончательный статус через тот же путь,
+// что и результат опроса, включая зачисление на баланс, и записывает операцию в журнал
+func (r *PostgresRepository) ForceOrderStatus(ctx context.Context, change domain.OrderStatusChange, entry domain.AdminAuditEntry) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID int64
+	var currentStatus domain.OrderStatus
+	err = tx.QueryRow(ctx,
+		`SELECT user_id, status FROM orders WHERE number = $1 FOR UPDATE`,
+		change.OrderNumber,
+	).Scan(&userID, &currentStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrOrderNotFound
+		}
+		return fmt.Errorf("error locking order: %w", err)
+	}
+
+	// Начисление уже зачислено на баланс: исправления проводятся только сверкой
+	if currentStatus == domain.StatusProcessed {
+		return domain.ErrOrderAlreadyProcessed
+	}
+
+	if err := applyStatusChange(ctx, tx, change, currentStatus, userID); err != nil {
+		return err
+	}
+
+	entry.OrderNumber = change.OrderNumber
+	entry.Affected = 1
+	if err := insertAuditEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// requeueLockedOrder ставит заблокированный в tx заказ в очередь заново со сброшенными счетчиками
+func requeueLockedOrder(ctx context.Context, tx pgx.Tx, number string, currentStatus domain.OrderStatus) error {
+	if currentStatus == domain.StatusInvalid || currentStatus == domain.StatusStuck {
+		_, err := tx.Exec(ctx,
+			`UPDATE orders SET status = $1, last_error = NULL, processed_at = NULL WHERE number = $2`,
+			domain.StatusNew, number,
+		)
+		if err != nil {
+			return fmt.Errorf("error resetting order status: %w", err)
+		}
+
+		err = insertStatusTransition(ctx, tx, number, domain.OrderStatusTransition{
+			From:   currentStatus,
+			To:     domain.StatusNew,
+			Source: domain.StatusSourceAdmin,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	_, err := tx.Exec(ctx,
+		`INSERT INTO accrual_jobs (order_number, next_attempt_at, created_at)
+		 VALUES ($1, $2, $2)
+		 ON CONFLICT (order_number) DO UPDATE
+		 SET attempts = 0, next_attempt_at = $2, created_at = $2, last_error = NULL,
+		     error_class = NULL, class_attempts = 0, locked_by = NULL, locked_until = NULL`,
+		number, now,
+	)
+	if err != nil {
+		return fmt.Errorf("error requeueing accrual job: %w", err)
+	}
+
+	return nil
+}
+
+// insertAuditEntry записывает административную операцию в журнал в рамках транзакции tx
+func insertAuditEntry(ctx context.Context, tx pgx.Tx, entry domain.AdminAuditEntry) error {
+	var orderNumber sql.NullString
+	if entry.OrderNumber != "" {
+		orderNumber = sql.NullString{String: entry.OrderNumber, Valid: true}
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO admin_audit_log (actor, action, order_number, details, reason, affected)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Actor, entry.Action, orderNumber, entry.Details, entry.Reason, entry.Affected,
+	)
+	if err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return nil
+}
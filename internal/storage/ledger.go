@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gophermart/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ledgerLeg - запись операции по одному счёту
+type ledgerLeg struct {
+	account domain.LedgerAccount
+	amount  domain.Money
+}
+
+// ledgerSource - источник операции журнала
+type ledgerSource struct {
+	orderNumber  string
+	adjustmentID int64
+}
+
+// insertLedgerTransaction проводит операцию kind по счетам пользователя userID в рамках
+// транзакции tx. Записи с нулевой суммой пропускаются; сумма записей должна быть равна нулю.
+func insertLedgerTransaction(ctx context.Context, tx pgx.Tx, userID int64, kind domain.LedgerEntryKind, source ledgerSource, legs ...ledgerLeg) error {
+	var total domain.Money
+	for _, leg := range legs {
+		total += leg.amount
+	}
+	if total != 0 {
+		return fmt.Errorf("error recording %s: unbalanced ledger transaction, legs add up to %s", kind, total)
+	}
+
+	var orderNumber sql.NullString
+	if source.orderNumber != "" {
+		orderNumber = sql.NullString{String: source.orderNumber, Valid: true}
+	}
+	var adjustmentID sql.NullInt64
+	if source.adjustmentID > 0 {
+		adjustmentID = sql.NullInt64{Int64: source.adjustmentID, Valid: true}
+	}
+
+	var transactionID int64
+	err := tx.QueryRow(ctx, `SELECT nextval('ledger_transaction_id_seq')`).Scan(&transactionID)
+	if err != nil {
+		return fmt.Errorf("error allocating ledger transaction: %w", err)
+	}
+
+	for _, leg := range legs {
+		if leg.amount == 0 {
+			continue
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO ledger_entries (transaction_id, user_id, account, kind, amount, order_number, adjustment_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			transactionID, userID, leg.account, kind, moneyArg(leg.amount), orderNumber, adjustmentID,
+		)
+		if err != nil {
+			return fmt.Errorf("error recording ledger entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetUserTransactions возвращает до limit операций пользователя от новых к старым,
+// начиная с операции, предшествующей before; before = 0 - с самой новой
+func (r *PostgresRepository) GetUserTransactions(ctx context.Context, userID int64, before int64, limit int) ([]domain.Transaction, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT transaction_id, kind, COALESCE(order_number, ''),
+		        COALESCE(SUM(amount) FILTER (WHERE account = 'current'), 0),
+		        -COALESCE(SUM(amount) FILTER (WHERE account = 'debt'), 0),
+		        MIN(created_at)
+		 FROM ledger_entries
+		 WHERE user_id = $1 AND ($2::bigint = 0 OR transaction_id < $2)
+		 GROUP BY transaction_id, kind, order_number
+		 ORDER BY transaction_id DESC
+		 LIMIT $3`,
+		userID, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []domain.Transaction
+	for rows.Next() {
+		var t domain.Transaction
+		err := rows.Scan(
+			&t.ID,
+			&t.Kind,
+			&t.OrderNumber,
+			scanMoney(&t.Amount),
+			scanMoney(&t.Debt),
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	}
+
+	return transactions, nil
+}
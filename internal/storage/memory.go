@@ -0,0 +1,882 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// MemoryStorage хранит данные в памяти процесса с той же семантикой, что и PostgresRepository.
+// Предназначено для локального запуска без базы данных и для тестов; данные теряются при остановке.
+type MemoryStorage struct {
+	mu sync.Mutex
+	// reconcileMu - блокировка сверки, аналог advisory-блокировки PostgresRepository
+	reconcileMu sync.Mutex
+
+	users      map[string]*domain.User
+	nextUserID int64
+
+	orders  map[string]*domain.Order
+	jobs    map[string]*domain.AccrualJob
+	history map[string][]domain.OrderStatusTransition
+
+	balances        map[int64]*memoryBalance
+	withdrawals     []memoryWithdrawal
+	orderWithdrawal map[string]int
+	idempotencyKeys map[memoryIdempotencyKey]memoryIdempotencyRecord
+
+	ledger            []memoryLedgerEntry
+	nextTransactionID int64
+
+	adjustments []domain.AccrualAdjustment
+	auditLog    []domain.AdminAuditEntry
+
+	lastReport *domain.ReconciliationReport
+}
+
+// memoryBalance - остатки счетов пользователя, которые меняются только проводками журнала
+type memoryBalance struct {
+	current   domain.Money
+	withdrawn domain.Money
+	debt      domain.Money
+}
+
+// memoryWithdrawal - списание вместе с его владельцем
+type memoryWithdrawal struct {
+	userID     int64
+	withdrawal domain.Withdrawal
+}
+
+// memoryIdempotencyKey - ключ идемпотентности в пространстве ключей пользователя
+type memoryIdempotencyKey struct {
+	userID int64
+	key    string
+}
+
+// memoryIdempotencyRecord связывает ключ идемпотентности со списанием или, если запрос
+// завершился отказом, с параметрами запроса и причиной отказа
+type memoryIdempotencyRecord struct {
+	withdrawal  int
+	orderNumber string
+	sum         domain.Money
+	failure     error
+	createdAt   time.Time
+}
+
+// memoryLedgerEntry - запись журнала операций
+type memoryLedgerEntry struct {
+	transactionID int64
+	userID        int64
+	account       domain.LedgerAccount
+	kind          domain.LedgerEntryKind
+	amount        domain.Money
+	source        ledgerSource
+	createdAt     time.Time
+}
+
+// NewMemoryStorage создает пустое хранилище в памяти
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users:           make(map[string]*domain.User),
+		orders:          make(map[string]*domain.Order),
+		jobs:            make(map[string]*domain.AccrualJob),
+		history:         make(map[string][]domain.OrderStatusTransition),
+		balances:        make(map[int64]*memoryBalance),
+		orderWithdrawal: make(map[string]int),
+		idempotencyKeys: make(map[memoryIdempotencyKey]memoryIdempotencyRecord),
+	}
+}
+
+// Ping всегда успешен: хранилище в памяти доступно, пока работает процесс
+func (s *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close ничего не освобождает
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// CreateUser создает нового пользователя
+func (s *MemoryStorage) CreateUser(ctx context.Context, login, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[login]; ok {
+		return domain.ErrUserExists
+	}
+
+	s.nextUserID++
+	s.users[login] = &domain.User{
+		ID:           s.nextUserID,
+		Login:        login,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+	return nil
+}
+
+// GetUserByLogin находит пользователя по логину
+func (s *MemoryStorage) GetUserByLogin(ctx context.Context, login string) (*domain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[login]
+	if !ok {
+		return nil, fmt.Errorf("error getting user by login: %w", domain.ErrUserNotFound)
+	}
+	found := *user
+	return &found, nil
+}
+
+// CreateOrder создает новый заказ и ставит его в очередь на опрос системы начислений.
+// Первый опрос выполняется не раньше firstAttemptAt.
+func (s *MemoryStorage) CreateOrder(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[number]; ok {
+		return fmt.Errorf("error creating order: %w", domain.ErrOrderExists)
+	}
+
+	now := time.Now()
+	s.orders[number] = &domain.Order{
+		UserID:     userID,
+		Number:     number,
+		Status:     domain.StatusNew,
+		UploadedAt: now,
+	}
+	s.jobs[number] = &domain.AccrualJob{
+		OrderNumber:   number,
+		NextAttemptAt: firstAttemptAt,
+		CreatedAt:     now,
+	}
+	s.addTransition(number, domain.OrderStatusTransition{
+		To:     domain.StatusNew,
+		Source: domain.StatusSourceUpload,
+	})
+	return nil
+}
+
+// GetOrderByNumber находит заказ по номеру вместе со временем следующей проверки начисления
+func (s *MemoryStorage) GetOrderByNumber(ctx context.Context, number string) (*domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[number]
+	if !ok {
+		return nil, domain.ErrOrderNotFound
+	}
+	found := *order
+	if job, ok := s.jobs[number]; ok {
+		nextCheckAt := job.NextAttemptAt
+		found.NextCheckAt = &nextCheckAt
+	}
+	return &found, nil
+}
+
+// GetUserOrders возвращает до limit заказов пользователя, отобранных filter, от новых к старым,
+// начиная с заказа, следующего за after; after = nil - с самого нового, limit <= 0 - все
+func (s *MemoryStorage) GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, after *domain.OrderCursor, limit int) ([]domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []domain.Order
+	for _, order := range s.orders {
+		if order.UserID != userID || !matchOrderFilter(order, filter) {
+			continue
+		}
+		if after != nil && !orderBefore(order, after.UploadedAt, after.Number) {
+			continue
+		}
+		found := *order
+		if job, ok := s.jobs[order.Number]; ok {
+			nextCheckAt := job.NextAttemptAt
+			found.NextCheckAt = &nextCheckAt
+		}
+		orders = append(orders, found)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orderBefore(&orders[j], orders[i].UploadedAt, orders[i].Number)
+	})
+	if limit > 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// orderBefore сообщает, идет ли order в списке после заказа number, загруженного в uploadedAt
+func orderBefore(order *domain.Order, uploadedAt time.Time, number string) bool {
+	if !order.UploadedAt.Equal(uploadedAt) {
+		return order.UploadedAt.Before(uploadedAt)
+	}
+	return order.Number < number
+}
+
+// matchOrderFilter проверяет, что заказ проходит фильтр списка
+func matchOrderFilter(order *domain.Order, filter domain.OrderFilter) bool {
+	if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, order.Status) {
+		return false
+	}
+	if !inInterval(order.UploadedAt, filter.UploadedFrom, filter.UploadedTo) {
+		return false
+	}
+	if filter.ProcessedFrom.IsZero() && filter.ProcessedTo.IsZero() {
+		return true
+	}
+	return order.ProcessedAt != nil && inInterval(*order.ProcessedAt, filter.ProcessedFrom, filter.ProcessedTo)
+}
+
+// inInterval проверяет, что t попадает в [from, to); нулевая граница не ограничивает интервал
+func inInterval(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// UpdateOrderStatusAndBalance атомарно обновляет статус заказа и баланс пользователя.
+// Смена статуса записывается в историю заказа.
+func (s *MemoryStorage) UpdateOrderStatusAndBalance(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[change.OrderNumber]
+	if !ok {
+		return domain.ErrOrderNotFound
+	}
+
+	// Окончательный статус уже применен другим обработчиком: повторно баланс не начисляем
+	if order.Status == domain.StatusProcessed || order.Status == domain.StatusInvalid {
+		delete(s.jobs, change.OrderNumber)
+		return nil
+	}
+
+	return s.applyStatusChange(order, change, userID)
+}
+
+// applyStatusChange применяет результат расчёта к заказу так же, как одноименная
+// функция PostgresRepository. Вызывается под s.mu.
+func (s *MemoryStorage) applyStatusChange(order *domain.Order, change domain.OrderStatusChange, userID int64) error {
+	currentStatus := order.Status
+
+	order.Status = change.Status
+	order.Accrual = change.Accrual
+	// Время обработки есть только у заказа с окончательным статусом
+	order.ProcessedAt = nil
+	if change.Status == domain.StatusProcessed || change.Status == domain.StatusInvalid {
+		now := time.Now()
+		order.ProcessedAt = &now
+	}
+
+	// Повторный опрос с тем же статусом переходом не считается
+	if change.Status != currentStatus {
+		s.addTransition(order.Number, domain.OrderStatusTransition{
+			From:    currentStatus,
+			To:      change.Status,
+			Accrual: change.Accrual,
+			Source:  change.Source,
+			Attempt: change.Attempt,
+		})
+	}
+
+	// Окончательный статус больше не требует опроса системы начислений
+	if change.Status == domain.StatusProcessed || change.Status == domain.StatusInvalid {
+		delete(s.jobs, order.Number)
+	}
+
+	// Начисление сначала гасит долг пользователя
+	if change.Status == domain.StatusProcessed && change.Accrual > 0 {
+		balance := s.balance(userID)
+		newCurrent, newDebt, _ := adjustBalance(balance.current, balance.debt, change.Accrual)
+		return s.postLedger(userID, domain.LedgerAccrual,
+			ledgerSource{orderNumber: order.Number},
+			ledgerLeg{account: domain.AccountAccrualSystem, amount: -change.Accrual},
+			ledgerLeg{account: domain.AccountCurrent, amount: newCurrent - balance.current},
+			ledgerLeg{account: domain.AccountDebt, amount: balance.debt - newDebt},
+		)
+	}
+	return nil
+}
+
+// addTransition записывает переход заказа в историю. Вызывается под s.mu.
+func (s *MemoryStorage) addTransition(number string, transition domain.OrderStatusTransition) {
+	transition.ChangedAt = time.Now()
+	s.history[number] = append(s.history[number], transition)
+}
+
+// GetOrderStatusHistory возвращает историю статусов заказа в порядке изменений
+func (s *MemoryStorage) GetOrderStatusHistory(ctx context.Context, number string) ([]domain.OrderStatusTransition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.history[number]
+	if len(history) == 0 {
+		return nil, nil
+	}
+	return append([]domain.OrderStatusTransition(nil), history...), nil
+}
+
+// ClaimAccrualJobs арендует для owner задания, время очередной попытки которых наступило.
+// Задания, арендованные другой репликой, пропускаются до истечения аренды.
+func (s *MemoryStorage) ClaimAccrualJobs(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	var due []*domain.AccrualJob
+	for _, job := range s.jobs {
+		if job.NextAttemptAt.After(now) || job.LockedUntil.After(now) {
+			continue
+		}
+		due = append(due, job)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	jobs := make([]domain.AccrualJob, 0, len(due))
+	for _, job := range due {
+		job.LockedBy = owner
+		job.LockedUntil = now.Add(leaseTTL)
+
+		claimed := *job
+		claimed.UploadedAt = s.orders[job.OrderNumber].UploadedAt
+		jobs = append(jobs, claimed)
+	}
+	return jobs, nil
+}
+
+// RescheduleAccrualJob откладывает задание до nextAttemptAt, сохраняет класс и текст
+// последней ошибки и снимает аренду. Счетчик попыток класса сбрасывается при смене класса.
+// Если задание арендовано не owner, оно не изменяется и возвращается domain.ErrLeaseLost.
+func (s *MemoryStorage) RescheduleAccrualJob(ctx context.Context, orderNumber, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[orderNumber]
+	if !ok || job.LockedBy != owner {
+		return domain.ErrLeaseLost
+	}
+
+	job.Attempts++
+	if job.ErrorClass == errorClass {
+		job.ClassAttempts++
+	} else {
+		job.ClassAttempts = 1
+	}
+	job.ErrorClass = errorClass
+	job.NextAttemptAt = nextAttemptAt
+	job.LastError = lastError
+	job.LockedBy = ""
+	job.LockedUntil = time.Time{}
+	return nil
+}
+
+// ExtendAccrualLeases продлевает до lockedUntil действующую аренду всех заданий owner.
+// Истекшая аренда не возобновляется: задание могла уже забрать другая реплика.
+func (s *MemoryStorage) ExtendAccrualLeases(ctx context.Context, owner string, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, job := range s.jobs {
+		if job.LockedBy == owner && job.LockedUntil.After(now) && job.LockedUntil.Before(lockedUntil) {
+			job.LockedUntil = lockedUntil
+		}
+	}
+	return nil
+}
+
+// MarkOrderStuck переводит заказ в статус STUCK с сохранением последней ошибки
+// и удаляет его задание из очереди. Окончательные статусы не изменяются.
+// Если задание арендовано не owner, заказ не изменяется и возвращается domain.ErrLeaseLost.
+func (s *MemoryStorage) MarkOrderStuck(ctx context.Context, orderNumber, owner string, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderNumber]
+	if !ok {
+		return domain.ErrOrderNotFound
+	}
+	if job, ok := s.jobs[orderNumber]; !ok || job.LockedBy != owner {
+		return domain.ErrLeaseLost
+	}
+
+	if order.Status != domain.StatusProcessed && order.Status != domain.StatusInvalid {
+		if order.Status != domain.StatusStuck {
+			s.addTransition(orderNumber, domain.OrderStatusTransition{
+				From:   order.Status,
+				To:     domain.StatusStuck,
+				Source: domain.StatusSourceRetryPolicy,
+			})
+		}
+		order.Status = domain.StatusStuck
+		order.LastError = lastError
+	}
+
+	delete(s.jobs, orderNumber)
+	return nil
+}
+
+// TryLockReconciliation берет блокировку сверки, если ее не держит другой проход
+func (s *MemoryStorage) TryLockReconciliation(ctx context.Context) (func(), error) {
+	if !s.reconcileMu.TryLock() {
+		return nil, domain.ErrReconciliationLocked
+	}
+	return s.reconcileMu.Unlock, nil
+}
+
+// GetProcessedOrdersSince возвращает до limit заказов, обработанных начиная с since, от новых к старым.
+// Если задан after, возвращаются заказы, следующие за ним.
+func (s *MemoryStorage) GetProcessedOrdersSince(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []domain.Order
+	for _, order := range s.orders {
+		if order.Status != domain.StatusProcessed || order.ProcessedAt == nil || order.ProcessedAt.Before(since) {
+			continue
+		}
+		if after != nil && !processedBefore(*order, after.ProcessedAt, after.Number) {
+			continue
+		}
+		orders = append(orders, *order)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return processedBefore(orders[j], *orders[i].ProcessedAt, orders[i].Number)
+	})
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders, nil
+}
+
+// processedBefore сообщает, что обработанный заказ order следует при сверке после позиции
+// (processedAt, number)
+func processedBefore(order domain.Order, processedAt time.Time, number string) bool {
+	if !order.ProcessedAt.Equal(processedAt) {
+		return order.ProcessedAt.Before(processedAt)
+	}
+	return order.Number < number
+}
+
+// ApplyAccrualAdjustment заменяет начисление по обработанному заказу на newAccrual и проводит
+// разницу по балансу владельца. Если начисление уже отличается от previousAccrual,
+// возвращается domain.ErrAccrualChanged и ничего не меняется.
+func (s *MemoryStorage) ApplyAccrualAdjustment(ctx context.Context, orderNumber string, previousAccrual, newAccrual domain.Money) (*domain.AccrualAdjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderNumber]
+	if !ok {
+		return nil, domain.ErrOrderNotFound
+	}
+	if order.Status != domain.StatusProcessed || order.Accrual != previousAccrual {
+		return nil, domain.ErrAccrualChanged
+	}
+
+	adjustment := domain.AccrualAdjustment{
+		ID:              int64(len(s.adjustments) + 1),
+		OrderNumber:     orderNumber,
+		UserID:          order.UserID,
+		PreviousAccrual: order.Accrual,
+		NewAccrual:      newAccrual,
+		Amount:          newAccrual - order.Accrual,
+		CreatedAt:       time.Now(),
+	}
+
+	balance := s.balance(order.UserID)
+	newCurrent, newDebt, incurred := adjustBalance(balance.current, balance.debt, adjustment.Amount)
+	adjustment.Debt = incurred
+
+	// Долг учитывается на своём счёте с отрицательным остатком
+	kind := domain.LedgerAdjustment
+	if adjustment.Amount < 0 {
+		kind = domain.LedgerReversal
+	}
+	err := s.postLedger(order.UserID, kind,
+		ledgerSource{orderNumber: orderNumber, adjustmentID: adjustment.ID},
+		ledgerLeg{account: domain.AccountAccrualSystem, amount: -adjustment.Amount},
+		ledgerLeg{account: domain.AccountCurrent, amount: newCurrent - balance.current},
+		ledgerLeg{account: domain.AccountDebt, amount: balance.debt - newDebt},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	order.Accrual = newAccrual
+	s.addTransition(orderNumber, domain.OrderStatusTransition{
+		From:    domain.StatusProcessed,
+		To:      domain.StatusProcessed,
+		Accrual: newAccrual,
+		Source:  domain.StatusSourceReconciliation,
+	})
+	s.adjustments = append(s.adjustments, adjustment)
+
+	return &adjustment, nil
+}
+
+// SaveReconciliationReport сохраняет отчет завершенного прохода сверки
+func (s *MemoryStorage) SaveReconciliationReport(ctx context.Context, report *domain.ReconciliationReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *report
+	saved.Discrepancies = append([]domain.AccrualDiscrepancy{}, report.Discrepancies...)
+	s.lastReport = &saved
+	return nil
+}
+
+// GetLastReconciliationReport возвращает отчет последнего сохраненного прохода сверки
+func (s *MemoryStorage) GetLastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastReport == nil {
+		return nil, domain.ErrReconciliationReportNotFound
+	}
+	found := *s.lastReport
+	found.Discrepancies = append([]domain.AccrualDiscrepancy{}, s.lastReport.Discrepancies...)
+	return &found, nil
+}
+
+// RequeueOrder возвращает заказ в очередь опроса с немедленной первой попыткой и записывает
+// операцию в журнал. Заказы в INVALID и STUCK переводятся в NEW; начисленный заказ
+// в очередь не возвращается.
+func (s *MemoryStorage) RequeueOrder(ctx context.Context, number string, entry domain.AdminAuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[number]
+	if !ok {
+		return domain.ErrOrderNotFound
+	}
+	if order.Status == domain.StatusProcessed {
+		return domain.ErrOrderAlreadyProcessed
+	}
+
+	s.requeueOrder(order)
+
+	entry.OrderNumber = number
+	entry.Affected = 1
+	s.addAuditEntry(entry)
+	return nil
+}
+
+// RequeueOrders возвращает в очередь все заказы, подходящие под filter, и записывает
+// операцию в журнал одной записью. Возвращает число затронутых заказов.
+func (s *MemoryStorage) RequeueOrders(ctx context.Context, filter domain.RequeueFilter, entry domain.AdminAuditEntry) (int, error) {
+	if filter.Status == domain.StatusProcessed {
+		return 0, domain.ErrOrderAlreadyProcessed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var selected []*domain.Order
+	for _, order := range s.orders {
+		if order.Status != filter.Status {
+			continue
+		}
+		if !filter.UploadedFrom.IsZero() && order.UploadedAt.Before(filter.UploadedFrom) {
+			continue
+		}
+		if !filter.UploadedTo.IsZero() && !order.UploadedAt.Before(filter.UploadedTo) {
+			continue
+		}
+		selected = append(selected, order)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].UploadedAt.Before(selected[j].UploadedAt)
+	})
+
+	for _, order := range selected {
+		s.requeueOrder(order)
+	}
+
+	entry.Affected = len(selected)
+	s.addAuditEntry(entry)
+	return len(selected), nil
+}
+
+// ForceOrderStatus вручную устанавливает заказу окончательный статус через тот же путь,
+// что и результат опроса, включая зачисление на баланс, и записывает операцию в журнал
+func (s *MemoryStorage) ForceOrderStatus(ctx context.Context, change domain.OrderStatusChange, entry domain.AdminAuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[change.OrderNumber]
+	if !ok {
+		return domain.ErrOrderNotFound
+	}
+
+	// Начисление уже зачислено на баланс: исправления проводятся только сверкой
+	if order.Status == domain.StatusProcessed {
+		return domain.ErrOrderAlreadyProcessed
+	}
+
+	if err := s.applyStatusChange(order, change, order.UserID); err != nil {
+		return err
+	}
+
+	entry.OrderNumber = change.OrderNumber
+	entry.Affected = 1
+	s.addAuditEntry(entry)
+	return nil
+}
+
+// requeueOrder ставит заказ в очередь заново со сброшенными счетчиками. Вызывается под s.mu.
+func (s *MemoryStorage) requeueOrder(order *domain.Order) {
+	if order.Status == domain.StatusInvalid || order.Status == domain.StatusStuck {
+		s.addTransition(order.Number, domain.OrderStatusTransition{
+			From:   order.Status,
+			To:     domain.StatusNew,
+			Source: domain.StatusSourceAdmin,
+		})
+		order.Status = domain.StatusNew
+		order.LastError = ""
+		order.ProcessedAt = nil
+	}
+
+	now := time.Now()
+	s.jobs[order.Number] = &domain.AccrualJob{
+		OrderNumber:   order.Number,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+// addAuditEntry записывает административную операцию в журнал. Вызывается под s.mu.
+func (s *MemoryStorage) addAuditEntry(entry domain.AdminAuditEntry) {
+	entry.ID = int64(len(s.auditLog) + 1)
+	entry.CreatedAt = time.Now()
+	s.auditLog = append(s.auditLog, entry)
+}
+
+// GetBalance возвращает баланс пользователя; у пользователя без операций он нулевой
+func (s *MemoryStorage) GetBalance(ctx context.Context, userID int64) (*domain.Balance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balance, ok := s.balances[userID]
+	if !ok {
+		return &domain.Balance{}, nil
+	}
+	return &domain.Balance{Current: balance.current, Withdrawn: balance.withdrawn}, nil
+}
+
+// CreateWithdrawal проводит списание sum в счёт заказа orderNumber с той же обработкой
+// повторов и ключей идемпотентности, что и PostgresRepository.CreateWithdrawal
+func (s *MemoryStorage) CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum domain.Money, idempotencyKey string) (*domain.Withdrawal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := memoryIdempotencyKey{userID: userID, key: idempotencyKey}
+
+	if idempotencyKey != "" {
+		if record, ok := s.idempotencyKeys[key]; ok && now.Sub(record.createdAt) < domain.IdempotencyKeyTTL {
+			if record.failure != nil {
+				if record.orderNumber != orderNumber || record.sum != sum {
+					return nil, domain.ErrIdempotencyKeyReused
+				}
+				return nil, record.failure
+			}
+			w := s.withdrawals[record.withdrawal].withdrawal
+			if w.OrderNumber != orderNumber || w.Sum != sum {
+				return nil, domain.ErrIdempotencyKeyReused
+			}
+			w.Replayed = true
+			return &w, nil
+		}
+	}
+
+	index, exists := s.orderWithdrawal[orderNumber]
+	if exists {
+		// Списание по заказу уже проведено
+		existing := s.withdrawals[index]
+		if existing.userID != userID || existing.withdrawal.Sum != sum {
+			return nil, s.rejectWithdrawal(key, orderNumber, sum, domain.ErrWithdrawalConflict, now)
+		}
+	} else {
+		if s.balance(userID).current < sum {
+			return nil, s.rejectWithdrawal(key, orderNumber, sum, domain.ErrInsufficientFunds, now)
+		}
+
+		err := s.postLedger(userID, domain.LedgerWithdrawal,
+			ledgerSource{orderNumber: orderNumber},
+			ledgerLeg{account: domain.AccountCurrent, amount: -sum},
+			ledgerLeg{account: domain.AccountWithdrawn, amount: sum},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		index = len(s.withdrawals)
+		s.withdrawals = append(s.withdrawals, memoryWithdrawal{
+			userID: userID,
+			withdrawal: domain.Withdrawal{
+				ID:          int64(index + 1),
+				OrderNumber: orderNumber,
+				Sum:         sum,
+				ProcessedAt: now,
+			},
+		})
+		s.orderWithdrawal[orderNumber] = index
+	}
+
+	if idempotencyKey != "" {
+		s.idempotencyKeys[key] = memoryIdempotencyRecord{withdrawal: index, createdAt: now}
+	}
+
+	w := s.withdrawals[index].withdrawal
+	w.Replayed = exists
+	return &w, nil
+}
+
+// rejectWithdrawal запоминает отказ в списании с непустым ключом идемпотентности и возвращает
+// его. Вызывается под s.mu.
+func (s *MemoryStorage) rejectWithdrawal(key memoryIdempotencyKey, orderNumber string, sum domain.Money, failure error, now time.Time) error {
+	if key.key != "" {
+		s.idempotencyKeys[key] = memoryIdempotencyRecord{
+			orderNumber: orderNumber,
+			sum:         sum,
+			failure:     failure,
+			createdAt:   now,
+		}
+	}
+	return failure
+}
+
+// GetUserWithdrawals возвращает до limit списаний пользователя, отобранных filter, от новых
+// к старым, начиная со списания, следующего за after; after = nil - с самого нового, limit <= 0 - все
+func (s *MemoryStorage) GetUserWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, after *domain.WithdrawalCursor, limit int) ([]domain.Withdrawal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var withdrawals []domain.Withdrawal
+	for _, w := range s.withdrawals {
+		if w.userID != userID || !inInterval(w.withdrawal.ProcessedAt, filter.ProcessedFrom, filter.ProcessedTo) {
+			continue
+		}
+		if after != nil && !withdrawalBefore(&w.withdrawal, after.ProcessedAt, after.ID) {
+			continue
+		}
+		withdrawals = append(withdrawals, w.withdrawal)
+	}
+
+	sort.Slice(withdrawals, func(i, j int) bool {
+		return withdrawalBefore(&withdrawals[j], withdrawals[i].ProcessedAt, withdrawals[i].ID)
+	})
+	if limit > 0 && len(withdrawals) > limit {
+		withdrawals = withdrawals[:limit]
+	}
+	return withdrawals, nil
+}
+
+// withdrawalBefore сообщает, идет ли w в списке после списания id, проведенного в processedAt
+func withdrawalBefore(w *domain.Withdrawal, processedAt time.Time, id int64) bool {
+	if !w.ProcessedAt.Equal(processedAt) {
+		return w.ProcessedAt.Before(processedAt)
+	}
+	return w.ID < id
+}
+
+// GetUserTransactions возвращает до limit операций пользователя от новых к старым,
+// начиная с операции, предшествующей before; before = 0 - с самой новой
+func (s *MemoryStorage) GetUserTransactions(ctx context.Context, userID int64, before int64, limit int) ([]domain.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []domain.Transaction
+	for i := len(s.ledger) - 1; i >= 0; i-- {
+		entry := s.ledger[i]
+		if entry.userID != userID || (before > 0 && entry.transactionID >= before) {
+			continue
+		}
+
+		// Записи одной операции идут в журнале подряд
+		last := len(transactions) - 1
+		if last < 0 || transactions[last].ID != entry.transactionID {
+			if len(transactions) == limit {
+				break
+			}
+			transactions = append(transactions, domain.Transaction{
+				ID:          entry.transactionID,
+				Kind:        entry.kind,
+				OrderNumber: entry.source.orderNumber,
+				CreatedAt:   entry.createdAt,
+			})
+			last++
+		}
+
+		switch entry.account {
+		case domain.AccountCurrent:
+			transactions[last].Amount += entry.amount
+		case domain.AccountDebt:
+			transactions[last].Debt -= entry.amount
+		}
+	}
+	return transactions, nil
+}
+
+// balance возвращает остатки счетов пользователя, создавая их при необходимости.
+// Вызывается под s.mu.
+func (s *MemoryStorage) balance(userID int64) *memoryBalance {
+	balance, ok := s.balances[userID]
+	if !ok {
+		balance = &memoryBalance{}
+		s.balances[userID] = balance
+	}
+	return balance
+}
+
+// postLedger проводит операцию kind по счетам пользователя userID и обновляет его остатки.
+// Записи с нулевой суммой пропускаются; сумма записей должна быть равна нулю. Вызывается под s.mu.
+func (s *MemoryStorage) postLedger(userID int64, kind domain.LedgerEntryKind, source ledgerSource, legs ...ledgerLeg) error {
+	var total domain.Money
+	for _, leg := range legs {
+		total += leg.amount
+	}
+	if total != 0 {
+		return fmt.Errorf("error recording %s: unbalanced ledger transaction, legs add up to %s", kind, total)
+	}
+
+	s.nextTransactionID++
+	now := time.Now()
+	balance := s.balance(userID)
+
+	for _, leg := range legs {
+		if leg.amount == 0 {
+			continue
+		}
+		s.ledger = append(s.ledger, memoryLedgerEntry{
+			transactionID: s.nextTransactionID,
+			userID:        userID,
+			account:       leg.account,
+			kind:          kind,
+			amount:        leg.amount,
+			source:        source,
+			createdAt:     now,
+		})
+
+		switch leg.account {
+		case domain.AccountCurrent:
+			balance.current += leg.amount
+		case domain.AccountWithdrawn:
+			balance.withdrawn += leg.amount
+		case domain.AccountDebt:
+			balance.debt -= leg.amount
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"testing"
+
+	"gophermart/internal/storage/storagetest"
+	"gophermart/internal/usecase"
+)
+
+func TestMemoryStorage_Contract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) usecase.Storage {
+		return NewMemoryStorage()
+	})
+}
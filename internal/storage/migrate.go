@@ -3,20 +3,45 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+
+	"gophermart/migrations"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+// MigrationStatus описывает состояние схемы базы данных
+type MigrationStatus struct {
+	// Version - последняя примененная миграция, 0 - миграции не применялись
+	Version uint
+	// Dirty - миграция Version завершилась ошибкой, схему нужно исправить вручную
+	// и отметить командой force
+	Dirty bool
+	// Latest - последняя миграция, встроенная в исполняемый файл
+	Latest uint
+}
+
+// newMigrate создает экземпляр migrate со встроенными миграциями
+func newMigrate(databaseURL string) (*migrate.Migrate, error) {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	return m, nil
+}
+
 // RunMigrations запускает миграции базы данных
-func RunMigrations(databaseURL, migrationsPath string) error {
-	m, err := migrate.New(
-		"file://"+migrationsPath,
-		databaseURL,
-	)
+func RunMigrations(databaseURL string) error {
+	m, err := newMigrate(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
 	}
 	defer m.Close()
 
@@ -27,20 +52,99 @@ func RunMigrations(databaseURL, migrationsPath string) error {
 	return nil
 }
 
-// RollbackMigrations откатывает все миграции
-func RollbackMigrations(databaseURL, migrationsPath string) error {
-	m, err := migrate.New(
-		"file://"+migrationsPath,
-		databaseURL,
-	)
+// RollbackMigrations откатывает steps последних миграций; steps = 0 откатывает все
+func RollbackMigrations(databaseURL string, steps int) error {
+	m, err := newMigrate(databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
 	}
 	defer m.Close()
 
-	if err := m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+	if steps > 0 {
+		err = m.Steps(-steps)
+	} else {
+		err = m.Down()
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return fmt.Errorf("failed to rollback migrations: %w", err)
 	}
 
 	return nil
 }
+
+// MigrateTo применяет или откатывает миграции до версии version
+func MigrateTo(databaseURL string, version uint) error {
+	m, err := newMigrate(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// ForceMigrationVersion записывает version как текущую версию схемы и снимает признак
+// незавершенной миграции, не выполняя SQL. Версия -1 означает, что миграции не применялись.
+func ForceMigrationVersion(databaseURL string, version int) error {
+	m, err := newMigrate(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// GetMigrationStatus возвращает текущую версию схемы и последнюю встроенную миграцию
+func GetMigrationStatus(databaseURL string) (*MigrationStatus, error) {
+	m, err := newMigrate(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+
+	var status MigrationStatus
+	status.Version, status.Dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	status.Latest, err = latestMigration()
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// latestMigration возвращает номер последней встроенной миграции
+func latestMigration() (uint, error) {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	for {
+		next, err := source.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+		}
+		version = next
+	}
+}
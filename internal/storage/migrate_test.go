@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gophermart/migrations"
+)
+
+func TestLatestMigration(t *testing.T) {
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+
+	// Номера миграций идут подряд с первой, последняя равна их количеству
+	last := files[len(files)-1]
+	want, err := strconv.ParseUint(last[:strings.Index(last, "_")], 10, 64)
+	if err != nil {
+		t.Fatalf("unexpected migration file name %q", last)
+	}
+	if int(want) != len(files) {
+		t.Errorf("expected %d migrations without gaps, got latest %s", len(files), last)
+	}
+
+	got, err := latestMigration()
+	if err != nil {
+		t.Fatalf("latestMigration() error = %v", err)
+	}
+	if uint64(got) != want {
+		t.Errorf("expected latest migration %d, got %d", want, got)
+	}
+}
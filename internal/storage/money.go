@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+
+	"gophermart/internal/domain"
+)
+
+// moneyArg передает сумму в запрос десятичной строкой. Без преобразования pgx записал бы
+// domain.Money в столбец DECIMAL как целое число копеек.
+func moneyArg(m domain.Money) string {
+	return m.String()
+}
+
+// moneyScanner читает значение DECIMAL в domain.Money без промежуточного float64
+type moneyScanner struct {
+	dst *domain.Money
+}
+
+// scanMoney возвращает приемник для Scan, записывающий сумму в dst
+func scanMoney(dst *domain.Money) moneyScanner {
+	return moneyScanner{dst: dst}
+}
+
+// Scan реализует sql.Scanner. NULL читается как нулевая сумма.
+func (s moneyScanner) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*s.dst = 0
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case int64:
+		*s.dst = domain.Rubles(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+
+	m, err := domain.ParseMoney(text)
+	if err != nil {
+		return fmt.Errorf("error scanning money: %w", err)
+	}
+	*s.dst = m
+	return nil
+}
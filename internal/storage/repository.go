@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// reconciliationLockID - ключ advisory-блокировки, под которой выполняется сверка начислений
+const reconciliationLockID int64 = 0x676d_7265_636f_6e63
+
 // PostgresRepository реализует интерфейс Storage для PostgreSQL
 type PostgresRepository struct {
 	pool *pgxpool.Pool
@@ -73,41 +77,91 @@ func (r *PostgresRepository) GetUserByLogin(ctx context.Context, login string) (
 	).Scan(&user.ID, &user.Login, &user.PasswordHash, &user.CreatedAt)
 
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("error getting user by login: %w", domain.ErrUserNotFound)
+		}
 		return nil, fmt.Errorf("error getting user by login: %w", err)
 	}
 	return &user, nil
 }
 
-// CreateOrder создает новый заказ
-func (r *PostgresRepository) CreateOrder(ctx context.Context, userID int64, number string) error {
-	_, err := r.pool.Exec(ctx,
+// CreateOrder создает новый заказ и ставит его в очередь на опрос системы начислений.
+// Первый опрос выполняется не раньше firstAttemptAt.
+func (r *PostgresRepository) CreateOrder(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
+	// Получаем соединение из пула
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+
+	_, err = tx.Exec(ctx,
 		`INSERT INTO orders (number, user_id, status, uploaded_at) 
 		 VALUES ($1, $2, $3, $4)`,
-		number, userID, domain.StatusNew, time.Now(),
+		number, userID, domain.StatusNew, now,
 	)
 	if err != nil {
+		if pqErr, ok := err.(*pgconn.PgError); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("error creating order: %w", domain.ErrOrderExists)
+		}
 		return fmt.Errorf("error creating order: %w", err)
 	}
+
+	// Задание создается в той же транзакции, чтобы заказ не потерялся при перезапуске
+	_, err = tx.Exec(ctx,
+		`INSERT INTO accrual_jobs (order_number, next_attempt_at, created_at) 
+		 VALUES ($1, $2, $3)`,
+		number, firstAttemptAt, now,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating accrual job: %w", err)
+	}
+
+	err = insertStatusTransition(ctx, tx, number, domain.OrderStatusTransition{
+		To:     domain.StatusNew,
+		Source: domain.StatusSourceUpload,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Фиксируем транзакцию
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
 	return nil
 }
 
-// GetOrderByNumber находит заказ по номеру
+// GetOrderByNumber находит заказ по номеру вместе со временем следующей проверки начисления
 func (r *PostgresRepository) GetOrderByNumber(ctx context.Context, number string) (*domain.Order, error) {
 	var order domain.Order
-	var processedAt sql.NullTime
+	var processedAt, nextCheckAt sql.NullTime
 
 	err := r.pool.QueryRow(ctx,
-		`SELECT number, user_id, status, accrual, uploaded_at, processed_at 
-		 FROM orders 
-		 WHERE number = $1`,
+		`SELECT o.number, o.user_id, o.status, o.accrual, o.uploaded_at, o.processed_at, 
+		        COALESCE(o.last_error, ''), j.next_attempt_at 
+		 FROM orders o 
+		 LEFT JOIN accrual_jobs j ON j.order_number = o.number 
+		 WHERE o.number = $1`,
 		number,
 	).Scan(
 		&order.Number,
 		&order.UserID,
 		&order.Status,
-		&order.Accrual,
+		scanMoney(&order.Accrual),
 		&order.UploadedAt,
 		&processedAt,
+		&order.LastError,
+		&nextCheckAt,
 	)
 
 	if err != nil {
@@ -120,18 +174,43 @@ func (r *PostgresRepository) GetOrderByNumber(ctx context.Context, number string
 	if processedAt.Valid {
 		order.ProcessedAt = &processedAt.Time
 	}
+	if nextCheckAt.Valid {
+		order.NextCheckAt = &nextCheckAt.Time
+	}
 
 	return &order, nil
 }
 
-// GetUserOrders возвращает все заказы пользователя
-func (r *PostgresRepository) GetUserOrders(ctx context.Context, userID int64) ([]domain.Order, error) {
+// GetUserOrders возвращает до limit заказов пользователя, отобранных filter, от новых к старым,
+// начиная с заказа, следующего за after; after = nil - с самого нового, limit <= 0 - все
+func (r *PostgresRepository) GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, after *domain.OrderCursor, limit int) ([]domain.Order, error) {
+	var statuses []string
+	for _, status := range filter.Statuses {
+		statuses = append(statuses, string(status))
+	}
+	var afterUploadedAt time.Time
+	var afterNumber string
+	if after != nil {
+		afterUploadedAt, afterNumber = after.UploadedAt, after.Number
+	}
+
 	rows, err := r.pool.Query(ctx,
-		`SELECT number, user_id, status, accrual, uploaded_at, processed_at 
-		 FROM orders 
-		 WHERE user_id = $1 
-		 ORDER BY uploaded_at DESC`,
-		userID,
+		`SELECT o.number, o.user_id, o.status, o.accrual, o.uploaded_at, o.processed_at, j.next_attempt_at 
+		 FROM orders o 
+		 LEFT JOIN accrual_jobs j ON j.order_number = o.number 
+		 WHERE o.user_id = $1 
+		   AND ($2::text[] IS NULL OR o.status = ANY($2)) 
+		   AND ($3::timestamptz IS NULL OR o.uploaded_at >= $3) 
+		   AND ($4::timestamptz IS NULL OR o.uploaded_at < $4) 
+		   AND ($5::timestamptz IS NULL OR o.processed_at >= $5) 
+		   AND ($6::timestamptz IS NULL OR o.processed_at < $6) 
+		   AND ($7::timestamptz IS NULL OR (o.uploaded_at, o.number) < ($7, $8::text)) 
+		 ORDER BY o.uploaded_at DESC, o.number DESC 
+		 LIMIT $9`,
+		userID, statuses,
+		nullTime(filter.UploadedFrom), nullTime(filter.UploadedTo),
+		nullTime(filter.ProcessedFrom), nullTime(filter.ProcessedTo),
+		nullTime(afterUploadedAt), afterNumber, nullLimit(limit),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user orders: %w", err)
@@ -141,15 +220,16 @@ func (r *PostgresRepository) GetUserOrders(ctx context.Context, userID int64) ([
 	var orders []domain.Order
 	for rows.Next() {
 		var order domain.Order
-		var processedAt sql.NullTime
+		var processedAt, nextCheckAt sql.NullTime
 
 		err := rows.Scan(
 			&order.Number,
 			&order.UserID,
 			&order.Status,
-			&order.Accrual,
+			scanMoney(&order.Accrual),
 			&order.UploadedAt,
 			&processedAt,
+			&nextCheckAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning order: %w", err)
@@ -158,6 +238,9 @@ func (r *PostgresRepository) GetUserOrders(ctx context.Context, userID int64) ([
 		if processedAt.Valid {
 			order.ProcessedAt = &processedAt.Time
 		}
+		if nextCheckAt.Valid {
+			order.NextCheckAt = &nextCheckAt.Time
+		}
 
 		orders = append(orders, order)
 	}
@@ -169,8 +252,11 @@ func (r *PostgresRepository) GetUserOrders(ctx context.Context, userID int64) ([
 	return orders, nil
 }
 
-// UpdateOrderStatusAndBalance атомарно обновляет статус заказа и баланс пользователя
-func (r *PostgresRepository) UpdateOrderStatusAndBalance(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error {
+// UpdateOrderStatusAndBalance атомарно обновляет статус заказа и баланс пользователя.
+// Смена статуса записывается в историю заказа.
+func (r *PostgresRepository) UpdateOrderStatusAndBalance(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+	number := change.OrderNumber
+
 	// Получаем соединение из пула
 	conn, err := r.pool.Acquire(ctx)
 	if err != nil {
@@ -184,51 +270,295 @@ func (r *PostgresRepository) UpdateOrderStatusAndBalance(ctx context.Context, nu
 	}
 	defer tx.Rollback(ctx)
 
+	// Блокируем заказ, чтобы параллельные обработчики применяли результат по очереди
+	var currentStatus domain.OrderStatus
+	err = tx.QueryRow(ctx,
+		`SELECT status FROM orders WHERE number = $1 FOR UPDATE`,
+		number,
+	).Scan(&currentStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrOrderNotFound
+		}
+		return fmt.Errorf("error locking order: %w", err)
+	}
+
+	// Окончательный статус уже применен другим обработчиком: повторно баланс не начисляем
+	if currentStatus == domain.StatusProcessed || currentStatus == domain.StatusInvalid {
+		_, err = tx.Exec(ctx,
+			`DELETE FROM accrual_jobs WHERE order_number = $1`,
+			number,
+		)
+		if err != nil {
+			return fmt.Errorf("error deleting accrual job: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("error committing transaction: %w", err)
+		}
+		return nil
+	}
+
+	if err := applyStatusChange(ctx, tx, change, currentStatus, userID); err != nil {
+		return err
+	}
+
+	// Фиксируем транзакцию
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// applyStatusChange применяет результат расчёта к заказу, заблокированному в транзакции tx:
+// обновляет статус, пишет историю, удаляет задание для окончательного статуса и
+// зачисляет начисление на баланс userID. Начисление сначала гасит долг пользователя.
+func applyStatusChange(ctx context.Context, tx pgx.Tx, change domain.OrderStatusChange, currentStatus domain.OrderStatus, userID int64) error {
+	number, status, accrual := change.OrderNumber, change.Status, change.Accrual
+
+	// Время обработки есть только у заказа с окончательным статусом
+	var processedAt time.Time
+	if status == domain.StatusProcessed || status == domain.StatusInvalid {
+		processedAt = time.Now()
+	}
+
 	// Обновляем статус заказа
-	_, err = tx.Exec(ctx,
+	_, err := tx.Exec(ctx,
 		`UPDATE orders 
          SET status = $1, accrual = $2, processed_at = $3 
          WHERE number = $4`,
-		status, accrual, time.Now(), number,
+		status, moneyArg(accrual), nullTime(processedAt), number,
 	)
 	if err != nil {
 		return fmt.Errorf("error updating order status: %w", err)
 	}
 
-	// Если статус PROCESSED и есть начисление, обновляем баланс
+	// Повторный опрос с тем же статусом переходом не считается
+	if status != currentStatus {
+		err = insertStatusTransition(ctx, tx, number, domain.OrderStatusTransition{
+			From:    currentStatus,
+			To:      status,
+			Accrual: accrual,
+			Source:  change.Source,
+			Attempt: change.Attempt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Окончательный статус больше не требует опроса системы начислений
+	if status == domain.StatusProcessed || status == domain.StatusInvalid {
+		_, err = tx.Exec(ctx,
+			`DELETE FROM accrual_jobs WHERE order_number = $1`,
+			number,
+		)
+		if err != nil {
+			return fmt.Errorf("error deleting accrual job: %w", err)
+		}
+	}
+
+	// Если статус PROCESSED и есть начисление, обновляем баланс.
+	// Создание без обновления исключает гонку при одновременном создании записи баланса
+	// из разных реплик, блокировка строки - гонку с другими изменениями баланса.
 	if status == domain.StatusProcessed && accrual > 0 {
-		// Проверяем существование записи в таблице balances
-		var exists bool
-		err := tx.QueryRow(ctx,
-			`SELECT EXISTS(SELECT 1 FROM balances WHERE user_id = $1)`,
+		_, err = tx.Exec(ctx,
+			`INSERT INTO balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`,
+			userID,
+		)
+		if err != nil {
+			return fmt.Errorf("error creating balance: %w", err)
+		}
+
+		var current, debt domain.Money
+		err = tx.QueryRow(ctx,
+			`SELECT current, debt FROM balances WHERE user_id = $1 FOR UPDATE`,
 			userID,
-		).Scan(&exists)
+		).Scan(scanMoney(&current), scanMoney(&debt))
 		if err != nil {
-			return fmt.Errorf("error checking balance existence: %w", err)
-		}
-
-		if exists {
-			// Обновляем существующий баланс
-			_, err = tx.Exec(ctx,
-				`UPDATE balances 
-                 SET current = current + $1 
-                 WHERE user_id = $2`,
-				accrual, userID,
-			)
-		} else {
-			// Создаем новую запись баланса
-			_, err = tx.Exec(ctx,
-				`INSERT INTO balances (user_id, current, withdrawn) 
-                 VALUES ($1, $2, 0)`,
-				userID, accrual,
-			)
+			return fmt.Errorf("error locking balance: %w", err)
 		}
+
+		newCurrent, newDebt, _ := adjustBalance(current, debt, accrual)
+
+		_, err = tx.Exec(ctx,
+			`UPDATE balances SET current = $1, debt = $2 WHERE user_id = $3`,
+			moneyArg(newCurrent), moneyArg(newDebt), userID,
+		)
 		if err != nil {
 			return fmt.Errorf("error updating balance: %w", err)
 		}
+
+		err = insertLedgerTransaction(ctx, tx, userID, domain.LedgerAccrual,
+			ledgerSource{orderNumber: number},
+			ledgerLeg{account: domain.AccountAccrualSystem, amount: -accrual},
+			ledgerLeg{account: domain.AccountCurrent, amount: newCurrent - current},
+			ledgerLeg{account: domain.AccountDebt, amount: debt - newDebt},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClaimAccrualJobs арендует для owner задания, время очередной попытки которых наступило.
+// Задания, заблокированные другой транзакцией или арендованные другой репликой, пропускаются.
+func (r *PostgresRepository) ClaimAccrualJobs(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error) {
+	now := time.Now()
+
+	rows, err := r.pool.Query(ctx,
+		`UPDATE accrual_jobs j 
+		 SET locked_by = $1, locked_until = $2 
+		 FROM orders o 
+		 WHERE o.number = j.order_number 
+		   AND j.order_number IN (
+		     SELECT order_number 
+		     FROM accrual_jobs 
+		     WHERE next_attempt_at <= $3 
+		       AND (locked_until IS NULL OR locked_until < $3) 
+		     ORDER BY next_attempt_at 
+		     LIMIT $4 
+		     FOR UPDATE SKIP LOCKED
+		 ) 
+		 RETURNING j.order_number, j.attempts, j.next_attempt_at, COALESCE(j.last_error, ''), 
+		           COALESCE(j.error_class, ''), j.class_attempts, j.created_at, j.locked_by, j.locked_until, 
+		           o.uploaded_at`,
+		owner, now.Add(leaseTTL), now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming accrual jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []domain.AccrualJob
+	for rows.Next() {
+		var job domain.AccrualJob
+		err := rows.Scan(
+			&job.OrderNumber,
+			&job.Attempts,
+			&job.NextAttemptAt,
+			&job.LastError,
+			&job.ErrorClass,
+			&job.ClassAttempts,
+			&job.CreatedAt,
+			&job.LockedBy,
+			&job.LockedUntil,
+			&job.UploadedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning accrual job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accrual jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// RescheduleAccrualJob откладывает задание до nextAttemptAt, сохраняет класс и текст
+// последней ошибки и снимает аренду. Счетчик попыток класса сбрасывается при смене класса.
+// Если задание арендовано не owner, оно не изменяется и возвращается domain.ErrLeaseLost.
+func (r *PostgresRepository) RescheduleAccrualJob(ctx context.Context, orderNumber, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE accrual_jobs 
+		 SET attempts = attempts + 1, 
+		     class_attempts = CASE WHEN error_class = $2 THEN class_attempts + 1 ELSE 1 END, 
+		     error_class = $2, 
+		     next_attempt_at = $1, 
+		     last_error = NULLIF($3, ''), 
+		     locked_by = NULL, locked_until = NULL 
+		 WHERE order_number = $4 AND locked_by = $5`,
+		nextAttemptAt, errorClass, lastError, orderNumber, owner,
+	)
+	if err != nil {
+		return fmt.Errorf("error rescheduling accrual job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+	return nil
+}
+
+// ExtendAccrualLeases продлевает до lockedUntil действующую аренду всех заданий owner.
+// Истекшая аренда не возобновляется: задание могла уже забрать другая реплика.
+func (r *PostgresRepository) ExtendAccrualLeases(ctx context.Context, owner string, lockedUntil time.Time) error {
+	_, err := r.pool.Exec(ctx,
+		`UPDATE accrual_jobs 
+		 SET locked_until = $2 
+		 WHERE locked_by = $1 AND locked_until > $3 AND locked_until < $2`,
+		owner, lockedUntil, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("error extending accrual job leases: %w", err)
+	}
+	return nil
+}
+
+// MarkOrderStuck переводит заказ в статус STUCK с сохранением последней ошибки
+// и удаляет его задание из очереди. Окончательные статусы не изменяются.
+// Если задание арендовано не owner, заказ не изменяется и возвращается domain.ErrLeaseLost.
+func (r *PostgresRepository) MarkOrderStuck(ctx context.Context, orderNumber, owner string, lastError string) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var currentStatus domain.OrderStatus
+	err = tx.QueryRow(ctx,
+		`SELECT status FROM orders WHERE number = $1 FOR UPDATE`,
+		orderNumber,
+	).Scan(&currentStatus)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrOrderNotFound
+		}
+		return fmt.Errorf("error locking order: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM accrual_jobs WHERE order_number = $1 AND locked_by = $2`,
+		orderNumber, owner,
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting accrual job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	if currentStatus != domain.StatusProcessed && currentStatus != domain.StatusInvalid {
+		_, err = tx.Exec(ctx,
+			`UPDATE orders SET status = $1, last_error = $2 WHERE number = $3`,
+			domain.StatusStuck, lastError, orderNumber,
+		)
+		if err != nil {
+			return fmt.Errorf("error marking order stuck: %w", err)
+		}
+
+		if currentStatus != domain.StatusStuck {
+			err = insertStatusTransition(ctx, tx, orderNumber, domain.OrderStatusTransition{
+				From:   currentStatus,
+				To:     domain.StatusStuck,
+				Source: domain.StatusSourceRetryPolicy,
+			})
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	// Фиксируем транзакцию
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
@@ -236,88 +566,569 @@ func (r *PostgresRepository) UpdateOrderStatusAndBalance(ctx context.Context, nu
 	return nil
 }
 
-// GetBalance возвращает баланс пользователя
+// GetOrderStatusHistory возвращает историю статусов заказа в порядке изменений
+func (r *PostgresRepository) GetOrderStatusHistory(ctx context.Context, number string) ([]domain.OrderStatusTransition, error) {
+	rows, err := r.pool.Query(ctx,
+		`SELECT COALESCE(from_status, ''), to_status, COALESCE(accrual, 0), source, COALESCE(attempt, 0), changed_at 
+		 FROM order_status_history 
+		 WHERE order_number = $1 
+		 ORDER BY id`,
+		number,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting order status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []domain.OrderStatusTransition
+	for rows.Next() {
+		var transition domain.OrderStatusTransition
+		err := rows.Scan(
+			&transition.From,
+			&transition.To,
+			scanMoney(&transition.Accrual),
+			&transition.Source,
+			&transition.Attempt,
+			&transition.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order status transition: %w", err)
+		}
+		history = append(history, transition)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// insertStatusTransition записывает переход заказа в историю в рамках транзакции tx
+func insertStatusTransition(ctx context.Context, tx pgx.Tx, number string, transition domain.OrderStatusTransition) error {
+	var from sql.NullString
+	if transition.From != "" {
+		from = sql.NullString{String: string(transition.From), Valid: true}
+	}
+	var attempt sql.NullInt32
+	if transition.Attempt > 0 {
+		attempt = sql.NullInt32{Int32: int32(transition.Attempt), Valid: true}
+	}
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO order_status_history (order_number, from_status, to_status, accrual, source, attempt) 
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		number, from, transition.To, moneyArg(transition.Accrual), transition.Source, attempt,
+	)
+	if err != nil {
+		return fmt.Errorf("error recording order status transition: %w", err)
+	}
+	return nil
+}
+
+// TryLockReconciliation берет сессионную advisory-блокировку сверки на отдельном соединении.
+// Блокировка снимается вызовом unlock или разрывом соединения при падении реплики.
+func (r *PostgresRepository) TryLockReconciliation(ctx context.Context) (func(), error) {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring connection: %w", err)
+	}
+
+	var locked bool
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, reconciliationLockID).Scan(&locked)
+	if err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("error locking reconciliation: %w", err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, domain.ErrReconciliationLocked
+	}
+
+	return func() {
+		_, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, reconciliationLockID)
+		if err != nil {
+			// Соединение с неснятой блокировкой нельзя возвращать в пул
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, nil
+}
+
+// GetProcessedOrdersSince возвращает до limit заказов, обработанных начиная с since, от новых к старым.
+// Если задан after, возвращаются заказы, следующие за ним.
+func (r *PostgresRepository) GetProcessedOrdersSince(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error) {
+	var afterProcessedAt sql.NullTime
+	var afterNumber string
+	if after != nil {
+		afterProcessedAt = nullTime(after.ProcessedAt)
+		afterNumber = after.Number
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT number, user_id, status, accrual, uploaded_at, processed_at 
+		 FROM orders 
+		 WHERE status = $1 AND processed_at >= $2 
+		   AND ($3::timestamptz IS NULL OR (processed_at, number) < ($3, $4::text)) 
+		 ORDER BY processed_at DESC, number DESC 
+		 LIMIT $5`,
+		domain.StatusProcessed, since, afterProcessedAt, afterNumber, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting processed orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []domain.Order
+	for rows.Next() {
+		var order domain.Order
+		var processedAt sql.NullTime
+
+		err := rows.Scan(
+			&order.Number,
+			&order.UserID,
+			&order.Status,
+			scanMoney(&order.Accrual),
+			&order.UploadedAt,
+			&processedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order: %w", err)
+		}
+
+		if processedAt.Valid {
+			order.ProcessedAt = &processedAt.Time
+		}
+
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// ApplyAccrualAdjustment заменяет начисление по обработанному заказу на newAccrual и проводит
+// разницу по балансу владельца. Если начисление уже отличается от previousAccrual,
+// возвращается domain.ErrAccrualChanged и ничего не меняется.
+func (r *PostgresRepository) ApplyAccrualAdjustment(ctx context.Context, orderNumber string, previousAccrual, newAccrual domain.Money) (*domain.AccrualAdjustment, error) {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	adjustment := domain.AccrualAdjustment{
+		OrderNumber: orderNumber,
+		NewAccrual:  newAccrual,
+	}
+
+	// Блокируем заказ, чтобы сверка не пересекалась с другими изменениями начисления
+	var status domain.OrderStatus
+	err = tx.QueryRow(ctx,
+		`SELECT user_id, status, accrual FROM orders WHERE number = $1 FOR UPDATE`,
+		orderNumber,
+	).Scan(&adjustment.UserID, &status, scanMoney(&adjustment.PreviousAccrual))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("error locking order: %w", err)
+	}
+
+	if status != domain.StatusProcessed || adjustment.PreviousAccrual != previousAccrual {
+		return nil, domain.ErrAccrualChanged
+	}
+
+	adjustment.Amount = newAccrual - adjustment.PreviousAccrual
+
+	_, err = tx.Exec(ctx,
+		`UPDATE orders SET accrual = $1 WHERE number = $2`,
+		moneyArg(newAccrual), orderNumber,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error updating order accrual: %w", err)
+	}
+
+	err = insertStatusTransition(ctx, tx, orderNumber, domain.OrderStatusTransition{
+		From:    domain.StatusProcessed,
+		To:      domain.StatusProcessed,
+		Accrual: newAccrual,
+		Source:  domain.StatusSourceReconciliation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Блокируем баланс, создавая его при необходимости
+	_, err = tx.Exec(ctx,
+		`INSERT INTO balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`,
+		adjustment.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating balance: %w", err)
+	}
+
+	var current, debt domain.Money
+	err = tx.QueryRow(ctx,
+		`SELECT current, debt FROM balances WHERE user_id = $1 FOR UPDATE`,
+		adjustment.UserID,
+	).Scan(scanMoney(&current), scanMoney(&debt))
+	if err != nil {
+		return nil, fmt.Errorf("error locking balance: %w", err)
+	}
+
+	newCurrent, newDebt, incurred := adjustBalance(current, debt, adjustment.Amount)
+	adjustment.Debt = incurred
+
+	_, err = tx.Exec(ctx,
+		`UPDATE balances SET current = $1, debt = $2 WHERE user_id = $3`,
+		moneyArg(newCurrent), moneyArg(newDebt), adjustment.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error updating balance: %w", err)
+	}
+
+	err = tx.QueryRow(ctx,
+		`INSERT INTO accrual_adjustments (order_number, user_id, previous_accrual, new_accrual, amount, debt) 
+		 VALUES ($1, $2, $3, $4, $5, $6) 
+		 RETURNING id, created_at`,
+		orderNumber, adjustment.UserID, moneyArg(adjustment.PreviousAccrual), moneyArg(newAccrual),
+		moneyArg(adjustment.Amount), moneyArg(adjustment.Debt),
+	).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating accrual adjustment: %w", err)
+	}
+
+	// Долг учитывается на своём счёте с отрицательным остатком
+	kind := domain.LedgerAdjustment
+	if adjustment.Amount < 0 {
+		kind = domain.LedgerReversal
+	}
+	err = insertLedgerTransaction(ctx, tx, adjustment.UserID, kind,
+		ledgerSource{orderNumber: orderNumber, adjustmentID: adjustment.ID},
+		ledgerLeg{account: domain.AccountAccrualSystem, amount: -adjustment.Amount},
+		ledgerLeg{account: domain.AccountCurrent, amount: newCurrent - current},
+		ledgerLeg{account: domain.AccountDebt, amount: debt - newDebt},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return &adjustment, nil
+}
+
+// adjustBalance проводит корректировку amount по балансу current с долгом debt.
+// Зачисление сначала гасит долг; списание не опускает баланс ниже нуля, а непокрытый
+// остаток добавляется к долгу. Возвращает новые баланс и долг, а также возникший долг.
+func adjustBalance(current, debt, amount domain.Money) (newCurrent, newDebt, incurred domain.Money) {
+	if amount >= 0 {
+		repaid := min(debt, amount)
+		return current + amount - repaid, debt - repaid, 0
+	}
+
+	charge := -amount
+	covered := min(current, charge)
+	incurred = charge - covered
+	return current - covered, debt + incurred, incurred
+}
+
+// SaveReconciliationReport сохраняет отчет завершенного прохода сверки
+func (r *PostgresRepository) SaveReconciliationReport(ctx context.Context, report *domain.ReconciliationReport) error {
+	discrepancies, err := json.Marshal(report.Discrepancies)
+	if err != nil {
+		return fmt.Errorf("error encoding reconciliation discrepancies: %w", err)
+	}
+
+	_, err = r.pool.Exec(ctx,
+		`INSERT INTO reconciliation_reports (started_at, finished_at, checked, failed, discrepancies) 
+		 VALUES ($1, $2, $3, $4, $5)`,
+		report.StartedAt, report.FinishedAt, report.Checked, report.Failed, discrepancies,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving reconciliation report: %w", err)
+	}
+
+	return nil
+}
+
+// GetLastReconciliationReport возвращает отчет последнего сохраненного прохода сверки
+func (r *PostgresRepository) GetLastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error) {
+	var report domain.ReconciliationReport
+	var discrepancies []byte
+
+	err := r.pool.QueryRow(ctx,
+		`SELECT started_at, finished_at, checked, failed, discrepancies 
+		 FROM reconciliation_reports 
+		 ORDER BY id DESC 
+		 LIMIT 1`,
+	).Scan(&report.StartedAt, &report.FinishedAt, &report.Checked, &report.Failed, &discrepancies)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrReconciliationReportNotFound
+		}
+		return nil, fmt.Errorf("error getting reconciliation report: %w", err)
+	}
+
+	if err := json.Unmarshal(discrepancies, &report.Discrepancies); err != nil {
+		return nil, fmt.Errorf("error decoding reconciliation discrepancies: %w", err)
+	}
+
+	return &report, nil
+}
+
+// GetBalance возвращает баланс пользователя, вычисленный по журналу операций.
+// Таблица balances хранит тот же остаток для блокировок и проверки при списании.
 func (r *PostgresRepository) GetBalance(ctx context.Context, userID int64) (*domain.Balance, error) {
 	var balance domain.Balance
 
-	// Получаем текущий баланс из таблицы balances
+	// Агрегат без GROUP BY возвращает строку и для пользователя без операций
 	err := r.pool.QueryRow(ctx,
-		`SELECT COALESCE(current, 0), COALESCE(withdrawn, 0)
-		 FROM balances 
+		`SELECT COALESCE(SUM(amount) FILTER (WHERE account = 'current'), 0), 
+		        COALESCE(SUM(amount) FILTER (WHERE account = 'withdrawn'), 0) 
+		 FROM ledger_entries 
 		 WHERE user_id = $1`,
 		userID,
-	).Scan(&balance.Current, &balance.Withdrawn)
-
+	).Scan(scanMoney(&balance.Current), scanMoney(&balance.Withdrawn))
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			// Если записи нет, возвращаем нулевой баланс
-			return &domain.Balance{Current: 0, Withdrawn: 0}, nil
-		}
 		return nil, fmt.Errorf("error getting balance: %w", err)
 	}
 
 	return &balance, nil
 }
 
-// CreateWithdrawal создает новое списание
-func (r *PostgresRepository) CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum float64) error {
+// withdrawalFailures - коды отказов в списании, которые запоминаются вместе с ключом идемпотентности
+var withdrawalFailures = map[string]error{
+	"insufficient_funds":  domain.ErrInsufficientFunds,
+	"withdrawal_conflict": domain.ErrWithdrawalConflict,
+}
+
+// withdrawalFailureCode возвращает код отказа err для withdrawal_idempotency_keys.failure;
+// false - err не является отказом в списании
+func withdrawalFailureCode(err error) (string, bool) {
+	for code, failure := range withdrawalFailures {
+		if err == failure {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// CreateWithdrawal проводит списание sum в счёт заказа orderNumber. По заказу допускается
+// одно списание: повтор с теми же параметрами возвращает проведенное списание с Replayed,
+// с другими - domain.ErrWithdrawalConflict. Непустой idempotencyKey запоминается на
+// domain.IdempotencyKeyTTL вместе с результатом запроса: повтор с этим ключом возвращает то же
+// списание или тот же отказ, а запрос с другими параметрами - domain.ErrIdempotencyKeyReused.
+func (r *PostgresRepository) CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum domain.Money, idempotencyKey string) (*domain.Withdrawal, error) {
+	now := time.Now()
+	keyExpiredAt := now.Add(-domain.IdempotencyKeyTTL)
+
+	if idempotencyKey != "" {
+		replayed, err := r.replayWithdrawal(ctx, userID, idempotencyKey, orderNumber, sum, keyExpiredAt)
+		if replayed != nil || err != nil {
+			return replayed, err
+		}
+	}
+
+	withdrawal, err := r.createWithdrawal(ctx, userID, orderNumber, sum, idempotencyKey, now)
+	if failure, ok := withdrawalFailureCode(err); ok && idempotencyKey != "" {
+		// Отказ запоминается с ключом, иначе повтор после пополнения баланса провел бы
+		// списание, на которое клиент уже получил отказ. Живой ключ не перезаписывается.
+		_, saveErr := r.pool.Exec(ctx,
+			`INSERT INTO withdrawal_idempotency_keys (user_id, key, order_number, sum, failure, created_at) 
+			 VALUES ($1, $2, $3, $4, $5, $6) 
+			 ON CONFLICT (user_id, key) DO UPDATE 
+			 SET withdrawal_id = NULL, 
+			     order_number = EXCLUDED.order_number, 
+			     sum = EXCLUDED.sum, 
+			     failure = EXCLUDED.failure, 
+			     created_at = EXCLUDED.created_at 
+			 WHERE withdrawal_idempotency_keys.created_at <= $7`,
+			userID, idempotencyKey, orderNumber, moneyArg(sum), failure, now, keyExpiredAt,
+		)
+		if saveErr != nil {
+			return nil, fmt.Errorf("error saving idempotency key: %w", saveErr)
+		}
+	}
+	return withdrawal, err
+}
+
+// replayWithdrawal возвращает результат запроса, ранее выполненного с ключом идемпотентности:
+// проведенное списание с Replayed или запомненный отказ. Для ключа, который не использовался
+// или просрочен, возвращается nil без ошибки.
+func (r *PostgresRepository) replayWithdrawal(ctx context.Context, userID int64, idempotencyKey, orderNumber string, sum domain.Money, keyExpiredAt time.Time) (*domain.Withdrawal, error) {
+	var w domain.Withdrawal
+	var withdrawalID sql.NullInt64
+	var processedAt sql.NullTime
+	var failure sql.NullString
+	err := r.pool.QueryRow(ctx,
+		`SELECT k.withdrawal_id, COALESCE(w.order_number, k.order_number), COALESCE(w.sum, k.sum), 
+		        w.processed_at, k.failure 
+		 FROM withdrawal_idempotency_keys k 
+		 LEFT JOIN withdrawals w ON w.id = k.withdrawal_id 
+		 WHERE k.user_id = $1 AND k.key = $2 AND k.created_at > $3`,
+		userID, idempotencyKey, keyExpiredAt,
+	).Scan(&withdrawalID, &w.OrderNumber, scanMoney(&w.Sum), &processedAt, &failure)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting idempotency key: %w", err)
+	}
+
+	if w.OrderNumber != orderNumber || w.Sum != sum {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+	if failure.Valid {
+		if failureErr, ok := withdrawalFailures[failure.String]; ok {
+			return nil, failureErr
+		}
+		return nil, fmt.Errorf("error getting idempotency key: unknown failure %q", failure.String)
+	}
+	w.ID, w.ProcessedAt, w.Replayed = withdrawalID.Int64, processedAt.Time, true
+	return &w, nil
+}
+
+// createWithdrawal проводит списание в одной транзакции с сохранением ключа идемпотентности
+func (r *PostgresRepository) createWithdrawal(ctx context.Context, userID int64, orderNumber string, sum domain.Money, idempotencyKey string, now time.Time) (*domain.Withdrawal, error) {
 	// Получаем соединение из пула
 	conn, err := r.pool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("error acquiring connection: %w", err)
+		return nil, fmt.Errorf("error acquiring connection: %w", err)
 	}
 	defer conn.Release()
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %w", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Создаем запись о списании
-	_, err = tx.Exec(ctx,
+	// Создаем запись о списании. Параллельный запрос по тому же заказу ждет
+	// завершения этой транзакции на уникальном индексе.
+	withdrawal := domain.Withdrawal{OrderNumber: orderNumber, Sum: sum}
+	err = tx.QueryRow(ctx,
 		`INSERT INTO withdrawals (user_id, order_number, sum) 
-		 VALUES ($1, $2, $3)`,
-		userID, orderNumber, sum,
-	)
-	if err != nil {
-		return fmt.Errorf("error creating withdrawal: %w", err)
-	}
+		 VALUES ($1, $2, $3) 
+		 ON CONFLICT (order_number) WHERE duplicate_of IS NULL DO NOTHING 
+		 RETURNING id, processed_at`,
+		userID, orderNumber, moneyArg(sum),
+	).Scan(&withdrawal.ID, &withdrawal.ProcessedAt)
+	switch {
+	case err == pgx.ErrNoRows:
+		// Списание по заказу уже проведено
+		var ownerID int64
+		err = tx.QueryRow(ctx,
+			`SELECT id, user_id, sum, processed_at 
+			 FROM withdrawals 
+			 WHERE order_number = $1 AND duplicate_of IS NULL`,
+			orderNumber,
+		).Scan(&withdrawal.ID, &ownerID, scanMoney(&withdrawal.Sum), &withdrawal.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error getting withdrawal: %w", err)
+		}
+		if ownerID != userID || withdrawal.Sum != sum {
+			return nil, domain.ErrWithdrawalConflict
+		}
+		withdrawal.Replayed = true
 
-	// Обновляем баланс пользователя
-	result, err := tx.Exec(ctx,
-		`UPDATE balances 
-		 SET current = current - $1,
-		     withdrawn = withdrawn + $1
-		 WHERE user_id = $2 AND current >= $1`,
-		sum, userID,
-	)
-	if err != nil {
-		return fmt.Errorf("error updating balance: %w", err)
+	case err != nil:
+		return nil, fmt.Errorf("error creating withdrawal: %w", err)
+
+	default:
+		// Обновляем баланс пользователя
+		result, err := tx.Exec(ctx,
+			`UPDATE balances
+			 SET current = current - $1,
+			     withdrawn = withdrawn + $1
+			 WHERE user_id = $2 AND current >= $1`,
+			moneyArg(sum), userID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error updating balance: %w", err)
+		}
+
+		// Проверяем, что обновление баланса произошло
+		rowsAffected := result.RowsAffected()
+		if rowsAffected == 0 {
+			return nil, domain.ErrInsufficientFunds
+		}
+
+		err = insertLedgerTransaction(ctx, tx, userID, domain.LedgerWithdrawal,
+			ledgerSource{orderNumber: orderNumber},
+			ledgerLeg{account: domain.AccountCurrent, amount: -sum},
+			ledgerLeg{account: domain.AccountWithdrawn, amount: sum},
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Проверяем, что обновление баланса произошло
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return domain.ErrInsufficientFunds
+	if idempotencyKey != "" {
+		// Просроченный ключ занимается заново. Ключ, который параллельный запрос связал
+		// с другим списанием или с отказом, не перезаписывается.
+		result, err := tx.Exec(ctx,
+			`INSERT INTO withdrawal_idempotency_keys (user_id, key, withdrawal_id, created_at) 
+			 VALUES ($1, $2, $3, $4) 
+			 ON CONFLICT (user_id, key) DO UPDATE 
+			 SET created_at = CASE WHEN withdrawal_idempotency_keys.withdrawal_id = EXCLUDED.withdrawal_id 
+			                       THEN withdrawal_idempotency_keys.created_at ELSE EXCLUDED.created_at END, 
+			     withdrawal_id = EXCLUDED.withdrawal_id, 
+			     order_number = NULL, 
+			     sum = NULL, 
+			     failure = NULL 
+			 WHERE withdrawal_idempotency_keys.created_at <= $5 
+			    OR withdrawal_idempotency_keys.withdrawal_id = EXCLUDED.withdrawal_id`,
+			userID, idempotencyKey, withdrawal.ID, now, now.Add(-domain.IdempotencyKeyTTL),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error saving idempotency key: %w", err)
+		}
+		if result.RowsAffected() == 0 {
+			return nil, domain.ErrIdempotencyKeyReused
+		}
 	}
 
 	// Фиксируем транзакцию
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
 	}
 
-	return nil
+	return &withdrawal, nil
 }
 
-// GetUserWithdrawals возвращает все списания пользователя
-func (r *PostgresRepository) GetUserWithdrawals(ctx context.Context, userID int64) ([]domain.Withdrawal, error) {
+// GetUserWithdrawals возвращает до limit списаний пользователя, отобранных filter, от новых
+// к старым, начиная со списания, следующего за after; after = nil - с самого нового, limit <= 0 - все
+func (r *PostgresRepository) GetUserWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, after *domain.WithdrawalCursor, limit int) ([]domain.Withdrawal, error) {
+	var afterProcessedAt time.Time
+	var afterID int64
+	if after != nil {
+		afterProcessedAt, afterID = after.ProcessedAt, after.ID
+	}
+
 	rows, err := r.pool.Query(ctx,
-		`SELECT order_number, sum, processed_at 
+		`SELECT id, order_number, sum, processed_at 
 		 FROM withdrawals 
 		 WHERE user_id = $1 
-		 ORDER BY processed_at DESC`,
-		userID,
+		   AND ($2::timestamptz IS NULL OR processed_at >= $2) 
+		   AND ($3::timestamptz IS NULL OR processed_at < $3) 
+		   AND ($4::timestamptz IS NULL OR (processed_at, id) < ($4, $5::bigint)) 
+		 ORDER BY processed_at DESC, id DESC 
+		 LIMIT $6`,
+		userID, nullTime(filter.ProcessedFrom), nullTime(filter.ProcessedTo),
+		nullTime(afterProcessedAt), afterID, nullLimit(limit),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user withdrawals: %w", err)
@@ -327,11 +1138,26 @@ func (r *PostgresRepository) GetUserWithdrawals(ctx context.Context, userID int6
 	var withdrawals []domain.Withdrawal
 	for rows.Next() {
 		var w domain.Withdrawal
-		err := rows.Scan(&w.OrderNumber, &w.Sum, &w.ProcessedAt)
+		err := rows.Scan(&w.ID, &w.OrderNumber, scanMoney(&w.Sum), &w.ProcessedAt)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning withdrawal: %w", err)
 		}
 		withdrawals = append(withdrawals, w)
 	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating withdrawals: %w", err)
+	}
+
 	return withdrawals, nil
 }
+
+// nullTime передает нулевое время в запрос как NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// nullLimit передает неположительный размер выборки в LIMIT как NULL, то есть без ограничения
+func nullLimit(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit), Valid: limit > 0}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"gophermart/internal/storage/storagetest"
+	"gophermart/internal/usecase"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// TestPostgresRepository_Contract запускается только при заданной TEST_DATABASE_URI.
+// Перед каждой проверкой все таблицы базы очищаются, поэтому тест отказывается работать
+// с базой, в имени которой нет "test", и не читает DATABASE_URI сервиса, чтобы не задеть
+// рабочую базу из окружения. В CI его запускает workflow storage (см. README).
+func TestPostgresRepository_Contract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URI: %v", err)
+	}
+	if !strings.Contains(strings.ToLower(config.Database), "test") {
+		t.Fatalf("refusing to truncate database %q: its name must contain \"test\"", config.Database)
+	}
+
+	if err := RunMigrations(dsn); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) usecase.Storage {
+		ctx := context.Background()
+
+		repo, err := NewPostgresRepository(ctx, dsn)
+		if err != nil {
+			t.Fatalf("NewPostgresRepository() error = %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+
+		_, err = repo.pool.Exec(ctx,
+			`TRUNCATE users, orders, balances, withdrawals, withdrawal_idempotency_keys, 
+			          accrual_jobs, accrual_adjustments, order_status_history, admin_audit_log, 
+			          ledger_entries, reconciliation_reports 
+			 RESTART IDENTITY CASCADE`,
+		)
+		if err != nil {
+			t.Fatalf("error truncating tables: %v", err)
+		}
+		return repo
+	})
+}
@@ -0,0 +1,1248 @@
+// Package storagetest содержит общий набор проверок контракта usecase.Storage.
+// Каждая реализация хранилища запускает его из своих тестов через Run.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/usecase"
+)
+
+// Factory возвращает пустое хранилище для одной проверки. Освобождение ресурсов
+// хранилища фабрика регистрирует через t.Cleanup.
+type Factory func(t *testing.T) usecase.Storage
+
+// Run проверяет, что хранилище ведет себя так, как ожидают сценарии usecase.
+// Проверки выполняются последовательно, каждая на новом хранилище из newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s usecase.Storage)
+	}{
+		{name: "пользователи", run: testUsers},
+		{name: "заказы", run: testOrders},
+		{name: "страницы и фильтры заказов", run: testOrderPages},
+		{name: "начисление по заказу", run: testAccrual},
+		{name: "погашение долга начислением", run: testAccrualRepaysDebt},
+		{name: "история статусов заказа", run: testOrderStatusHistory},
+		{name: "корректировка начисления", run: testAccrualAdjustment},
+		{name: "нулевой баланс без операций", run: testEmptyBalance},
+		{name: "списания", run: testWithdrawals},
+		{name: "отказ по ключу идемпотентности", run: testWithdrawalFailureReplay},
+		{name: "страницы и фильтры списаний", run: testWithdrawalPages},
+		{name: "выписка операций", run: testTransactions},
+		{name: "аренда заданий опроса", run: testClaimAccrualJobs},
+		{name: "продление аренды на время паузы", run: testExtendAccrualLeases},
+		{name: "возврат заказа в очередь", run: testRequeueOrder},
+		{name: "массовый возврат в очередь", run: testRequeueOrders},
+		{name: "ручная установка статуса", run: testForceOrderStatus},
+		{name: "пачки заказов для сверки", run: testProcessedOrderPages},
+		{name: "блокировка сверки", run: testReconciliationLock},
+		{name: "отчет сверки", run: testReconciliationReport},
+		{name: "параллельная регистрация", run: testConcurrentUsers},
+		{name: "параллельная загрузка заказа", run: testConcurrentOrders},
+		{name: "параллельные списания", run: testConcurrentWithdrawals},
+		{name: "параллельные повторы списания", run: testConcurrentWithdrawalReplays},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStorage(t))
+		})
+	}
+}
+
+// createUser регистрирует пользователя login и возвращает его идентификатор
+func createUser(t *testing.T, s usecase.Storage, login string) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := s.CreateUser(ctx, login, "hash-"+login); err != nil {
+		t.Fatalf("CreateUser(%q) error = %v", login, err)
+	}
+	user, err := s.GetUserByLogin(ctx, login)
+	if err != nil {
+		t.Fatalf("GetUserByLogin(%q) error = %v", login, err)
+	}
+	return user.ID
+}
+
+// credit загружает от имени пользователя заказ number и начисляет по нему amount
+func credit(t *testing.T, s usecase.Storage, userID int64, number string, amount domain.Money) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := s.CreateOrder(ctx, userID, number, time.Now()); err != nil {
+		t.Fatalf("CreateOrder(%q) error = %v", number, err)
+	}
+	err := s.UpdateOrderStatusAndBalance(ctx, domain.OrderStatusChange{
+		OrderNumber: number,
+		Status:      domain.StatusProcessed,
+		Accrual:     amount,
+		Source:      domain.StatusSourcePoll,
+	}, userID)
+	if err != nil {
+		t.Fatalf("UpdateOrderStatusAndBalance(%q) error = %v", number, err)
+	}
+}
+
+// expectBalance проверяет доступные и списанные баллы пользователя
+func expectBalance(t *testing.T, s usecase.Storage, userID int64, current, withdrawn domain.Money) {
+	t.Helper()
+
+	balance, err := s.GetBalance(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetBalance() error = %v", err)
+	}
+	if balance.Current != current || balance.Withdrawn != withdrawn {
+		t.Errorf("expected balance %s/%s, got %s/%s", current, withdrawn, balance.Current, balance.Withdrawn)
+	}
+}
+
+func testUsers(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+
+	userID := createUser(t, s, "alice")
+	user, err := s.GetUserByLogin(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserByLogin() error = %v", err)
+	}
+	if user.ID != userID || user.Login != "alice" || user.PasswordHash != "hash-alice" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if err := s.CreateUser(ctx, "alice", "other"); !errors.Is(err, domain.ErrUserExists) {
+		t.Errorf("expected ErrUserExists for duplicate login, got %v", err)
+	}
+
+	if _, err := s.GetUserByLogin(ctx, "bob"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound for unknown login, got %v", err)
+	}
+}
+
+func testOrders(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	for _, number := range []string{"12345678903", "2377225624"} {
+		if err := s.CreateOrder(ctx, alice, number, time.Now()); err != nil {
+			t.Fatalf("CreateOrder(%q) error = %v", number, err)
+		}
+		// Разводим время загрузки, чтобы порядок заказов был однозначным
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	order, err := s.GetOrderByNumber(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.UserID != alice || order.Status != domain.StatusNew || order.Accrual != 0 || order.ProcessedAt != nil {
+		t.Errorf("unexpected new order: %+v", order)
+	}
+
+	// Номер заказа уникален для всех пользователей
+	if err := s.CreateOrder(ctx, bob, "12345678903", time.Now()); !errors.Is(err, domain.ErrOrderExists) {
+		t.Errorf("expected ErrOrderExists for duplicate number, got %v", err)
+	}
+
+	if _, err := s.GetOrderByNumber(ctx, "79927398713"); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("expected ErrOrderNotFound for unknown number, got %v", err)
+	}
+
+	orders, err := s.GetUserOrders(ctx, alice, domain.OrderFilter{}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetUserOrders() error = %v", err)
+	}
+	if len(orders) != 2 || orders[0].Number != "2377225624" || orders[1].Number != "12345678903" {
+		t.Errorf("expected orders of alice from newest to oldest, got %+v", orders)
+	}
+	for _, o := range orders {
+		if o.NextCheckAt == nil {
+			t.Errorf("expected next check time for pending order %s", o.Number)
+		}
+	}
+
+	orders, err = s.GetUserOrders(ctx, bob, domain.OrderFilter{}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetUserOrders() error = %v", err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("expected no orders of bob, got %+v", orders)
+	}
+}
+
+func testOrderPages(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	// Заказы загружаются от старых к новым, список возвращает их в обратном порядке
+	numbers := []string{"12345678903", "2377225624", "79927398713", "4561261212345467", "5062821234567892"}
+	for _, number := range numbers {
+		if err := s.CreateOrder(ctx, alice, number, time.Now()); err != nil {
+			t.Fatalf("CreateOrder(%q) error = %v", number, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.CreateOrder(ctx, bob, "371449635398431", time.Now()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	// Заказ в PROCESSING еще не обработан и в фильтр по времени обработки не попадает
+	processedFrom := time.Now()
+	for number, status := range map[string]domain.OrderStatus{
+		numbers[0]: domain.StatusProcessed,
+		numbers[1]: domain.StatusProcessing,
+		numbers[2]: domain.StatusProcessed,
+		numbers[3]: domain.StatusInvalid,
+	} {
+		err := s.UpdateOrderStatusAndBalance(ctx, domain.OrderStatusChange{
+			OrderNumber: number,
+			Status:      status,
+			Accrual:     domain.Rubles(10),
+			Source:      domain.StatusSourcePoll,
+		}, alice)
+		if err != nil {
+			t.Fatalf("UpdateOrderStatusAndBalance(%q) error = %v", number, err)
+		}
+	}
+
+	// Неположительный limit возвращает список целиком
+	all, err := s.GetUserOrders(ctx, alice, domain.OrderFilter{}, nil, 0)
+	if err != nil {
+		t.Fatalf("GetUserOrders() error = %v", err)
+	}
+	if len(all) != len(numbers) {
+		t.Fatalf("expected %d orders, got %+v", len(numbers), all)
+	}
+
+	// Обход страницами возвращает каждый заказ ровно один раз в том же порядке
+	var walked []string
+	var after *domain.OrderCursor
+	for page := 0; page < len(numbers); page++ {
+		orders, err := s.GetUserOrders(ctx, alice, domain.OrderFilter{}, after, 2)
+		if err != nil {
+			t.Fatalf("GetUserOrders() error = %v", err)
+		}
+		if len(orders) == 0 {
+			break
+		}
+		for _, o := range orders {
+			walked = append(walked, o.Number)
+		}
+		last := orders[len(orders)-1]
+		after = &domain.OrderCursor{UploadedAt: last.UploadedAt, Number: last.Number}
+	}
+	if fmt.Sprint(walked) != fmt.Sprint(orderNumbers(all)) {
+		t.Errorf("expected pages to walk %v, got %v", orderNumbers(all), walked)
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.OrderFilter
+		want   []string
+	}{
+		{
+			name:   "по статусу",
+			filter: domain.OrderFilter{Statuses: []domain.OrderStatus{domain.StatusProcessed}},
+			want:   []string{numbers[2], numbers[0]},
+		},
+		{
+			name:   "по нескольким статусам",
+			filter: domain.OrderFilter{Statuses: []domain.OrderStatus{domain.StatusNew, domain.StatusProcessing, domain.StatusInvalid}},
+			want:   []string{numbers[4], numbers[3], numbers[1]},
+		},
+		{
+			name:   "по времени загрузки",
+			filter: domain.OrderFilter{UploadedFrom: all[3].UploadedAt, UploadedTo: all[1].UploadedAt},
+			want:   []string{numbers[2], numbers[1]},
+		},
+		{
+			name:   "по времени обработки",
+			filter: domain.OrderFilter{ProcessedFrom: processedFrom},
+			want:   []string{numbers[3], numbers[2], numbers[0]},
+		},
+		{
+			name:   "обработанные до начала обработки",
+			filter: domain.OrderFilter{ProcessedTo: processedFrom},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := s.GetUserOrders(ctx, alice, tt.filter, nil, 10)
+			if err != nil {
+				t.Fatalf("GetUserOrders() error = %v", err)
+			}
+			if fmt.Sprint(orderNumbers(orders)) != fmt.Sprint(tt.want) {
+				t.Errorf("expected orders %v, got %v", tt.want, orderNumbers(orders))
+			}
+		})
+	}
+}
+
+// orderNumbers возвращает номера заказов в порядке списка
+func orderNumbers(orders []domain.Order) []string {
+	numbers := make([]string, 0, len(orders))
+	for _, o := range orders {
+		numbers = append(numbers, o.Number)
+	}
+	return numbers
+}
+
+func testAccrual(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	// Повторное применение окончательного статуса не начисляет баллы второй раз
+	err := s.UpdateOrderStatusAndBalance(ctx, domain.OrderStatusChange{
+		OrderNumber: "12345678903",
+		Status:      domain.StatusProcessed,
+		Accrual:     domain.Rubles(100),
+		Source:      domain.StatusSourceCallback,
+	}, alice)
+	if err != nil {
+		t.Fatalf("UpdateOrderStatusAndBalance() error = %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(100), 0)
+
+	order, err := s.GetOrderByNumber(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.Status != domain.StatusProcessed || order.Accrual != domain.Rubles(100) || order.ProcessedAt == nil {
+		t.Errorf("unexpected processed order: %+v", order)
+	}
+
+	history, err := s.GetOrderStatusHistory(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderStatusHistory() error = %v", err)
+	}
+	if len(history) != 2 || history[0].To != domain.StatusNew ||
+		history[1].From != domain.StatusNew || history[1].To != domain.StatusProcessed {
+		t.Errorf("expected NEW and NEW -> PROCESSED transitions, got %+v", history)
+	}
+
+	err = s.UpdateOrderStatusAndBalance(ctx, domain.OrderStatusChange{
+		OrderNumber: "79927398713",
+		Status:      domain.StatusProcessed,
+	}, alice)
+	if !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("expected ErrOrderNotFound for unknown order, got %v", err)
+	}
+}
+
+func testAccrualRepaysDebt(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(80), ""); err != nil {
+		t.Fatalf("CreateWithdrawal() error = %v", err)
+	}
+
+	// Сверка отменяет начисление: 20 баллов списываются с баланса, 80 становятся долгом
+	adjustment, err := s.ApplyAccrualAdjustment(ctx, "12345678903", domain.Rubles(100), 0)
+	if err != nil {
+		t.Fatalf("ApplyAccrualAdjustment() error = %v", err)
+	}
+	if adjustment.Debt != domain.Rubles(80) {
+		t.Fatalf("expected debt 80, got %s", adjustment.Debt)
+	}
+	expectBalance(t, s, alice, 0, domain.Rubles(80))
+
+	// Новые начисления сначала гасят долг и только затем пополняют баланс
+	credit(t, s, alice, "79927398713", domain.Rubles(50))
+	expectBalance(t, s, alice, 0, domain.Rubles(80))
+	credit(t, s, alice, "4561261212345467", domain.Rubles(50))
+	expectBalance(t, s, alice, domain.Rubles(20), domain.Rubles(80))
+
+	transactions, err := s.GetUserTransactions(ctx, alice, 0, 2)
+	if err != nil {
+		t.Fatalf("GetUserTransactions() error = %v", err)
+	}
+	if len(transactions) != 2 ||
+		transactions[0].Amount != domain.Rubles(20) || transactions[0].Debt != domain.Rubles(-30) ||
+		transactions[1].Amount != 0 || transactions[1].Debt != domain.Rubles(-50) {
+		t.Errorf("expected repayments of 30 and 50 in the statement, got %+v", transactions)
+	}
+}
+
+func testOrderStatusHistory(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	if err := s.CreateOrder(ctx, alice, "12345678903", time.Now()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+	// Повторный опрос с тем же статусом переходом не считается
+	for _, change := range []domain.OrderStatusChange{
+		{OrderNumber: "12345678903", Status: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 2},
+		{OrderNumber: "12345678903", Status: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 3},
+		{OrderNumber: "12345678903", Status: domain.StatusInvalid, Source: domain.StatusSourceCallback},
+	} {
+		if err := s.UpdateOrderStatusAndBalance(ctx, change, alice); err != nil {
+			t.Fatalf("UpdateOrderStatusAndBalance(%s) error = %v", change.Status, err)
+		}
+	}
+
+	history, err := s.GetOrderStatusHistory(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderStatusHistory() error = %v", err)
+	}
+	want := []domain.OrderStatusTransition{
+		{To: domain.StatusNew, Source: domain.StatusSourceUpload},
+		{From: domain.StatusNew, To: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 2},
+		{From: domain.StatusProcessing, To: domain.StatusInvalid, Source: domain.StatusSourceCallback},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("expected %d transitions, got %+v", len(want), history)
+	}
+	for i, transition := range history {
+		if transition.From != want[i].From || transition.To != want[i].To ||
+			transition.Source != want[i].Source || transition.Attempt != want[i].Attempt {
+			t.Errorf("transition %d: expected %+v, got %+v", i, want[i], transition)
+		}
+		if transition.ChangedAt.IsZero() || i > 0 && transition.ChangedAt.Before(history[i-1].ChangedAt) {
+			t.Errorf("transition %d: expected ordered change time, got %v", i, transition.ChangedAt)
+		}
+	}
+
+	history, err = s.GetOrderStatusHistory(ctx, "79927398713")
+	if err != nil || len(history) != 0 {
+		t.Errorf("expected empty history for unknown order, got %+v, %v", history, err)
+	}
+}
+
+func testAccrualAdjustment(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	adjustment, err := s.ApplyAccrualAdjustment(ctx, "12345678903", domain.Rubles(100), domain.Rubles(150))
+	if err != nil {
+		t.Fatalf("ApplyAccrualAdjustment() error = %v", err)
+	}
+	if adjustment.UserID != alice || adjustment.PreviousAccrual != domain.Rubles(100) ||
+		adjustment.NewAccrual != domain.Rubles(150) || adjustment.Amount != domain.Rubles(50) || adjustment.Debt != 0 {
+		t.Errorf("unexpected adjustment: %+v", adjustment)
+	}
+	expectBalance(t, s, alice, domain.Rubles(150), 0)
+
+	order, err := s.GetOrderByNumber(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.Accrual != domain.Rubles(150) {
+		t.Errorf("expected order accrual 150, got %s", order.Accrual)
+	}
+	history, err := s.GetOrderStatusHistory(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderStatusHistory() error = %v", err)
+	}
+	if last := history[len(history)-1]; last.Source != domain.StatusSourceReconciliation || last.Accrual != domain.Rubles(150) {
+		t.Errorf("expected reconciliation transition, got %+v", last)
+	}
+
+	// Корректировка по устаревшему начислению или незавершенному заказу не проводится
+	if _, err := s.ApplyAccrualAdjustment(ctx, "12345678903", domain.Rubles(100), domain.Rubles(200)); !errors.Is(err, domain.ErrAccrualChanged) {
+		t.Errorf("expected ErrAccrualChanged for stale accrual, got %v", err)
+	}
+	if err := s.CreateOrder(ctx, alice, "2377225624", time.Now()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+	if _, err := s.ApplyAccrualAdjustment(ctx, "2377225624", 0, domain.Rubles(10)); !errors.Is(err, domain.ErrAccrualChanged) {
+		t.Errorf("expected ErrAccrualChanged for unprocessed order, got %v", err)
+	}
+	if _, err := s.ApplyAccrualAdjustment(ctx, "79927398713", 0, domain.Rubles(10)); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("expected ErrOrderNotFound for unknown order, got %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(150), 0)
+}
+
+func testEmptyBalance(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	expectBalance(t, s, alice, 0, 0)
+
+	withdrawals, err := s.GetUserWithdrawals(ctx, alice, domain.WithdrawalFilter{}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals() error = %v", err)
+	}
+	if len(withdrawals) != 0 {
+		t.Errorf("expected no withdrawals, got %+v", withdrawals)
+	}
+
+	transactions, err := s.GetUserTransactions(ctx, alice, 0, 10)
+	if err != nil {
+		t.Fatalf("GetUserTransactions() error = %v", err)
+	}
+	if len(transactions) != 0 {
+		t.Errorf("expected no transactions, got %+v", transactions)
+	}
+}
+
+func testWithdrawals(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(150), ""); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(100), 0)
+
+	first, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(40), "key-1")
+	if err != nil {
+		t.Fatalf("CreateWithdrawal() error = %v", err)
+	}
+	if first.ID == 0 || first.Replayed || first.OrderNumber != "2377225624" || first.Sum != domain.Rubles(40) {
+		t.Errorf("unexpected withdrawal: %+v", first)
+	}
+	expectBalance(t, s, alice, domain.Rubles(60), domain.Rubles(40))
+
+	tests := []struct {
+		name    string
+		userID  int64
+		order   string
+		sum     domain.Money
+		key     string
+		wantErr error
+	}{
+		{name: "повтор по ключу", userID: alice, order: "2377225624", sum: domain.Rubles(40), key: "key-1"},
+		{name: "повтор по заказу", userID: alice, order: "2377225624", sum: domain.Rubles(40)},
+		{name: "ключ для другого заказа", userID: alice, order: "79927398713", sum: domain.Rubles(40), key: "key-1", wantErr: domain.ErrIdempotencyKeyReused},
+		{name: "заказ с другой суммой", userID: alice, order: "2377225624", sum: domain.Rubles(50), wantErr: domain.ErrWithdrawalConflict},
+		{name: "заказ другого пользователя", userID: bob, order: "2377225624", sum: domain.Rubles(40), wantErr: domain.ErrWithdrawalConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CreateWithdrawal(ctx, tt.userID, tt.order, tt.sum, tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (got.ID != first.ID || !got.Replayed) {
+				t.Errorf("expected replay of withdrawal %d, got %+v", first.ID, got)
+			}
+			expectBalance(t, s, alice, domain.Rubles(60), domain.Rubles(40))
+		})
+	}
+
+	withdrawals, err := s.GetUserWithdrawals(ctx, alice, domain.WithdrawalFilter{}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals() error = %v", err)
+	}
+	if len(withdrawals) != 1 || withdrawals[0].ID != first.ID || withdrawals[0].Sum != domain.Rubles(40) {
+		t.Errorf("expected single withdrawal %d, got %+v", first.ID, withdrawals)
+	}
+}
+
+func testWithdrawalFailureReplay(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(150), "key-1"); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Fatalf("expected ErrInsufficientFunds, got %v", err)
+	}
+	// После пополнения повтор с тем же ключом получает прежний отказ, а не проводит списание
+	credit(t, s, alice, "79927398713", domain.Rubles(100))
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(150), "key-1"); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Errorf("expected replayed ErrInsufficientFunds, got %v", err)
+	}
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(50), "key-1"); !errors.Is(err, domain.ErrIdempotencyKeyReused) {
+		t.Errorf("expected ErrIdempotencyKeyReused, got %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(200), 0)
+
+	// Новая попытка проводится с новым ключом
+	created, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(150), "key-2")
+	if err != nil {
+		t.Fatalf("CreateWithdrawal() error = %v", err)
+	}
+	if created.Replayed {
+		t.Errorf("expected new withdrawal, got %+v", created)
+	}
+	expectBalance(t, s, alice, domain.Rubles(50), domain.Rubles(150))
+
+	// Отказ из-за чужого списания по заказу тоже запоминается, ключи у пользователей свои
+	for i := 0; i < 2; i++ {
+		if _, err := s.CreateWithdrawal(ctx, bob, "2377225624", domain.Rubles(150), "key-1"); !errors.Is(err, domain.ErrWithdrawalConflict) {
+			t.Errorf("attempt %d: expected ErrWithdrawalConflict, got %v", i+1, err)
+		}
+	}
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(150), "key-1"); !errors.Is(err, domain.ErrInsufficientFunds) {
+		t.Errorf("expected alice's key to keep its failure, got %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(50), domain.Rubles(150))
+}
+
+func testWithdrawalPages(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+	credit(t, s, bob, "2377225624", domain.Rubles(100))
+
+	var created []int64
+	for _, number := range []string{"79927398713", "4561261212345467", "5062821234567892", "371449635398431"} {
+		w, err := s.CreateWithdrawal(ctx, alice, number, domain.Rubles(10), "")
+		if err != nil {
+			t.Fatalf("CreateWithdrawal(%q) error = %v", number, err)
+		}
+		created = append(created, w.ID)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := s.CreateWithdrawal(ctx, bob, "6011000990139424", domain.Rubles(10), ""); err != nil {
+		t.Fatalf("CreateWithdrawal() error = %v", err)
+	}
+
+	first, err := s.GetUserWithdrawals(ctx, alice, domain.WithdrawalFilter{}, nil, 3)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals() error = %v", err)
+	}
+	if len(first) != 3 || first[0].ID != created[3] || first[2].ID != created[1] {
+		t.Fatalf("expected first page of 3 newest withdrawals, got %+v", first)
+	}
+
+	last := first[len(first)-1]
+	second, err := s.GetUserWithdrawals(ctx, alice, domain.WithdrawalFilter{},
+		&domain.WithdrawalCursor{ProcessedAt: last.ProcessedAt, ID: last.ID}, 3)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals() error = %v", err)
+	}
+	if len(second) != 1 || second[0].ID != created[0] {
+		t.Errorf("expected second page with the oldest withdrawal, got %+v", second)
+	}
+
+	all, err := s.GetUserWithdrawals(ctx, alice, domain.WithdrawalFilter{}, nil, 0)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals() error = %v", err)
+	}
+	if len(all) != len(created) {
+		t.Errorf("expected all %d withdrawals without limit, got %+v", len(created), all)
+	}
+
+	// Интервал включает нижнюю границу и не включает верхнюю
+	filtered, err := s.GetUserWithdrawals(ctx, alice, domain.WithdrawalFilter{
+		ProcessedFrom: second[0].ProcessedAt,
+		ProcessedTo:   first[1].ProcessedAt,
+	}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetUserWithdrawals() error = %v", err)
+	}
+	if len(filtered) != 2 || filtered[0].ID != created[1] || filtered[1].ID != created[0] {
+		t.Errorf("expected withdrawals %d and %d, got %+v", created[1], created[0], filtered)
+	}
+}
+
+func testTransactions(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+	credit(t, s, bob, "4561261212345467", domain.Rubles(500))
+	if _, err := s.CreateWithdrawal(ctx, alice, "2377225624", domain.Rubles(30), ""); err != nil {
+		t.Fatalf("CreateWithdrawal() error = %v", err)
+	}
+
+	transactions, err := s.GetUserTransactions(ctx, alice, 0, 10)
+	if err != nil {
+		t.Fatalf("GetUserTransactions() error = %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("expected 2 transactions of alice, got %+v", transactions)
+	}
+	withdrawal, accrual := transactions[0], transactions[1]
+	if withdrawal.Kind != domain.LedgerWithdrawal || withdrawal.OrderNumber != "2377225624" || withdrawal.Amount != -domain.Rubles(30) {
+		t.Errorf("unexpected withdrawal transaction: %+v", withdrawal)
+	}
+	if accrual.Kind != domain.LedgerAccrual || accrual.OrderNumber != "12345678903" || accrual.Amount != domain.Rubles(100) {
+		t.Errorf("unexpected accrual transaction: %+v", accrual)
+	}
+
+	// Постраничная выборка продолжается с операции, предшествующей before
+	page, err := s.GetUserTransactions(ctx, alice, 0, 1)
+	if err != nil {
+		t.Fatalf("GetUserTransactions() error = %v", err)
+	}
+	if len(page) != 1 || page[0].ID != withdrawal.ID {
+		t.Fatalf("expected first page with withdrawal, got %+v", page)
+	}
+	page, err = s.GetUserTransactions(ctx, alice, page[0].ID, 1)
+	if err != nil {
+		t.Fatalf("GetUserTransactions() error = %v", err)
+	}
+	if len(page) != 1 || page[0].ID != accrual.ID {
+		t.Errorf("expected second page with accrual, got %+v", page)
+	}
+}
+
+func testClaimAccrualJobs(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	due := []string{"12345678903", "2377225624", "79927398713"}
+	for _, number := range due {
+		if err := s.CreateOrder(ctx, alice, number, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("CreateOrder(%q) error = %v", number, err)
+		}
+	}
+	if err := s.CreateOrder(ctx, alice, "4561261212345467", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	// Реплики, опрашивающие очередь одновременно, не получают одно задание дважды
+	owners := []string{"replica-1", "replica-2", "replica-3"}
+	claimed := make([][]domain.AccrualJob, len(owners))
+	var wg sync.WaitGroup
+	for i, owner := range owners {
+		wg.Add(1)
+		go func(i int, owner string) {
+			defer wg.Done()
+			jobs, err := s.ClaimAccrualJobs(ctx, owner, 10, time.Minute)
+			if err != nil {
+				t.Errorf("ClaimAccrualJobs(%q) error = %v", owner, err)
+			}
+			claimed[i] = jobs
+		}(i, owner)
+	}
+	wg.Wait()
+
+	seen := make(map[string]string)
+	for i, jobs := range claimed {
+		for _, job := range jobs {
+			if other, ok := seen[job.OrderNumber]; ok {
+				t.Errorf("job %s claimed by both %s and %s", job.OrderNumber, other, owners[i])
+			}
+			seen[job.OrderNumber] = owners[i]
+		}
+	}
+	if len(seen) != len(due) {
+		t.Errorf("expected %d due jobs to be claimed, got %v", len(due), seen)
+	}
+
+	// Арендованные задания недоступны до истечения аренды
+	jobs, err := s.ClaimAccrualJobs(ctx, "replica-4", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimAccrualJobs() error = %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("expected no jobs while leased, got %+v", jobs)
+	}
+
+	// Реплика, потерявшая аренду, не меняет расписание и статус чужого задания
+	number, owner := due[0], seen[due[0]]
+	err = s.RescheduleAccrualJob(ctx, number, "replica-4", time.Now(), domain.AccrualPending, "")
+	if !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost on foreign reschedule, got %v", err)
+	}
+	if err := s.MarkOrderStuck(ctx, number, "replica-4", "timeout"); !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost on foreign stuck mark, got %v", err)
+	}
+	order, err := s.GetOrderByNumber(ctx, number)
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.Status != domain.StatusNew {
+		t.Errorf("expected order to stay NEW, got %s", order.Status)
+	}
+
+	// Владелец аренды откладывает задание и тем самым снимает аренду
+	nextCheckAt := time.Now().Add(time.Hour)
+	if err := s.RescheduleAccrualJob(ctx, number, owner, nextCheckAt, domain.AccrualPending, ""); err != nil {
+		t.Errorf("RescheduleAccrualJob() error = %v", err)
+	}
+	// Заказ, найденный по номеру, показывает время следующей проверки
+	if order, err = s.GetOrderByNumber(ctx, number); err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.NextCheckAt == nil || order.NextCheckAt.Sub(nextCheckAt).Abs() > time.Millisecond {
+		t.Errorf("expected next check at %v, got %v", nextCheckAt, order.NextCheckAt)
+	}
+	if err := s.MarkOrderStuck(ctx, number, owner, "timeout"); !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost after lease release, got %v", err)
+	}
+
+	number, owner = due[1], seen[due[1]]
+	if err := s.MarkOrderStuck(ctx, number, owner, "timeout"); err != nil {
+		t.Fatalf("MarkOrderStuck() error = %v", err)
+	}
+	if order, err = s.GetOrderByNumber(ctx, number); err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.Status != domain.StatusStuck || order.LastError != "timeout" {
+		t.Errorf("expected STUCK with last error, got %s %q", order.Status, order.LastError)
+	}
+	if order.NextCheckAt != nil {
+		t.Errorf("expected no next check for STUCK order, got %v", order.NextCheckAt)
+	}
+}
+
+func testExtendAccrualLeases(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	numbers := []string{"12345678903", "2377225624"}
+	for i, number := range numbers {
+		if err := s.CreateOrder(ctx, alice, number, time.Now().Add(time.Duration(i-10)*time.Second)); err != nil {
+			t.Fatalf("CreateOrder(%q) error = %v", number, err)
+		}
+	}
+
+	// replica-1 арендует только первое задание и продлевает аренду до истечения
+	leased, err := s.ClaimAccrualJobs(ctx, "replica-1", 1, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("ClaimAccrualJobs() error = %v", err)
+	}
+	if len(leased) != 1 || leased[0].OrderNumber != numbers[0] {
+		t.Fatalf("expected job %s leased, got %+v", numbers[0], leased)
+	}
+	if err := s.ExtendAccrualLeases(ctx, "replica-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ExtendAccrualLeases() error = %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// Продленное задание не достается другой реплике, а задание без аренды продление не затрагивает
+	jobs, err := s.ClaimAccrualJobs(ctx, "replica-2", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimAccrualJobs() error = %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].OrderNumber != numbers[1] {
+		t.Errorf("expected only job %s for replica-2, got %+v", numbers[1], jobs)
+	}
+}
+
+func testRequeueOrder(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	credit(t, s, alice, "79927398713", domain.Rubles(10))
+
+	invalid, stuck := "12345678903", "2377225624"
+	for _, number := range []string{invalid, stuck} {
+		if err := s.CreateOrder(ctx, alice, number, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("CreateOrder(%q) error = %v", number, err)
+		}
+	}
+	err := s.UpdateOrderStatusAndBalance(ctx, domain.OrderStatusChange{
+		OrderNumber: invalid,
+		Status:      domain.StatusInvalid,
+		Source:      domain.StatusSourcePoll,
+	}, alice)
+	if err != nil {
+		t.Fatalf("UpdateOrderStatusAndBalance() error = %v", err)
+	}
+	if jobs, err := s.ClaimAccrualJobs(ctx, "replica-1", 10, time.Minute); err != nil || len(jobs) != 1 || jobs[0].OrderNumber != stuck {
+		t.Fatalf("expected only job %s, got %+v, %v", stuck, jobs, err)
+	}
+	if err := s.MarkOrderStuck(ctx, stuck, "replica-1", "timeout"); err != nil {
+		t.Fatalf("MarkOrderStuck() error = %v", err)
+	}
+
+	// Заказы в INVALID и STUCK возвращаются в NEW с немедленной попыткой опроса
+	for _, number := range []string{invalid, stuck} {
+		entry := domain.AdminAuditEntry{Actor: "admin", Action: domain.AdminActionRequeue, Reason: "accrual fixed"}
+		if err := s.RequeueOrder(ctx, number, entry); err != nil {
+			t.Fatalf("RequeueOrder(%q) error = %v", number, err)
+		}
+		order, err := s.GetOrderByNumber(ctx, number)
+		if err != nil {
+			t.Fatalf("GetOrderByNumber(%q) error = %v", number, err)
+		}
+		if order.Status != domain.StatusNew || order.LastError != "" || order.ProcessedAt != nil || order.NextCheckAt == nil {
+			t.Errorf("expected %s back in queue as NEW, got %+v", number, order)
+		}
+		history, err := s.GetOrderStatusHistory(ctx, number)
+		if err != nil {
+			t.Fatalf("GetOrderStatusHistory(%q) error = %v", number, err)
+		}
+		if last := history[len(history)-1]; last.To != domain.StatusNew || last.Source != domain.StatusSourceAdmin {
+			t.Errorf("expected admin transition to NEW for %s, got %+v", number, last)
+		}
+	}
+	jobs, err := s.ClaimAccrualJobs(ctx, "replica-2", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimAccrualJobs() error = %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("expected both requeued jobs due, got %+v", jobs)
+	}
+
+	entry := domain.AdminAuditEntry{Actor: "admin", Action: domain.AdminActionRequeue}
+	if err := s.RequeueOrder(ctx, "79927398713", entry); !errors.Is(err, domain.ErrOrderAlreadyProcessed) {
+		t.Errorf("expected ErrOrderAlreadyProcessed, got %v", err)
+	}
+	if err := s.RequeueOrder(ctx, "4561261212345467", entry); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func testRequeueOrders(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	numbers := []string{"12345678903", "2377225624", "79927398713"}
+	for _, number := range numbers {
+		if err := s.CreateOrder(ctx, alice, number, time.Now()); err != nil {
+			t.Fatalf("CreateOrder(%q) error = %v", number, err)
+		}
+		err := s.UpdateOrderStatusAndBalance(ctx, domain.OrderStatusChange{
+			OrderNumber: number,
+			Status:      domain.StatusInvalid,
+			Source:      domain.StatusSourcePoll,
+		}, alice)
+		if err != nil {
+			t.Fatalf("UpdateOrderStatusAndBalance(%q) error = %v", number, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.CreateOrder(ctx, alice, "4561261212345467", time.Now()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	second, err := s.GetOrderByNumber(ctx, numbers[1])
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+
+	// Возвращаются только заказы с нужным статусом, загруженные в интервале
+	entry := domain.AdminAuditEntry{Actor: "admin", Action: domain.AdminActionBulkRequeue, Reason: "outage"}
+	requeued, err := s.RequeueOrders(ctx, domain.RequeueFilter{
+		Status:       domain.StatusInvalid,
+		UploadedFrom: second.UploadedAt,
+	}, entry)
+	if err != nil {
+		t.Fatalf("RequeueOrders() error = %v", err)
+	}
+	if requeued != 2 {
+		t.Errorf("expected 2 orders requeued, got %d", requeued)
+	}
+	for number, want := range map[string]domain.OrderStatus{
+		numbers[0]: domain.StatusInvalid,
+		numbers[1]: domain.StatusNew,
+		numbers[2]: domain.StatusNew,
+	} {
+		order, err := s.GetOrderByNumber(ctx, number)
+		if err != nil {
+			t.Fatalf("GetOrderByNumber(%q) error = %v", number, err)
+		}
+		if order.Status != want {
+			t.Errorf("expected %s to be %s, got %s", number, want, order.Status)
+		}
+	}
+
+	_, err = s.RequeueOrders(ctx, domain.RequeueFilter{Status: domain.StatusProcessed}, entry)
+	if !errors.Is(err, domain.ErrOrderAlreadyProcessed) {
+		t.Errorf("expected ErrOrderAlreadyProcessed for processed orders, got %v", err)
+	}
+}
+
+func testForceOrderStatus(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	if err := s.CreateOrder(ctx, alice, "12345678903", time.Now()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	// Ручной статус проходит тот же путь, что и результат опроса, включая начисление
+	change := domain.OrderStatusChange{
+		OrderNumber: "12345678903",
+		Status:      domain.StatusProcessed,
+		Accrual:     domain.Rubles(70),
+		Source:      domain.StatusSourceAdmin,
+	}
+	entry := domain.AdminAuditEntry{Actor: "admin", Action: domain.AdminActionForceStatus, Reason: "confirmed by partner"}
+	if err := s.ForceOrderStatus(ctx, change, entry); err != nil {
+		t.Fatalf("ForceOrderStatus() error = %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(70), 0)
+
+	order, err := s.GetOrderByNumber(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+	if order.Status != domain.StatusProcessed || order.ProcessedAt == nil || order.NextCheckAt != nil {
+		t.Errorf("expected processed order without queued job, got %+v", order)
+	}
+	history, err := s.GetOrderStatusHistory(ctx, "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderStatusHistory() error = %v", err)
+	}
+	if last := history[len(history)-1]; last.From != domain.StatusNew || last.To != domain.StatusProcessed ||
+		last.Source != domain.StatusSourceAdmin {
+		t.Errorf("expected admin transition NEW -> PROCESSED, got %+v", last)
+	}
+
+	// Начисленный заказ вручную не меняется, баланс не пополняется второй раз
+	if err := s.ForceOrderStatus(ctx, change, entry); !errors.Is(err, domain.ErrOrderAlreadyProcessed) {
+		t.Errorf("expected ErrOrderAlreadyProcessed, got %v", err)
+	}
+	expectBalance(t, s, alice, domain.Rubles(70), 0)
+
+	change.OrderNumber = "2377225624"
+	if err := s.ForceOrderStatus(ctx, change, entry); !errors.Is(err, domain.ErrOrderNotFound) {
+		t.Errorf("expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func testProcessedOrderPages(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+
+	numbers := []string{"12345678903", "2377225624", "79927398713", "4561261212345467", "5062821234567892"}
+	for _, number := range numbers {
+		credit(t, s, alice, number, domain.Rubles(100))
+	}
+	if err := s.CreateOrder(ctx, alice, "371449635398431", time.Now()); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	// Пачки покрывают все обработанные заказы окна, каждый ровно один раз
+	seen := make(map[string]bool)
+	var after *domain.ProcessedOrderCursor
+	for page := 0; ; page++ {
+		if page > len(numbers) {
+			t.Fatalf("pagination did not terminate, seen %v", seen)
+		}
+		orders, err := s.GetProcessedOrdersSince(ctx, time.Now().Add(-time.Hour), after, 2)
+		if err != nil {
+			t.Fatalf("GetProcessedOrdersSince() error = %v", err)
+		}
+		for _, order := range orders {
+			if seen[order.Number] {
+				t.Errorf("order %s returned twice", order.Number)
+			}
+			seen[order.Number] = true
+		}
+		if len(orders) < 2 {
+			break
+		}
+		last := orders[len(orders)-1]
+		after = &domain.ProcessedOrderCursor{ProcessedAt: *last.ProcessedAt, Number: last.Number}
+	}
+	if len(seen) != len(numbers) {
+		t.Errorf("expected %d processed orders, got %v", len(numbers), seen)
+	}
+
+	orders, err := s.GetProcessedOrdersSince(ctx, time.Now().Add(time.Hour), nil, 10)
+	if err != nil {
+		t.Fatalf("GetProcessedOrdersSince() error = %v", err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("expected no orders processed in the future, got %v", orderNumbers(orders))
+	}
+}
+
+func testReconciliationLock(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+
+	unlock, err := s.TryLockReconciliation(ctx)
+	if err != nil {
+		t.Fatalf("TryLockReconciliation() error = %v", err)
+	}
+	if _, err := s.TryLockReconciliation(ctx); !errors.Is(err, domain.ErrReconciliationLocked) {
+		t.Errorf("expected ErrReconciliationLocked while locked, got %v", err)
+	}
+	unlock()
+
+	unlock, err = s.TryLockReconciliation(ctx)
+	if err != nil {
+		t.Fatalf("TryLockReconciliation() after unlock error = %v", err)
+	}
+	unlock()
+}
+
+func testReconciliationReport(t *testing.T, s usecase.Storage) {
+	ctx := context.Background()
+
+	if _, err := s.GetLastReconciliationReport(ctx); !errors.Is(err, domain.ErrReconciliationReportNotFound) {
+		t.Errorf("expected ErrReconciliationReportNotFound before first pass, got %v", err)
+	}
+
+	startedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	for i, report := range []*domain.ReconciliationReport{
+		{StartedAt: startedAt, FinishedAt: startedAt.Add(time.Minute), Checked: 1, Discrepancies: []domain.AccrualDiscrepancy{}},
+		{
+			StartedAt:  startedAt.Add(time.Hour),
+			FinishedAt: startedAt.Add(time.Hour + time.Minute),
+			Checked:    5,
+			Failed:     1,
+			Discrepancies: []domain.AccrualDiscrepancy{{
+				OrderNumber:     "12345678903",
+				UserID:          1,
+				PreviousAccrual: domain.Rubles(500),
+				ReportedStatus:  domain.StatusProcessed,
+				ReportedAccrual: domain.Rubles(300),
+				Adjustment:      -domain.Rubles(200),
+				Debt:            domain.Rubles(50),
+				Note:            "balance insufficient, debt recorded",
+			}},
+		},
+	} {
+		if err := s.SaveReconciliationReport(ctx, report); err != nil {
+			t.Fatalf("SaveReconciliationReport(%d) error = %v", i, err)
+		}
+	}
+
+	// Возвращается отчет последнего прохода вместе с расхождениями
+	report, err := s.GetLastReconciliationReport(ctx)
+	if err != nil {
+		t.Fatalf("GetLastReconciliationReport() error = %v", err)
+	}
+	if report.Checked != 5 || report.Failed != 1 || !report.StartedAt.Equal(startedAt.Add(time.Hour)) {
+		t.Errorf("expected the latest report, got %+v", report)
+	}
+	if len(report.Discrepancies) != 1 {
+		t.Fatalf("expected 1 discrepancy, got %+v", report.Discrepancies)
+	}
+	if d := report.Discrepancies[0]; d.OrderNumber != "12345678903" || d.Adjustment != -domain.Rubles(200) ||
+		d.Debt != domain.Rubles(50) || d.ReportedStatus != domain.StatusProcessed {
+		t.Errorf("expected discrepancy to round-trip, got %+v", d)
+	}
+}
+
+func testConcurrentUsers(t *testing.T, s usecase.Storage) {
+	const attempts = 10
+
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.CreateUser(context.Background(), "alice", "hash")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, domain.ErrUserExists):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one registration, got %d", created)
+	}
+}
+
+func testConcurrentOrders(t *testing.T, s usecase.Storage) {
+	const attempts = 10
+	users := []int64{createUser(t, s, "alice"), createUser(t, s, "bob")}
+
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(userID int64) {
+			defer wg.Done()
+			errs <- s.CreateOrder(context.Background(), userID, "12345678903", time.Now())
+		}(users[i%len(users)])
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, domain.ErrOrderExists):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one order, got %d", created)
+	}
+}
+
+func testConcurrentWithdrawals(t *testing.T, s usecase.Storage) {
+	const attempts = 10
+	alice := createUser(t, s, "alice")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	// Десять списаний по 30 при балансе 100: проходят ровно три
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.CreateWithdrawal(context.Background(), alice, fmt.Sprintf("order-%d", i), domain.Rubles(30), "")
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, domain.ErrInsufficientFunds):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 3 {
+		t.Errorf("expected 3 withdrawals, got %d", succeeded)
+	}
+	expectBalance(t, s, alice, domain.Rubles(10), domain.Rubles(90))
+}
+
+func testConcurrentWithdrawalReplays(t *testing.T, s usecase.Storage) {
+	const attempts = 10
+	alice := createUser(t, s, "alice")
+	credit(t, s, alice, "12345678903", domain.Rubles(100))
+
+	// Повторы одного запроса списывают баллы один раз и возвращают то же списание
+	results := make(chan *domain.Withdrawal, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := s.CreateWithdrawal(context.Background(), alice, "2377225624", domain.Rubles(30), "key-1")
+			if err != nil {
+				t.Errorf("CreateWithdrawal() error = %v", err)
+			}
+			results <- w
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var id int64
+	created := 0
+	for w := range results {
+		if w == nil {
+			continue
+		}
+		if !w.Replayed {
+			created++
+		}
+		if id != 0 && w.ID != id {
+			t.Errorf("expected the same withdrawal for every replay, got %d and %d", id, w.ID)
+		}
+		id = w.ID
+	}
+	if created != 1 {
+		t.Errorf("expected exactly one new withdrawal, got %d", created)
+	}
+	expectBalance(t, s, alice, domain.Rubles(70), domain.Rubles(30))
+}
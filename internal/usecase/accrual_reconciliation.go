@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultReconcileInterval - период сверки по умолчанию
+	defaultReconcileInterval = time.Hour
+	// defaultReconcileWindow - глубина сверки по умолчанию
+	defaultReconcileWindow = 7 * 24 * time.Hour
+	// defaultReconcileBatchSize - число заказов, читаемых из хранилища за один запрос
+	defaultReconcileBatchSize = 500
+)
+
+// ReconciliationConfig содержит настройки периодической сверки начислений
+type ReconciliationConfig struct {
+	// Interval - период между проходами сверки
+	Interval time.Duration
+	// Window - сверяются заказы, обработанные не раньше этого срока назад
+	Window time.Duration
+	// BatchSize - число заказов, читаемых из хранилища за один запрос; проход сверяет все окно
+	BatchSize int
+}
+
+// StartReconciliation запускает периодическую сверку обработанных заказов с системой начислений.
+// Запросы сверки подчиняются тому же лимиту и паузам, что и опрос очереди.
+func (uc *orderUseCase) StartReconciliation(cfg ReconciliationConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultReconcileInterval
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultReconcileWindow
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultReconcileBatchSize
+	}
+
+	uc.wg.Add(1)
+	go func() {
+		defer uc.wg.Done()
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-uc.ctx.Done():
+				logger.Info("Context cancelled, stopping accrual reconciliation")
+				return
+			case <-ticker.C:
+				uc.ReconcileAccruals(uc.ctx, time.Now().Add(-cfg.Window), cfg.BatchSize)
+			}
+		}
+	}()
+
+	logger.Info("Accrual reconciliation started",
+		zap.Duration("interval", cfg.Interval),
+		zap.Duration("window", cfg.Window),
+		zap.Int("batch_size", cfg.BatchSize))
+}
+
+// LastReconciliationReport возвращает отчет последнего завершенного прохода сверки,
+// выполненного любой репликой. Если проходов еще не было, возвращается
+// domain.ErrReconciliationReportNotFound.
+func (uc *orderUseCase) LastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error) {
+	report, err := uc.storage.GetLastReconciliationReport(ctx)
+	if err != nil {
+		if !errors.Is(err, domain.ErrReconciliationReportNotFound) {
+			logger.Error("Failed to get reconciliation report", zap.Error(err))
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+// ReconcileAccruals повторно запрашивает начисления по всем заказам, обработанным начиная с since,
+// читая их пачками по batchSize, и проводит корректировки по расхождениям. Проход выполняет
+// одна реплика: если сверку уже ведет другая, проход пропускается и возвращается nil.
+// Корректировка применяется, только если начисление в хранилище не изменилось с момента
+// чтения, поэтому разница не проводится дважды.
+func (uc *orderUseCase) ReconcileAccruals(ctx context.Context, since time.Time, batchSize int) *domain.ReconciliationReport {
+	unlock, err := uc.storage.TryLockReconciliation(ctx)
+	if err != nil {
+		if errors.Is(err, domain.ErrReconciliationLocked) {
+			logger.Info("Accrual reconciliation is running on another replica, skipping")
+		} else {
+			logger.Error("Failed to lock accrual reconciliation", zap.Error(err))
+		}
+		return nil
+	}
+	defer unlock()
+
+	report := &domain.ReconciliationReport{
+		StartedAt:     time.Now(),
+		Discrepancies: []domain.AccrualDiscrepancy{},
+	}
+
+	var after *domain.ProcessedOrderCursor
+	for ctx.Err() == nil {
+		orders, err := uc.storage.GetProcessedOrdersSince(ctx, since, after, batchSize)
+		if err != nil {
+			logger.Error("Failed to get orders for reconciliation", zap.Error(err))
+			report.Failed++
+			break
+		}
+
+		uc.reconcileOrders(ctx, orders, report)
+
+		if len(orders) < batchSize {
+			break
+		}
+		last := orders[len(orders)-1]
+		after = &domain.ProcessedOrderCursor{ProcessedAt: *last.ProcessedAt, Number: last.Number}
+	}
+
+	report.FinishedAt = time.Now()
+
+	logger.Info("Accrual reconciliation finished",
+		zap.Int("checked", report.Checked),
+		zap.Int("failed", report.Failed),
+		zap.Int("discrepancies", len(report.Discrepancies)),
+		zap.Duration("duration", report.FinishedAt.Sub(report.StartedAt)))
+
+	// Отчет сохраняется в хранилище, чтобы его отдавала любая реплика
+	if err := uc.storage.SaveReconciliationReport(context.WithoutCancel(ctx), report); err != nil {
+		logger.Error("Failed to save reconciliation report", zap.Error(err))
+	}
+
+	return report
+}
+
+// reconcileOrders сверяет пачку заказов и добавляет результаты в report
+func (uc *orderUseCase) reconcileOrders(ctx context.Context, orders []domain.Order, report *domain.ReconciliationReport) {
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return
+		}
+
+		discrepancy, err := uc.reconcileOrder(ctx, order)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			logger.Error("Failed to reconcile order accrual",
+				zap.Error(err),
+				zap.String("order", order.Number))
+			report.Failed++
+			continue
+		}
+
+		report.Checked++
+		if discrepancy != nil {
+			logger.Warn("Accrual discrepancy found",
+				zap.String("order", discrepancy.OrderNumber),
+				zap.Int64("user_id", discrepancy.UserID),
+				zap.Stringer("previous_accrual", discrepancy.PreviousAccrual),
+				zap.String("reported_status", string(discrepancy.ReportedStatus)),
+				zap.Stringer("reported_accrual", discrepancy.ReportedAccrual),
+				zap.Stringer("adjustment", discrepancy.Adjustment),
+				zap.Stringer("debt", discrepancy.Debt),
+				zap.String("note", discrepancy.Note))
+			report.Discrepancies = append(report.Discrepancies, *discrepancy)
+		}
+	}
+}
+
+// reconcileOrder сверяет один заказ. Возвращает nil, если расхождения нет.
+// Смена статуса или пропажа заказа в системе начислений попадают в отчет без корректировки.
+func (uc *orderUseCase) reconcileOrder(ctx context.Context, order domain.Order) (*domain.AccrualDiscrepancy, error) {
+	if err := uc.throttle.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	reported, err := uc.accrual.GetOrderAccrual(ctx, order.Number)
+	if err != nil {
+		// Ответ 429 или разомкнутый выключатель приостанавливают и опрос очереди
+		var tooManyRequestsErr *domain.TooManyRequestsError
+		if errors.As(err, &tooManyRequestsErr) {
+			uc.pauseAccrualRequests(ctx, tooManyRequestsErr.RetryAfter)
+		}
+		var circuitOpenErr *domain.CircuitOpenError
+		if errors.As(err, &circuitOpenErr) {
+			uc.pauseAccrualRequests(ctx, circuitOpenErr.RetryAfter)
+		}
+		return nil, err
+	}
+
+	discrepancy := &domain.AccrualDiscrepancy{
+		OrderNumber:     order.Number,
+		UserID:          order.UserID,
+		PreviousAccrual: order.Accrual,
+	}
+
+	if reported == nil {
+		discrepancy.Note = "order not registered in accrual system"
+		return discrepancy, nil
+	}
+
+	discrepancy.ReportedStatus = reported.Status
+	discrepancy.ReportedAccrual = reported.Accrual
+
+	if reported.Status != domain.StatusProcessed {
+		discrepancy.Note = fmt.Sprintf("status changed to %s, manual review required", reported.Status)
+		return discrepancy, nil
+	}
+
+	if order.Accrual == reported.Accrual {
+		return nil, nil
+	}
+
+	adjustment, err := uc.storage.ApplyAccrualAdjustment(ctx, order.Number, order.Accrual, reported.Accrual)
+	if err != nil {
+		if errors.Is(err, domain.ErrAccrualChanged) {
+			// Начисление изменилось после чтения - следующий проход сверит новое значение
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	discrepancy.Adjustment = adjustment.Amount
+	discrepancy.Debt = adjustment.Debt
+	if adjustment.Debt > 0 {
+		discrepancy.Note = "balance insufficient, debt recorded"
+	}
+
+	return discrepancy, nil
+}
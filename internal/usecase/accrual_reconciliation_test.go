@@ -0,0 +1,218 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/usecase/mocks"
+)
+
+func TestOrderUseCase_ReconcileAccruals(t *testing.T) {
+	tests := []struct {
+		name             string
+		reported         *domain.Order
+		accrualErr       error
+		adjustErr        error
+		adjustDebt       domain.Money
+		wantAdjust       bool
+		wantDiscrepancy  bool
+		wantFailed       int
+		wantNote         bool
+		wantAdjustAmount domain.Money
+	}{
+		{
+			name:     "Начисление не изменилось",
+			reported: &domain.Order{Number: "12345678903", Status: domain.StatusProcessed, Accrual: domain.Rubles(500)},
+		},
+		{
+			name:             "Начисление увеличилось",
+			reported:         &domain.Order{Number: "12345678903", Status: domain.StatusProcessed, Accrual: domain.Rubles(600)},
+			wantAdjust:       true,
+			wantDiscrepancy:  true,
+			wantAdjustAmount: domain.Rubles(100),
+		},
+		{
+			name:             "Начисление уменьшилось сверх баланса",
+			reported:         &domain.Order{Number: "12345678903", Status: domain.StatusProcessed, Accrual: domain.Rubles(100)},
+			adjustDebt:       domain.Rubles(150),
+			wantAdjust:       true,
+			wantDiscrepancy:  true,
+			wantNote:         true,
+			wantAdjustAmount: domain.Rubles(-400),
+		},
+		{
+			name:            "Заказ стал недействительным",
+			reported:        &domain.Order{Number: "12345678903", Status: domain.StatusInvalid},
+			wantDiscrepancy: true,
+			wantNote:        true,
+		},
+		{
+			name:            "Заказ пропал из системы начислений",
+			wantDiscrepancy: true,
+			wantNote:        true,
+		},
+		{
+			name:       "Начисление изменено параллельно",
+			reported:   &domain.Order{Number: "12345678903", Status: domain.StatusProcessed, Accrual: domain.Rubles(600)},
+			adjustErr:  domain.ErrAccrualChanged,
+			wantAdjust: true,
+		},
+		{
+			name:       "Система начислений недоступна",
+			accrualErr: errors.New("connection refused"),
+			wantFailed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var adjusted bool
+			var saved *domain.ReconciliationReport
+			mockStorage := &mocks.MockStorage{
+				SaveReconciliationReportFunc: func(ctx context.Context, report *domain.ReconciliationReport) error {
+					saved = report
+					return nil
+				},
+				GetProcessedOrdersSinceFunc: func(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error) {
+					return []domain.Order{
+						{Number: "12345678903", UserID: 1, Status: domain.StatusProcessed, Accrual: domain.Rubles(500)},
+					}, nil
+				},
+				ApplyAccrualAdjustmentFunc: func(ctx context.Context, orderNumber string, previousAccrual, newAccrual domain.Money) (*domain.AccrualAdjustment, error) {
+					adjusted = true
+					if previousAccrual != domain.Rubles(500) || newAccrual != tt.reported.Accrual {
+						t.Errorf("Expected adjustment 500 -> %v, got %v -> %v", tt.reported.Accrual, previousAccrual, newAccrual)
+					}
+					if tt.adjustErr != nil {
+						return nil, tt.adjustErr
+					}
+					return &domain.AccrualAdjustment{
+						OrderNumber: orderNumber,
+						Amount:      newAccrual - previousAccrual,
+						Debt:        tt.adjustDebt,
+					}, nil
+				},
+			}
+			mockAccrual := &mocks.MockAccrualService{
+				GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+					return tt.reported, tt.accrualErr
+				},
+			}
+
+			uc := NewOrderUseCase(mockStorage, mockAccrual)
+			report := uc.ReconcileAccruals(context.Background(), time.Now().Add(-time.Hour), 10)
+
+			if adjusted != tt.wantAdjust {
+				t.Errorf("Expected adjustment applied %v, got %v", tt.wantAdjust, adjusted)
+			}
+			if report.Failed != tt.wantFailed {
+				t.Errorf("Expected %d failed orders, got %d", tt.wantFailed, report.Failed)
+			}
+			if got := len(report.Discrepancies) > 0; got != tt.wantDiscrepancy {
+				t.Fatalf("Expected discrepancy %v, got %+v", tt.wantDiscrepancy, report.Discrepancies)
+			}
+			if tt.wantDiscrepancy {
+				discrepancy := report.Discrepancies[0]
+				if discrepancy.Adjustment != tt.wantAdjustAmount {
+					t.Errorf("Expected adjustment %v, got %v", tt.wantAdjustAmount, discrepancy.Adjustment)
+				}
+				if (discrepancy.Note != "") != tt.wantNote {
+					t.Errorf("Expected note %v, got %q", tt.wantNote, discrepancy.Note)
+				}
+			}
+			if saved != report {
+				t.Errorf("Expected report to be saved to storage")
+			}
+		})
+	}
+}
+
+func TestOrderUseCase_ReconcileAccrualsPages(t *testing.T) {
+	processedAt := time.Now().Add(-time.Minute)
+	var window []domain.Order
+	for _, number := range []string{"79927398713", "2377225624", "12345678903"} {
+		window = append(window, domain.Order{Number: number, UserID: 1, Status: domain.StatusProcessed, Accrual: domain.Rubles(500), ProcessedAt: &processedAt})
+	}
+
+	var pages []*domain.ProcessedOrderCursor
+	mockStorage := &mocks.MockStorage{
+		GetProcessedOrdersSinceFunc: func(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error) {
+			pages = append(pages, after)
+			start := 0
+			if after != nil {
+				for i, order := range window {
+					if order.Number == after.Number {
+						start = i + 1
+					}
+				}
+			}
+			return window[start:min(start+limit, len(window))], nil
+		},
+	}
+	checked := make(map[string]int)
+	mockAccrual := &mocks.MockAccrualService{
+		GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+			checked[orderNumber]++
+			return &domain.Order{Number: orderNumber, Status: domain.StatusProcessed, Accrual: domain.Rubles(500)}, nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, mockAccrual)
+	report := uc.ReconcileAccruals(context.Background(), time.Now().Add(-time.Hour), 2)
+
+	// Окно больше пачки сверяется целиком, каждый заказ - один раз
+	if report.Checked != len(window) || len(checked) != len(window) {
+		t.Errorf("Expected %d orders checked once, got %d: %v", len(window), report.Checked, checked)
+	}
+	if len(pages) != 2 || pages[0] != nil || pages[1] == nil || pages[1].Number != "2377225624" {
+		t.Errorf("Expected second page after 2377225624, got %+v", pages)
+	}
+}
+
+func TestOrderUseCase_ReconcileAccrualsLocked(t *testing.T) {
+	mockStorage := &mocks.MockStorage{
+		TryLockReconciliationFunc: func(ctx context.Context) (func(), error) {
+			return nil, domain.ErrReconciliationLocked
+		},
+		GetProcessedOrdersSinceFunc: func(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error) {
+			t.Error("Orders must not be read while another replica reconciles")
+			return nil, nil
+		},
+		SaveReconciliationReportFunc: func(ctx context.Context, report *domain.ReconciliationReport) error {
+			t.Error("Skipped pass must not replace the report of another replica")
+			return nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+	if report := uc.ReconcileAccruals(context.Background(), time.Now().Add(-time.Hour), 10); report != nil {
+		t.Errorf("Expected skipped pass without report, got %+v", report)
+	}
+}
+
+func TestOrderUseCase_LastReconciliationReport(t *testing.T) {
+	stored := &domain.ReconciliationReport{Checked: 7}
+	mockStorage := &mocks.MockStorage{
+		GetLastReconciliationReportFunc: func(ctx context.Context) (*domain.ReconciliationReport, error) {
+			return stored, nil
+		},
+	}
+
+	// Отчет читается из хранилища, поэтому его отдает и реплика, не выполнявшая проход
+	uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+	report, err := uc.LastReconciliationReport(context.Background())
+	if err != nil {
+		t.Fatalf("LastReconciliationReport() error = %v", err)
+	}
+	if report != stored {
+		t.Errorf("Expected stored report, got %+v", report)
+	}
+
+	uc = NewOrderUseCase(&mocks.MockStorage{}, &mocks.MockAccrualService{})
+	if _, err := uc.LastReconciliationReport(context.Background()); !errors.Is(err, domain.ErrReconciliationReportNotFound) {
+		t.Errorf("Expected ErrReconciliationReportNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"gophermart/internal/domain"
+)
+
+// AccrualRetryPolicy описывает повторные попытки опроса для одного класса ошибок
+type AccrualRetryPolicy struct {
+	// BaseDelay - задержка перед первой повторной попыткой, далее удваивается
+	BaseDelay time.Duration
+	// MaxDelay - верхняя граница задержки
+	MaxDelay time.Duration
+	// MaxAttempts - число подряд идущих попыток с этим классом, 0 - без ограничения
+	MaxAttempts int
+	// MaxAge - время с постановки задания в очередь, после которого попытки прекращаются, 0 - без ограничения
+	MaxAge time.Duration
+}
+
+// AccrualRetryPolicies содержит политики повторных попыток по классам ошибок
+type AccrualRetryPolicies struct {
+	// Unavailable - сетевые ошибки и ответы 5xx системы начислений
+	Unavailable AccrualRetryPolicy
+	// NotRegistered - заказ ещё не зарегистрирован в системе начислений
+	NotRegistered AccrualRetryPolicy
+	// InvalidResponse - ответ системы начислений не прошел проверку
+	InvalidResponse AccrualRetryPolicy
+	// Storage - ошибки сохранения результата
+	Storage AccrualRetryPolicy
+	// Pending - расчёт начисления ещё не окончен
+	Pending AccrualRetryPolicy
+}
+
+// DefaultAccrualRetryPolicies возвращает политики повторных попыток по умолчанию
+func DefaultAccrualRetryPolicies() AccrualRetryPolicies {
+	return AccrualRetryPolicies{
+		Unavailable: AccrualRetryPolicy{
+			BaseDelay: 2 * time.Second,
+			MaxDelay:  5 * time.Minute,
+			MaxAge:    72 * time.Hour,
+		},
+		NotRegistered: AccrualRetryPolicy{
+			BaseDelay: 5 * time.Second,
+			MaxDelay:  time.Hour,
+			MaxAge:    72 * time.Hour,
+		},
+		InvalidResponse: AccrualRetryPolicy{
+			BaseDelay:   30 * time.Second,
+			MaxDelay:    30 * time.Minute,
+			MaxAttempts: 10,
+		},
+		Storage: AccrualRetryPolicy{
+			BaseDelay:   time.Second,
+			MaxDelay:    time.Minute,
+			MaxAttempts: 20,
+		},
+		Pending: AccrualRetryPolicy{
+			BaseDelay: accrualPollInterval,
+			MaxDelay:  10 * time.Minute,
+			MaxAge:    7 * 24 * time.Hour,
+		},
+	}
+}
+
+// policyFor возвращает политику для класса ошибки
+func (p AccrualRetryPolicies) policyFor(class domain.AccrualErrorClass) AccrualRetryPolicy {
+	switch class {
+	case domain.AccrualErrorUnavailable:
+		return p.Unavailable
+	case domain.AccrualErrorNotRegistered:
+		return p.NotRegistered
+	case domain.AccrualErrorInvalidResponse:
+		return p.InvalidResponse
+	case domain.AccrualErrorStorage:
+		return p.Storage
+	default:
+		return p.Pending
+	}
+}
+
+// exhausted сообщает, что attempt-я подряд попытка класса или возраст задания превышают лимиты
+func (p AccrualRetryPolicy) exhausted(attempt int, age time.Duration) bool {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return true
+	}
+	return p.MaxAge > 0 && age >= p.MaxAge
+}
+
+// delay вычисляет экспоненциальную задержку перед попыткой attempt+1 со случайной
+// составляющей: результат лежит в интервале [d/2, d], чтобы реплики не опрашивали синхронно
+func (p AccrualRetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
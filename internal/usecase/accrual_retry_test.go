@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccrualRetryPolicy_Delay(t *testing.T) {
+	policy := AccrualRetryPolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  10 * time.Second,
+	}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: time.Second},
+		{attempt: 2, max: 2 * time.Second},
+		{attempt: 3, max: 4 * time.Second},
+		{attempt: 10, max: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			d := policy.delay(tt.attempt)
+			if d < tt.max/2 || d > tt.max {
+				t.Errorf("delay(%d) = %v, want in [%v, %v]", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestAccrualRetryPolicy_Exhausted(t *testing.T) {
+	policy := AccrualRetryPolicy{MaxAttempts: 3, MaxAge: time.Hour}
+
+	if policy.exhausted(2, time.Minute) {
+		t.Error("expected policy not to be exhausted")
+	}
+	if !policy.exhausted(3, time.Minute) {
+		t.Error("expected policy to be exhausted by attempts")
+	}
+	if !policy.exhausted(1, 2*time.Hour) {
+		t.Error("expected policy to be exhausted by age")
+	}
+	if (AccrualRetryPolicy{}).exhausted(1000, 1000*time.Hour) {
+		t.Error("expected zero policy to retry forever")
+	}
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultPollSchedule - расписание опроса по умолчанию: часто в первые минуты,
+// затем всё реже вплоть до раза в час
+const defaultPollSchedule = "0s:5s,2m:30s,15m:2m,1h:10m,24h:1h"
+
+// PollScheduleStep задает интервал опроса для заказов не моложе MinAge
+type PollScheduleStep struct {
+	MinAge   time.Duration
+	Interval time.Duration
+}
+
+// PollSchedule - ступенчатое расписание опроса заказов, расчёт которых ещё не окончен.
+// Шаги упорядочены по возрастанию MinAge.
+type PollSchedule []PollScheduleStep
+
+// DefaultPollSchedule возвращает расписание опроса по умолчанию
+func DefaultPollSchedule() PollSchedule {
+	schedule, err := ParsePollSchedule(defaultPollSchedule)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+// ParsePollSchedule разбирает расписание вида "0s:5s,5m:30s,1h:5m,24h:1h",
+// где каждый шаг - "возраст заказа:интервал опроса"
+func ParsePollSchedule(value string) (PollSchedule, error) {
+	var schedule PollSchedule
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		age, interval, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid poll schedule step %q: expected age:interval", part)
+		}
+
+		var step PollScheduleStep
+		var err error
+		if step.MinAge, err = time.ParseDuration(strings.TrimSpace(age)); err != nil || step.MinAge < 0 {
+			return nil, fmt.Errorf("invalid poll schedule age %q", age)
+		}
+		if step.Interval, err = time.ParseDuration(strings.TrimSpace(interval)); err != nil || step.Interval <= 0 {
+			return nil, fmt.Errorf("invalid poll schedule interval %q", interval)
+		}
+		schedule = append(schedule, step)
+	}
+
+	if len(schedule) == 0 {
+		return nil, fmt.Errorf("poll schedule is empty")
+	}
+
+	sort.SliceStable(schedule, func(i, j int) bool {
+		return schedule[i].MinAge < schedule[j].MinAge
+	})
+	return schedule, nil
+}
+
+// Interval возвращает интервал опроса для заказа возраста age.
+// Для заказов моложе первого шага используется интервал первого шага.
+func (s PollSchedule) Interval(age time.Duration) time.Duration {
+	if len(s) == 0 {
+		return accrualPollInterval
+	}
+
+	interval := s[0].Interval
+	for _, step := range s {
+		if age < step.MinAge {
+			break
+		}
+		interval = step.Interval
+	}
+	return interval
+}
+
+// Next возвращает время следующего опроса заказа, загруженного в uploadedAt
+func (s PollSchedule) Next(uploadedAt, now time.Time) time.Time {
+	return now.Add(s.Interval(now.Sub(uploadedAt)))
+}
+
+// String возвращает расписание в формате ParsePollSchedule
+func (s PollSchedule) String() string {
+	parts := make([]string, 0, len(s))
+	for _, step := range s {
+		parts = append(parts, step.MinAge.String()+":"+step.Interval.String())
+	}
+	return strings.Join(parts, ",")
+}
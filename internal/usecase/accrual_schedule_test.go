@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/usecase/mocks"
+)
+
+func TestParsePollSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Расписание по умолчанию", value: defaultPollSchedule, want: "0s:5s,2m0s:30s,15m0s:2m0s,1h0m0s:10m0s,24h0m0s:1h0m0s"},
+		{name: "Шаги сортируются по возрасту", value: "1h:10m, 0s:5s", want: "0s:5s,1h0m0s:10m0s"},
+		{name: "Пустое расписание", value: " ", wantErr: true},
+		{name: "Шаг без интервала", value: "0s", wantErr: true},
+		{name: "Нулевой интервал", value: "0s:0s", wantErr: true},
+		{name: "Отрицательный возраст", value: "-1m:5s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParsePollSchedule(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := schedule.String(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPollSchedule_Interval(t *testing.T) {
+	schedule, err := ParsePollSchedule("1m:30s,0s:5s,1h:10m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		age  time.Duration
+		want time.Duration
+	}{
+		{age: 0, want: 5 * time.Second},
+		{age: 59 * time.Second, want: 5 * time.Second},
+		{age: time.Minute, want: 30 * time.Second},
+		{age: 5 * 24 * time.Hour, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := schedule.Interval(tt.age); got != tt.want {
+			t.Errorf("Interval(%v) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestOrderUseCase_ProcessOrderAccrual_PendingFollowsSchedule(t *testing.T) {
+	var nextAttempt time.Time
+	mockStorage := &mocks.MockStorage{
+		GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+			return &domain.Order{Number: number, UserID: 1, Status: domain.StatusProcessing}, nil
+		},
+		RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+			nextAttempt = nextAttemptAt
+			return nil
+		},
+	}
+	mockAccrual := &mocks.MockAccrualService{
+		GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+			return &domain.Order{Number: orderNumber, Status: domain.StatusProcessing}, nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, mockAccrual)
+	uc.schedule = PollSchedule{{MinAge: 0, Interval: 5 * time.Second}, {MinAge: time.Hour, Interval: 10 * time.Minute}}
+
+	// Заказ двухчасовой давности опрашивается по шагу для заказов старше часа
+	uc.processOrderAccrual(context.Background(), domain.AccrualJob{
+		OrderNumber: "12345678903",
+		CreatedAt:   time.Now(),
+		UploadedAt:  time.Now().Add(-2 * time.Hour),
+	})
+
+	if wait := time.Until(nextAttempt); wait < 9*time.Minute || wait > 10*time.Minute {
+		t.Errorf("Expected next check in about 10m, got %v", wait)
+	}
+}
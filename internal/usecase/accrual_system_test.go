@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gophermart/internal/accrual"
+	"gophermart/internal/accrualsystem"
+	"gophermart/internal/domain"
+	"gophermart/internal/usecase/mocks"
+)
+
+// Проверяет обработку заказа против эталонной системы начислений с реальным расчётом вознаграждения
+func TestOrderUseCase_ProcessOrderAccrual_ReferenceSystem(t *testing.T) {
+	system := accrualsystem.NewSystem(accrualsystem.Config{ProcessingDelay: 5 * time.Millisecond})
+	defer system.Close()
+	ts := httptest.NewServer(accrualsystem.NewHandler(system, 0))
+	defer ts.Close()
+
+	if err := system.RegisterReward(accrualsystem.Reward{Match: "Bork", Reward: 7.5, RewardType: accrualsystem.RewardPercent}); err != nil {
+		t.Fatalf("RegisterReward() error = %v", err)
+	}
+	if err := system.RegisterOrder(accrualsystem.OrderRegistration{
+		Order: "12345678903",
+		Goods: []accrualsystem.Good{{Description: "Миксер Bork", Price: 1999.99}},
+	}); err != nil {
+		t.Fatalf("RegisterOrder() error = %v", err)
+	}
+
+	type update struct {
+		status  domain.OrderStatus
+		accrual domain.Money
+	}
+	updates := make(chan update, 10)
+
+	mockStorage := &mocks.MockStorage{
+		GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+			return &domain.Order{Number: number, UserID: 1, Status: domain.StatusNew}, nil
+		},
+		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+			updates <- update{status: change.Status, accrual: change.Accrual}
+			return nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, accrual.NewService(ts.URL))
+	job := domain.AccrualJob{OrderNumber: "12345678903"}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		uc.processOrderAccrual(context.Background(), job)
+
+		select {
+		case u := <-updates:
+			if u.status != domain.StatusProcessed {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			if u.accrual != domain.Rubles(150) {
+				t.Errorf("Expected accrual %v, got %v", domain.Rubles(150), u.accrual)
+			}
+			return
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	t.Fatal("Order was not processed")
+}
+
+// Проверяет обработку заказа по записанному обмену с системой начислений
+func TestOrderUseCase_ProcessOrderAccrual_Cassette(t *testing.T) {
+	client, err := accrual.NewHTTPClient(accrual.TransportConfig{
+		CassetteMode: accrual.CassetteReplay,
+		CassettePath: "testdata/accrual_cassette.json",
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+
+	var statuses []domain.OrderStatus
+	var final domain.OrderStatusChange
+	reschedules := 0
+
+	mockStorage := &mocks.MockStorage{
+		GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+			return &domain.Order{Number: number, UserID: 1, Status: domain.StatusNew}, nil
+		},
+		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+			statuses = append(statuses, change.Status)
+			final = change
+			return nil
+		},
+		RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+			reschedules++
+			return nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, accrual.NewServiceWithClient(client, "http://accrual.invalid"))
+	job := domain.AccrualJob{OrderNumber: "12345678903"}
+	for i := 0; i < 4; i++ {
+		uc.processOrderAccrual(context.Background(), job)
+		job.Attempts++
+	}
+
+	if final.Status != domain.StatusProcessed || final.Accrual != 72998 {
+		t.Fatalf("Expected PROCESSED with accrual 729.98, got %+v (statuses %v)", final, statuses)
+	}
+	if reschedules == 0 {
+		t.Error("Expected unfinished responses to reschedule the job")
+	}
+}
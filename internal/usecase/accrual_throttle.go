@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// accrualThrottle ограничивает частоту запросов к системе начислений и
+// приостанавливает их для всех обработчиков после ответа 429
+type accrualThrottle struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	next        time.Time
+	pausedUntil time.Time
+}
+
+// newAccrualThrottle создает ограничитель на requestsPerSecond запросов в секунду.
+// Нулевое или отрицательное значение отключает ограничение частоты.
+func newAccrualThrottle(requestsPerSecond float64) *accrualThrottle {
+	t := &accrualThrottle{}
+	if requestsPerSecond > 0 {
+		t.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return t
+}
+
+// Pause приостанавливает все запросы на d и возвращает момент возобновления.
+// Более ранний срок не сокращает уже действующую паузу.
+func (t *accrualThrottle) Pause(d time.Duration) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deadline := time.Now().Add(d)
+	if deadline.After(t.pausedUntil) {
+		t.pausedUntil = deadline
+	}
+	return t.pausedUntil
+}
+
+// PausedUntil возвращает момент окончания текущей паузы
+func (t *accrualThrottle) PausedUntil() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pausedUntil
+}
+
+// Wait блокирует вызывающего до момента, когда запрос разрешен, или до отмены контекста
+func (t *accrualThrottle) Wait(ctx context.Context) error {
+	for {
+		delay, reserved := t.reserve()
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+
+		// Пока мы ждали свой слот, другой обработчик мог получить 429
+		if reserved && !time.Now().Before(t.PausedUntil()) {
+			return nil
+		}
+	}
+}
+
+// reserve резервирует слот для запроса и возвращает время ожидания до него.
+// Если действует пауза, слот не резервируется и возвращается время до её окончания.
+func (t *accrualThrottle) reserve() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(t.pausedUntil) {
+		return t.pausedUntil.Sub(now), false
+	}
+
+	if t.interval <= 0 {
+		return 0, true
+	}
+
+	slot := now
+	if t.next.After(slot) {
+		slot = t.next
+	}
+	t.next = slot.Add(t.interval)
+	return slot.Sub(now), true
+}
+
+// sleepContext ждет d или отмены контекста
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
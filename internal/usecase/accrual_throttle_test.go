@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAccrualThrottle_RateLimit(t *testing.T) {
+	throttle := newAccrualThrottle(50)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := throttle.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	// Первый запрос проходит сразу, остальные - с интервалом 20мс
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("Expected rate limit to delay requests, elapsed %v", elapsed)
+	}
+}
+
+func TestAccrualThrottle_Pause(t *testing.T) {
+	throttle := newAccrualThrottle(0)
+
+	throttle.Pause(50 * time.Millisecond)
+	// Более короткая пауза не сокращает уже действующую
+	throttle.Pause(time.Millisecond)
+
+	start := time.Now()
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected Wait to respect pause, elapsed %v", elapsed)
+	}
+}
+
+func TestAccrualThrottle_WaitCancelled(t *testing.T) {
+	throttle := newAccrualThrottle(0)
+	throttle.Pause(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := throttle.Wait(ctx); err == nil {
+		t.Error("Expected error on cancelled context")
+	}
+}
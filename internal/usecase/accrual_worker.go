@@ -0,0 +1,390 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// accrualPollInterval - период проверки очереди заданий
+	accrualPollInterval = 2 * time.Second
+	// defaultAccrualWorkers - число обработчиков по умолчанию
+	defaultAccrualWorkers = 4
+	// defaultAccrualLeaseTTL - срок аренды задания по умолчанию
+	defaultAccrualLeaseTTL = 30 * time.Second
+)
+
+// AccrualWorkerConfig содержит настройки обработки очереди начислений
+type AccrualWorkerConfig struct {
+	// Workers - число заказов, опрашиваемых одновременно
+	Workers int
+	// RequestsPerSecond - общий лимит запросов к системе начислений, 0 - без ограничения
+	RequestsPerSecond float64
+	// InstanceID - идентификатор реплики, от имени которой арендуются задания
+	InstanceID string
+	// LeaseTTL - срок аренды задания; по его истечении задание может забрать другая реплика
+	LeaseTTL time.Duration
+	// Retry - политики повторных попыток; нулевое значение заменяется DefaultAccrualRetryPolicies
+	Retry *AccrualRetryPolicies
+	// Schedule - расписание опроса заказов, расчёт которых не окончен; пустое значение
+	// заменяется DefaultPollSchedule
+	Schedule PollSchedule
+	// CallbackTimeout - сколько ждать уведомления о расчёте перед первым опросом нового заказа;
+	// 0 - опрашивать сразу
+	CallbackTimeout time.Duration
+}
+
+// accrualTask - задание, переданное обработчику, вместе с признаком завершения пачки
+type accrualTask struct {
+	job   domain.AccrualJob
+	batch *sync.WaitGroup
+}
+
+// StartAccrualWorker запускает диспетчер очереди заданий на опрос системы начислений
+// и пул из cfg.Workers обработчиков. Задания, накопившиеся до запуска, обрабатываются сразу,
+// остальные - по тикеру.
+func (uc *orderUseCase) StartAccrualWorker(cfg AccrualWorkerConfig) {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultAccrualWorkers
+	}
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = defaultAccrualLeaseTTL
+	}
+	uc.throttle = newAccrualThrottle(cfg.RequestsPerSecond)
+	uc.instanceID = cfg.InstanceID
+	uc.leaseTTL = cfg.LeaseTTL
+	uc.workers = workers
+	if cfg.Retry != nil {
+		uc.retry = *cfg.Retry
+	}
+	if len(cfg.Schedule) > 0 {
+		uc.schedule = cfg.Schedule
+	}
+	uc.callbackTimeout = cfg.CallbackTimeout
+
+	tasks := make(chan accrualTask)
+
+	for i := 0; i < workers; i++ {
+		uc.wg.Add(1)
+		go func() {
+			defer uc.wg.Done()
+			for task := range tasks {
+				uc.processOrderAccrual(uc.ctx, task.job)
+				task.batch.Done()
+			}
+		}()
+	}
+
+	uc.wg.Add(1)
+	go func() {
+		defer uc.wg.Done()
+		defer close(tasks)
+		uc.runAccrualDispatcher(tasks)
+	}()
+
+	logger.Info("Accrual worker started",
+		zap.Int("workers", workers),
+		zap.Float64("requests_per_second", cfg.RequestsPerSecond),
+		zap.String("instance_id", cfg.InstanceID),
+		zap.Duration("lease_ttl", cfg.LeaseTTL),
+		zap.Duration("callback_timeout", cfg.CallbackTimeout),
+		zap.Stringer("poll_schedule", uc.schedule))
+}
+
+// runAccrualDispatcher раздает задания обработчикам до отмены контекста
+func (uc *orderUseCase) runAccrualDispatcher(tasks chan<- accrualTask) {
+	ticker := time.NewTicker(accrualPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока очередь отдает полные пачки, продолжаем без ожидания тикера
+		if uc.dispatchDueAccrualJobs(tasks) == uc.workers && uc.ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-uc.ctx.Done():
+			logger.Info("Context cancelled, stopping accrual worker")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchDueAccrualJobs арендует задания, время которых наступило, передает их
+// обработчикам и дожидается их обработки. Возвращает число арендованных заданий.
+func (uc *orderUseCase) dispatchDueAccrualJobs(tasks chan<- accrualTask) int {
+	// Во время паузы задания не арендуем: аренда истекла бы раньше, чем разрешен опрос
+	if err := sleepContext(uc.ctx, time.Until(uc.throttle.PausedUntil())); err != nil {
+		return 0
+	}
+
+	// Арендуем не больше заданий, чем обработчиков, чтобы аренда начиналась незадолго до опроса
+	jobs, err := uc.storage.ClaimAccrualJobs(uc.ctx, uc.instanceID, uc.workers, uc.leaseTTL)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to claim accrual jobs", zap.Error(err))
+		}
+		return 0
+	}
+
+	// Ждем всю пачку, прежде чем арендовать следующую
+	var batch sync.WaitGroup
+	defer batch.Wait()
+
+	for i, job := range jobs {
+		batch.Add(1)
+		select {
+		case tasks <- accrualTask{job: job, batch: &batch}:
+		case <-uc.ctx.Done():
+			batch.Done()
+			return i
+		}
+	}
+
+	return len(jobs)
+}
+
+// processOrderAccrual выполняет одну попытку получения начисления за заказ
+func (uc *orderUseCase) processOrderAccrual(ctx context.Context, job domain.AccrualJob) {
+	orderNumber := job.OrderNumber
+
+	// Соблюдаем общий лимит запросов и паузу после ответа 429
+	if err := uc.throttle.Wait(ctx); err != nil {
+		return
+	}
+
+	// Получаем информацию о начислении
+	logger.Info("Requesting accrual info",
+		zap.String("order", orderNumber),
+		zap.Int("attempt", job.Attempts+1))
+	order, err := uc.accrual.GetOrderAccrual(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
+		// Ответ 429 приостанавливает запросы всех обработчиков до истечения Retry-After
+		// и не расходует попытки задания
+		var tooManyRequestsErr *domain.TooManyRequestsError
+		if errors.As(err, &tooManyRequestsErr) {
+			pausedUntil := uc.pauseAccrualRequests(ctx, tooManyRequestsErr.RetryAfter)
+			logger.Warn("Too many requests to accrual service, pausing all workers",
+				zap.Duration("retry_after", tooManyRequestsErr.RetryAfter),
+				zap.Time("paused_until", pausedUntil),
+				zap.String("order", orderNumber))
+			uc.rescheduleAccrualJob(ctx, job, pausedUntil, domain.AccrualThrottled, err)
+			return
+		}
+
+		// Разомкнутый выключатель означает недоступность системы начислений:
+		// приостанавливаем опрос целиком, не расходуя попытки задания
+		var circuitOpenErr *domain.CircuitOpenError
+		if errors.As(err, &circuitOpenErr) {
+			pausedUntil := uc.pauseAccrualRequests(ctx, circuitOpenErr.RetryAfter)
+			logger.Warn("Accrual circuit breaker is open, pausing all workers",
+				zap.Time("paused_until", pausedUntil),
+				zap.String("order", orderNumber))
+			uc.rescheduleAccrualJob(ctx, job, pausedUntil, domain.AccrualThrottled, err)
+			return
+		}
+
+		class := domain.AccrualErrorUnavailable
+		if errors.Is(err, domain.ErrInvalidAccrualResponse) {
+			class = domain.AccrualErrorInvalidResponse
+		}
+
+		logger.Error("Failed to get order accrual",
+			zap.Error(err),
+			zap.String("order", orderNumber),
+			zap.String("error_class", string(class)))
+		uc.retryAccrualJob(ctx, job, class, err)
+		return
+	}
+
+	if order == nil {
+		logger.Info("Order not found in accrual system, continuing to retry",
+			zap.String("order", orderNumber))
+		uc.retryAccrualJob(ctx, job, domain.AccrualErrorNotRegistered, domain.ErrOrderNotRegistered)
+		return
+	}
+
+	logger.Info("Received accrual response",
+		zap.String("order", orderNumber),
+		zap.String("status", string(order.Status)),
+		zap.Stringer("accrual", order.Accrual))
+
+	change := domain.OrderStatusChange{
+		OrderNumber: orderNumber,
+		Status:      order.Status,
+		Accrual:     order.Accrual,
+		Source:      domain.StatusSourcePoll,
+		Attempt:     job.Attempts + 1,
+	}
+	if err := uc.applyAccrualResult(ctx, change); err != nil {
+		uc.retryAccrualJob(ctx, job, domain.AccrualErrorStorage, err)
+		return
+	}
+
+	// Если статус окончательный, обработка завершена
+	if isFinalOrderStatus(order.Status) {
+		return
+	}
+
+	uc.retryAccrualJob(ctx, job, domain.AccrualPending, nil)
+}
+
+// pauseAccrualRequests приостанавливает запросы всех обработчиков на d и возвращает момент
+// возобновления. Аренда заданий этой реплики продлевается на время паузы и еще на срок
+// аренды: иначе задания пачки, ждущие конца паузы, забрала бы и опросила другая реплика.
+func (uc *orderUseCase) pauseAccrualRequests(ctx context.Context, d time.Duration) time.Time {
+	pausedUntil := uc.throttle.Pause(d)
+
+	err := uc.storage.ExtendAccrualLeases(ctx, uc.instanceID, pausedUntil.Add(uc.leaseTTL))
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Failed to extend accrual job leases",
+			zap.Error(err),
+			zap.Time("paused_until", pausedUntil))
+	}
+	return pausedUntil
+}
+
+// ApplyAccrualCallback сохраняет результат расчёта, присланный системой начислений.
+// Уведомления и опрос сходятся в одном пути обновления, поэтому повторное или запоздавшее
+// уведомление не приводит к повторному начислению.
+func (uc *orderUseCase) ApplyAccrualCallback(ctx context.Context, order *domain.Order) error {
+	logger.Info("Received accrual callback",
+		zap.String("order", order.Number),
+		zap.String("status", string(order.Status)),
+		zap.Stringer("accrual", order.Accrual))
+
+	return uc.applyAccrualResult(ctx, domain.OrderStatusChange{
+		OrderNumber: order.Number,
+		Status:      order.Status,
+		Accrual:     order.Accrual,
+		Source:      domain.StatusSourceCallback,
+	})
+}
+
+// applyAccrualResult атомарно обновляет статус заказа и баланс владельца.
+// Для окончательного статуса задание на опрос удаляется.
+func (uc *orderUseCase) applyAccrualResult(ctx context.Context, change domain.OrderStatusChange) error {
+	// Получаем существующий заказ для определения userID
+	existingOrder, err := uc.storage.GetOrderByNumber(ctx, change.OrderNumber)
+	if err != nil {
+		logger.Error("Failed to get existing order",
+			zap.Error(err),
+			zap.String("order", change.OrderNumber))
+		return err
+	}
+
+	if err := uc.storage.UpdateOrderStatusAndBalance(ctx, change, existingOrder.UserID); err != nil {
+		logger.Error("Failed to update order status and balance",
+			zap.Error(err),
+			zap.String("order", change.OrderNumber))
+		return err
+	}
+
+	logger.Info("Updated order status and balance in database",
+		zap.String("order", change.OrderNumber),
+		zap.String("status", string(change.Status)),
+		zap.Stringer("accrual", change.Accrual),
+		zap.String("source", string(change.Source)))
+
+	if isFinalOrderStatus(change.Status) {
+		logger.Info("Order processing completed",
+			zap.String("order", change.OrderNumber),
+			zap.String("status", string(change.Status)),
+			zap.Stringer("accrual", change.Accrual))
+	}
+
+	return nil
+}
+
+// isFinalOrderStatus сообщает, что статус заказа больше не изменится
+func isFinalOrderStatus(status domain.OrderStatus) bool {
+	return status == domain.StatusProcessed || status == domain.StatusInvalid
+}
+
+// retryAccrualJob планирует следующую попытку по политике класса или, если попытки
+// исчерпаны, переводит заказ в STUCK с сохранением последней ошибки
+func (uc *orderUseCase) retryAccrualJob(ctx context.Context, job domain.AccrualJob, class domain.AccrualErrorClass, cause error) {
+	attempt := 1
+	if job.ErrorClass == class {
+		attempt = job.ClassAttempts + 1
+	}
+
+	var age time.Duration
+	if !job.CreatedAt.IsZero() {
+		age = time.Since(job.CreatedAt)
+	}
+
+	policy := uc.retry.policyFor(class)
+	if policy.exhausted(attempt, age) {
+		lastError := string(class)
+		if cause != nil {
+			lastError = cause.Error()
+		}
+
+		logger.Warn("Accrual retries exhausted, marking order as stuck",
+			zap.String("order", job.OrderNumber),
+			zap.String("error_class", string(class)),
+			zap.Int("attempts", attempt),
+			zap.Duration("age", age),
+			zap.String("last_error", lastError))
+
+		err := uc.storage.MarkOrderStuck(ctx, job.OrderNumber, uc.instanceID, lastError)
+		switch {
+		case errors.Is(err, domain.ErrLeaseLost):
+			logger.Warn("Accrual job lease lost, order left to its new owner",
+				zap.String("order", job.OrderNumber))
+		case err != nil:
+			logger.Error("Failed to mark order as stuck",
+				zap.Error(err),
+				zap.String("order", job.OrderNumber))
+		}
+		return
+	}
+
+	// Пока расчёт не окончен, опрос следует расписанию по возрасту заказа,
+	// ошибки повторяются с экспоненциальной задержкой
+	now := time.Now()
+	nextAttemptAt := now.Add(policy.delay(attempt))
+	if class == domain.AccrualPending {
+		uploadedAt := job.UploadedAt
+		if uploadedAt.IsZero() {
+			uploadedAt = job.CreatedAt
+		}
+		nextAttemptAt = uc.schedule.Next(uploadedAt, now)
+	}
+
+	uc.rescheduleAccrualJob(ctx, job, nextAttemptAt, class, cause)
+}
+
+// rescheduleAccrualJob откладывает следующую попытку опроса до nextAttemptAt.
+// Если аренда истекла и задание забрала другая реплика, расписание остается за ней.
+func (uc *orderUseCase) rescheduleAccrualJob(ctx context.Context, job domain.AccrualJob, nextAttemptAt time.Time, class domain.AccrualErrorClass, cause error) {
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+
+	err := uc.storage.RescheduleAccrualJob(ctx, job.OrderNumber, uc.instanceID, nextAttemptAt, class, lastError)
+	switch {
+	case errors.Is(err, domain.ErrLeaseLost):
+		logger.Warn("Accrual job lease lost, schedule left to its new owner",
+			zap.String("order", job.OrderNumber))
+	case err != nil:
+		logger.Error("Failed to reschedule accrual job",
+			zap.Error(err),
+			zap.String("order", job.OrderNumber))
+	}
+}
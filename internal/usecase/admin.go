@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+type adminUseCase struct {
+	storage Storage
+}
+
+// NewAdminUseCase создает новый экземпляр AdminUseCase
+func NewAdminUseCase(storage Storage) *adminUseCase {
+	return &adminUseCase{
+		storage: storage,
+	}
+}
+
+// RequeueOrder возвращает заказ в очередь опроса системы начислений
+func (uc *adminUseCase) RequeueOrder(ctx context.Context, actor, orderNumber, reason string) error {
+	entry := domain.AdminAuditEntry{
+		Actor:  actor,
+		Action: domain.AdminActionRequeue,
+		Reason: strings.TrimSpace(reason),
+	}
+
+	if err := uc.storage.RequeueOrder(ctx, orderNumber, entry); err != nil {
+		logger.Error("Failed to requeue order",
+			zap.Error(err),
+			zap.String("number", orderNumber),
+			zap.String("actor", actor))
+		return err
+	}
+
+	logger.Info("Order requeued by admin",
+		zap.String("number", orderNumber),
+		zap.String("actor", actor),
+		zap.String("reason", entry.Reason))
+	return nil
+}
+
+// RequeueOrders возвращает в очередь все заказы со статусом filter.Status,
+// загруженные в заданном интервале. Возвращает число заказов.
+func (uc *adminUseCase) RequeueOrders(ctx context.Context, actor string, filter domain.RequeueFilter, reason string) (int, error) {
+	switch filter.Status {
+	case domain.StatusNew, domain.StatusProcessing, domain.StatusInvalid, domain.StatusStuck:
+	default:
+		return 0, domain.ErrInvalidOrderStatus
+	}
+
+	entry := domain.AdminAuditEntry{
+		Actor:   actor,
+		Action:  domain.AdminActionBulkRequeue,
+		Details: formatRequeueFilter(filter),
+		Reason:  strings.TrimSpace(reason),
+	}
+
+	count, err := uc.storage.RequeueOrders(ctx, filter, entry)
+	if err != nil {
+		logger.Error("Failed to requeue orders",
+			zap.Error(err),
+			zap.String("filter", entry.Details),
+			zap.String("actor", actor))
+		return 0, err
+	}
+
+	logger.Info("Orders requeued by admin",
+		zap.Int("count", count),
+		zap.String("filter", entry.Details),
+		zap.String("actor", actor),
+		zap.String("reason", entry.Reason))
+	return count, nil
+}
+
+// ForceOrderStatus вручную переводит заказ в окончательный статус.
+// Причина обязательна; для PROCESSED начисление зачисляется на баланс владельца.
+func (uc *adminUseCase) ForceOrderStatus(ctx context.Context, actor, orderNumber string, status domain.OrderStatus, accrual domain.Money, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return domain.ErrReasonRequired
+	}
+
+	switch status {
+	case domain.StatusProcessed:
+		if accrual < 0 {
+			return domain.ErrInvalidAmount
+		}
+	case domain.StatusInvalid:
+		if accrual != 0 {
+			return domain.ErrInvalidAmount
+		}
+	default:
+		return domain.ErrInvalidOrderStatus
+	}
+
+	change := domain.OrderStatusChange{
+		OrderNumber: orderNumber,
+		Status:      status,
+		Accrual:     accrual,
+		Source:      domain.StatusSourceAdmin,
+	}
+	entry := domain.AdminAuditEntry{
+		Actor:   actor,
+		Action:  domain.AdminActionForceStatus,
+		Details: fmt.Sprintf("status=%s accrual=%v", status, accrual),
+		Reason:  reason,
+	}
+
+	if err := uc.storage.ForceOrderStatus(ctx, change, entry); err != nil {
+		logger.Error("Failed to force order status",
+			zap.Error(err),
+			zap.String("number", orderNumber),
+			zap.String("actor", actor))
+		return err
+	}
+
+	logger.Info("Order status forced by admin",
+		zap.String("number", orderNumber),
+		zap.String("status", string(status)),
+		zap.Stringer("accrual", accrual),
+		zap.String("actor", actor),
+		zap.String("reason", reason))
+	return nil
+}
+
+// formatRequeueFilter описывает фильтр массовой операции для журнала
+func formatRequeueFilter(filter domain.RequeueFilter) string {
+	details := "status=" + string(filter.Status)
+	if !filter.UploadedFrom.IsZero() {
+		details += " from=" + filter.UploadedFrom.Format(time.RFC3339)
+	}
+	if !filter.UploadedTo.IsZero() {
+		details += " to=" + filter.UploadedTo.Format(time.RFC3339)
+	}
+	return details
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gophermart/internal/domain"
+	"gophermart/internal/usecase/mocks"
+)
+
+func TestAdminUseCase_ForceOrderStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        domain.OrderStatus
+		accrual       domain.Money
+		reason        string
+		expectedError error
+		expectStorage bool
+	}{
+		{
+			name:          "Начисление вручную",
+			status:        domain.StatusProcessed,
+			accrual:       domain.Rubles(500),
+			reason:        "confirmed by partner",
+			expectStorage: true,
+		},
+		{
+			name:          "Отказ вручную",
+			status:        domain.StatusInvalid,
+			reason:        "fraud",
+			expectStorage: true,
+		},
+		{
+			name:          "Без причины",
+			status:        domain.StatusInvalid,
+			reason:        "  ",
+			expectedError: domain.ErrReasonRequired,
+		},
+		{
+			name:          "Промежуточный статус",
+			status:        domain.StatusProcessing,
+			reason:        "retry",
+			expectedError: domain.ErrInvalidOrderStatus,
+		},
+		{
+			name:          "Начисление для INVALID",
+			status:        domain.StatusInvalid,
+			accrual:       domain.Rubles(10),
+			reason:        "fraud",
+			expectedError: domain.ErrInvalidAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			mockStorage := &mocks.MockStorage{
+				ForceOrderStatusFunc: func(ctx context.Context, change domain.OrderStatusChange, entry domain.AdminAuditEntry) error {
+					called = true
+					if change.Source != domain.StatusSourceAdmin || change.Status != tt.status || change.Accrual != tt.accrual {
+						t.Errorf("Unexpected status change %+v", change)
+					}
+					if entry.Actor != "support" || entry.Action != domain.AdminActionForceStatus || entry.Reason != tt.reason {
+						t.Errorf("Unexpected audit entry %+v", entry)
+					}
+					return nil
+				},
+			}
+
+			uc := NewAdminUseCase(mockStorage)
+			err := uc.ForceOrderStatus(context.Background(), "support", "12345678903", tt.status, tt.accrual, tt.reason)
+
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if called != tt.expectStorage {
+				t.Errorf("Expected storage call %v, got %v", tt.expectStorage, called)
+			}
+		})
+	}
+}
+
+func TestAdminUseCase_RequeueOrders(t *testing.T) {
+	mockStorage := &mocks.MockStorage{
+		RequeueOrdersFunc: func(ctx context.Context, filter domain.RequeueFilter, entry domain.AdminAuditEntry) (int, error) {
+			if entry.Action != domain.AdminActionBulkRequeue || entry.Details != "status=STUCK" {
+				t.Errorf("Unexpected audit entry %+v", entry)
+			}
+			return 2, nil
+		},
+	}
+	uc := NewAdminUseCase(mockStorage)
+
+	count, err := uc.RequeueOrders(context.Background(), "support", domain.RequeueFilter{Status: domain.StatusStuck}, "outage")
+	if err != nil || count != 2 {
+		t.Errorf("Expected 2 requeued orders, got %d, %v", count, err)
+	}
+
+	_, err = uc.RequeueOrders(context.Background(), "support", domain.RequeueFilter{Status: domain.StatusProcessed}, "")
+	if !errors.Is(err, domain.ErrInvalidOrderStatus) {
+		t.Errorf("Expected ErrInvalidOrderStatus for PROCESSED, got %v", err)
+	}
+}
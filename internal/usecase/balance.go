@@ -33,72 +33,86 @@ func (uc *balanceUseCase) GetBalance(ctx context.Context, userID int64) (*domain
 
 	logger.Info("Retrieved user balance",
 		zap.Int64("user_id", userID),
-		zap.Float64("current", balance.Current),
-		zap.Float64("withdrawn", balance.Withdrawn))
+		zap.Stringer("current", balance.Current),
+		zap.Stringer("withdrawn", balance.Withdrawn))
 	return balance, nil
 }
 
-// Withdraw списывает баллы с баланса пользователя
-func (uc *balanceUseCase) Withdraw(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest) error {
+// Withdraw списывает баллы с баланса пользователя. Повторный запрос с теми же параметрами
+// или с тем же ключом идемпотентности возвращает уже проведенное списание; повтор с ключом
+// запроса, получившего отказ, возвращает тот же отказ.
+func (uc *balanceUseCase) Withdraw(ctx context.Context, userID int64, withdrawal domain.WithdrawalRequest, idempotencyKey string) (*domain.Withdrawal, error) {
 	// Проверяем, что сумма положительная
 	if withdrawal.Sum <= 0 {
 		logger.Error("Invalid withdrawal amount",
-			zap.Float64("sum", withdrawal.Sum))
-		return domain.ErrInvalidAmount
+			zap.Stringer("sum", withdrawal.Sum))
+		return nil, domain.ErrInvalidAmount
 	}
 
 	// Проверяем, что номер заказа состоит только из цифр
 	if _, err := strconv.ParseInt(withdrawal.Order, 10, 64); err != nil {
 		logger.Error("Invalid order number format",
 			zap.String("number", withdrawal.Order))
-		return domain.ErrInvalidOrderNumber
+		return nil, domain.ErrInvalidOrderNumber
 	}
 
 	// Проверяем номер по алгоритму Луна
 	if !validateLuhn(withdrawal.Order) {
 		logger.Error("Order number failed Luhn validation",
 			zap.String("number", withdrawal.Order))
-		return domain.ErrInvalidOrderNumber
+		return nil, domain.ErrInvalidOrderNumber
 	}
 
-	// Получаем текущий баланс
-	balance, err := uc.storage.GetBalance(ctx, userID)
+	// Достаточность средств проверяется хранилищем в одной транзакции со списанием:
+	// предварительная проверка отклонила бы повтор уже проведенного списания
+	created, err := uc.storage.CreateWithdrawal(ctx, userID, withdrawal.Order, withdrawal.Sum, idempotencyKey)
 	if err != nil {
-		logger.Error("Failed to get balance for withdrawal",
-			zap.Error(err),
-			zap.Int64("user_id", userID))
-		return err
-	}
-
-	// Проверяем достаточность средств
-	if balance.Current < withdrawal.Sum {
-		logger.Warn("Insufficient funds for withdrawal",
-			zap.Int64("user_id", userID),
-			zap.Float64("balance", balance.Current),
-			zap.Float64("requested", withdrawal.Sum))
-		return domain.ErrInsufficientFunds
+		switch err {
+		case domain.ErrInsufficientFunds, domain.ErrWithdrawalConflict, domain.ErrIdempotencyKeyReused:
+			logger.Warn("Withdrawal rejected",
+				zap.Error(err),
+				zap.Int64("user_id", userID),
+				zap.String("order", withdrawal.Order),
+				zap.Stringer("sum", withdrawal.Sum))
+		default:
+			logger.Error("Failed to create withdrawal",
+				zap.Error(err),
+				zap.Int64("user_id", userID),
+				zap.String("order", withdrawal.Order),
+				zap.Stringer("sum", withdrawal.Sum))
+		}
+		return nil, err
 	}
 
-	// Создаем запись о списании
-	if err := uc.storage.CreateWithdrawal(ctx, userID, withdrawal.Order, withdrawal.Sum); err != nil {
-		logger.Error("Failed to create withdrawal",
-			zap.Error(err),
+	if created.Replayed {
+		logger.Info("Withdrawal request replayed",
 			zap.Int64("user_id", userID),
-			zap.String("order", withdrawal.Order),
-			zap.Float64("sum", withdrawal.Sum))
-		return err
+			zap.Int64("withdrawal_id", created.ID),
+			zap.String("order", withdrawal.Order))
+		return created, nil
 	}
 
 	logger.Info("Withdrawal created successfully",
 		zap.Int64("user_id", userID),
+		zap.Int64("withdrawal_id", created.ID),
 		zap.String("order", withdrawal.Order),
-		zap.Float64("sum", withdrawal.Sum))
-	return nil
+		zap.Stringer("sum", withdrawal.Sum))
+	return created, nil
 }
 
-// GetWithdrawals возвращает историю списаний пользователя
-func (uc *balanceUseCase) GetWithdrawals(ctx context.Context, userID int64) ([]domain.Withdrawal, error) {
-	withdrawals, err := uc.storage.GetUserWithdrawals(ctx, userID)
+// GetWithdrawals возвращает страницу списаний пользователя, отобранных filter, от новых к старым.
+// Пустой cursor - первая страница; limit ограничивается maxListLimit.
+func (uc *balanceUseCase) GetWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, cursor string, limit int) (*domain.WithdrawalPage, error) {
+	after, err := domain.DecodeWithdrawalCursor(cursor)
+	if err != nil {
+		logger.Warn("Invalid withdrawals cursor",
+			zap.Int64("user_id", userID),
+			zap.String("cursor", cursor))
+		return nil, err
+	}
+
+	limit, fetch := listFetchLimit(limit, after != nil)
+	withdrawals, err := uc.storage.GetUserWithdrawals(ctx, userID, filter, after, fetch)
 	if err != nil {
 		logger.Error("Failed to get withdrawals",
 			zap.Error(err),
@@ -106,14 +120,57 @@ func (uc *balanceUseCase) GetWithdrawals(ctx context.Context, userID int64) ([]d
 		return nil, err
 	}
 
-	if len(withdrawals) == 0 {
-		logger.Info("No withdrawals found for user",
-			zap.Int64("user_id", userID))
-		return nil, nil
+	page := &domain.WithdrawalPage{Withdrawals: withdrawals}
+	if fetch > 0 && len(withdrawals) > limit {
+		page.Withdrawals = withdrawals[:limit]
+		page.NextCursor = domain.EncodeWithdrawalCursor(page.Withdrawals[limit-1])
 	}
 
 	logger.Info("Retrieved user withdrawals",
 		zap.Int64("user_id", userID),
-		zap.Int("count", len(withdrawals)))
-	return withdrawals, nil
+		zap.Int("count", len(page.Withdrawals)),
+		zap.Bool("has_more", page.NextCursor != ""))
+	return page, nil
+}
+
+const (
+	// defaultTransactionsLimit - размер страницы выписки, если он не задан
+	defaultTransactionsLimit = 50
+	// maxTransactionsLimit - наибольший размер страницы выписки
+	maxTransactionsLimit = 100
+)
+
+// GetTransactions возвращает страницу выписки пользователя от новых операций к старым.
+// Пустой cursor - первая страница; limit ограничивается maxTransactionsLimit.
+func (uc *balanceUseCase) GetTransactions(ctx context.Context, userID int64, cursor string, limit int) (*domain.TransactionPage, error) {
+	before, err := domain.DecodeCursor(cursor)
+	if err != nil {
+		logger.Warn("Invalid transactions cursor",
+			zap.Int64("user_id", userID),
+			zap.String("cursor", cursor))
+		return nil, err
+	}
+
+	limit = pageLimit(limit, defaultTransactionsLimit, maxTransactionsLimit)
+
+	// Лишняя запись показывает, есть ли следующая страница
+	transactions, err := uc.storage.GetUserTransactions(ctx, userID, before, limit+1)
+	if err != nil {
+		logger.Error("Failed to get transactions",
+			zap.Error(err),
+			zap.Int64("user_id", userID))
+		return nil, err
+	}
+
+	page := &domain.TransactionPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = domain.EncodeCursor(page.Transactions[limit-1].ID)
+	}
+
+	logger.Info("Retrieved user transactions",
+		zap.Int64("user_id", userID),
+		zap.Int("count", len(page.Transactions)),
+		zap.Bool("has_more", page.NextCursor != ""))
+	return page, nil
 }
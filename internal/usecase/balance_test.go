@@ -23,14 +23,14 @@ func TestBalanceUseCase(t *testing.T) {
 			mockBehavior: func(s *mocks.MockStorage) {
 				s.GetBalanceFunc = func(ctx context.Context, userID int64) (*domain.Balance, error) {
 					return &domain.Balance{
-						Current:   1000,
-						Withdrawn: 500,
+						Current:   domain.Rubles(1000),
+						Withdrawn: domain.Rubles(500),
 					}, nil
 				}
 			},
 			wantBalance: &domain.Balance{
-				Current:   1000,
-				Withdrawn: 500,
+				Current:   domain.Rubles(1000),
+				Withdrawn: domain.Rubles(500),
 			},
 			wantErr: false,
 		},
@@ -71,39 +71,58 @@ func TestBalanceUseCase(t *testing.T) {
 
 func TestBalanceUseCase_Withdraw(t *testing.T) {
 	tests := []struct {
-		name          string
-		userID        int64
-		withdrawal    domain.WithdrawalRequest
-		mockBehavior  func(*mocks.MockStorage)
-		expectedError error
+		name           string
+		userID         int64
+		withdrawal     domain.WithdrawalRequest
+		idempotencyKey string
+		mockBehavior   func(*mocks.MockStorage)
+		expectedError  error
+		wantReplayed   bool
 	}{
 		{
 			name:   "Успешное списание",
 			userID: 1,
 			withdrawal: domain.WithdrawalRequest{
 				Order: "12345678903",
-				Sum:   100,
+				Sum:   domain.Rubles(100),
 			},
+			idempotencyKey: "key-1",
 			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetBalanceFunc = func(ctx context.Context, userID int64) (*domain.Balance, error) {
-					return &domain.Balance{Current: 200, Withdrawn: 0}, nil
+				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount domain.Money, key string) (*domain.Withdrawal, error) {
+					if key != "key-1" {
+						t.Errorf("expected idempotency key key-1, got %q", key)
+					}
+					return &domain.Withdrawal{ID: 7, OrderNumber: orderNumber, Sum: amount}, nil
 				}
-				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount float64) error {
-					return nil
+			},
+			expectedError: nil,
+		},
+		{
+			name:   "Повтор проведенного списания",
+			userID: 1,
+			withdrawal: domain.WithdrawalRequest{
+				Order: "12345678903",
+				Sum:   domain.Rubles(100),
+			},
+			idempotencyKey: "key-1",
+			mockBehavior: func(s *mocks.MockStorage) {
+				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount domain.Money, key string) (*domain.Withdrawal, error) {
+					return &domain.Withdrawal{ID: 7, OrderNumber: orderNumber, Sum: amount, Replayed: true}, nil
 				}
 			},
 			expectedError: nil,
+			wantReplayed:  true,
 		},
 		{
 			name:   "Недостаточно средств",
 			userID: 1,
 			withdrawal: domain.WithdrawalRequest{
 				Order: "12345678903",
-				Sum:   200,
+				Sum:   domain.Rubles(200),
 			},
 			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetBalanceFunc = func(ctx context.Context, userID int64) (*domain.Balance, error) {
-					return &domain.Balance{Current: 100, Withdrawn: 0}, nil
+				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount domain.Money, key string) (*domain.Withdrawal, error) {
+					return nil, domain.ErrInsufficientFunds
 				}
 			},
 			expectedError: domain.ErrInsufficientFunds,
@@ -113,7 +132,7 @@ func TestBalanceUseCase_Withdraw(t *testing.T) {
 			userID: 1,
 			withdrawal: domain.WithdrawalRequest{
 				Order: "12345678903",
-				Sum:   -100,
+				Sum:   domain.Rubles(-100),
 			},
 			mockBehavior:  func(s *mocks.MockStorage) {},
 			expectedError: domain.ErrInvalidAmount,
@@ -123,38 +142,35 @@ func TestBalanceUseCase_Withdraw(t *testing.T) {
 			userID: 1,
 			withdrawal: domain.WithdrawalRequest{
 				Order: "invalid",
-				Sum:   100,
+				Sum:   domain.Rubles(100),
 			},
 			mockBehavior:  func(s *mocks.MockStorage) {},
 			expectedError: domain.ErrInvalidOrderNumber,
 		},
 		{
-			name:   "Ошибка при получении баланса",
+			name:   "Списание по заказу с другой суммой",
 			userID: 1,
 			withdrawal: domain.WithdrawalRequest{
 				Order: "12345678903",
-				Sum:   100,
+				Sum:   domain.Rubles(150),
 			},
 			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetBalanceFunc = func(ctx context.Context, userID int64) (*domain.Balance, error) {
-					return nil, domain.ErrUserNotFound
+				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount domain.Money, key string) (*domain.Withdrawal, error) {
+					return nil, domain.ErrWithdrawalConflict
 				}
 			},
-			expectedError: domain.ErrUserNotFound,
+			expectedError: domain.ErrWithdrawalConflict,
 		},
 		{
 			name:   "Ошибка при создании списания",
 			userID: 1,
 			withdrawal: domain.WithdrawalRequest{
 				Order: "12345678903",
-				Sum:   100,
+				Sum:   domain.Rubles(100),
 			},
 			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetBalanceFunc = func(ctx context.Context, userID int64) (*domain.Balance, error) {
-					return &domain.Balance{Current: 200, Withdrawn: 0}, nil
-				}
-				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount float64) error {
-					return domain.ErrOrderExists
+				s.CreateWithdrawalFunc = func(ctx context.Context, userID int64, orderNumber string, amount domain.Money, key string) (*domain.Withdrawal, error) {
+					return nil, domain.ErrOrderExists
 				}
 			},
 			expectedError: domain.ErrOrderExists,
@@ -168,95 +184,229 @@ func TestBalanceUseCase_Withdraw(t *testing.T) {
 
 			uc := NewBalanceUseCase(mockStorage)
 
-			err := uc.Withdraw(context.Background(), tt.userID, tt.withdrawal)
+			withdrawal, err := uc.Withdraw(context.Background(), tt.userID, tt.withdrawal, tt.idempotencyKey)
 			if err != tt.expectedError {
 				t.Errorf("Withdraw() error = %v, wantErr %v", err, tt.expectedError)
 			}
+			if err != nil {
+				return
+			}
+			if withdrawal.OrderNumber != tt.withdrawal.Order || withdrawal.Sum != tt.withdrawal.Sum {
+				t.Errorf("Withdraw() = %+v, want order %s and sum %s", withdrawal, tt.withdrawal.Order, tt.withdrawal.Sum)
+			}
+			if withdrawal.Replayed != tt.wantReplayed {
+				t.Errorf("Withdraw() replayed = %v, want %v", withdrawal.Replayed, tt.wantReplayed)
+			}
 		})
 	}
 }
 
 func TestBalanceUseCase_GetWithdrawals(t *testing.T) {
-	now := time.Now()
+	processedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// stored возвращает списания с идентификаторами от n до 1
+	stored := func(n int) []domain.Withdrawal {
+		withdrawals := make([]domain.Withdrawal, 0, n)
+		for id := n; id > 0; id-- {
+			withdrawals = append(withdrawals, domain.Withdrawal{
+				ID:          int64(id),
+				OrderNumber: "12345678903",
+				Sum:         domain.Rubles(100),
+				ProcessedAt: processedAt.Add(time.Duration(id) * time.Minute),
+			})
+		}
+		return withdrawals
+	}
+
 	tests := []struct {
-		name          string
-		userID        int64
-		mockBehavior  func(*mocks.MockStorage)
-		wantWithdraws []domain.Withdrawal
-		wantErr       bool
+		name        string
+		cursor      string
+		limit       int
+		stored      int
+		storageErr  error
+		wantAfterID int64
+		wantLimit   int
+		wantCount   int
+		wantNext    string
+		expectedErr error
 	}{
 		{
-			name:   "Успешное получение списаний",
-			userID: 1,
-			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetUserWithdrawalsFunc = func(ctx context.Context, userID int64) ([]domain.Withdrawal, error) {
-					return []domain.Withdrawal{
-						{
-							OrderNumber: "12345678903",
-							Sum:         100,
-							ProcessedAt: now,
-						},
-					}, nil
-				}
-			},
-			wantWithdraws: []domain.Withdrawal{
-				{
-					OrderNumber: "12345678903",
-					Sum:         100,
-					ProcessedAt: now,
-				},
-			},
-			wantErr: false,
+			name:      "Первая страница с продолжением",
+			limit:     2,
+			stored:    5,
+			wantLimit: 3,
+			wantCount: 2,
+			wantNext:  domain.EncodeWithdrawalCursor(stored(5)[1]),
 		},
 		{
-			name:   "Нет списаний",
-			userID: 1,
-			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetUserWithdrawalsFunc = func(ctx context.Context, userID int64) ([]domain.Withdrawal, error) {
-					return nil, nil
-				}
-			},
-			wantWithdraws: nil,
-			wantErr:       false,
+			name:        "Последняя страница",
+			cursor:      domain.EncodeWithdrawalCursor(stored(3)[0]),
+			limit:       2,
+			stored:      2,
+			wantAfterID: 3,
+			wantLimit:   3,
+			wantCount:   2,
 		},
 		{
-			name:   "Ошибка при получении списаний",
-			userID: 1,
-			mockBehavior: func(s *mocks.MockStorage) {
-				s.GetUserWithdrawalsFunc = func(ctx context.Context, userID int64) ([]domain.Withdrawal, error) {
-					return nil, domain.ErrUserNotFound
-				}
-			},
-			wantWithdraws: nil,
-			wantErr:       true,
+			name: "Нет списаний",
+		},
+		{
+			name:      "Без limit и курсора список отдается целиком",
+			stored:    defaultListLimit + 50,
+			wantCount: defaultListLimit + 50,
+		},
+		{
+			name:        "Курсор без limit",
+			cursor:      domain.EncodeWithdrawalCursor(stored(defaultListLimit + 2)[0]),
+			stored:      defaultListLimit + 1,
+			wantAfterID: defaultListLimit + 2,
+			wantLimit:   defaultListLimit + 1,
+			wantCount:   defaultListLimit,
+			wantNext:    domain.EncodeWithdrawalCursor(stored(defaultListLimit + 1)[defaultListLimit-1]),
+		},
+		{
+			name:        "Ошибка при получении списаний",
+			storageErr:  domain.ErrUserNotFound,
+			expectedErr: domain.ErrUserNotFound,
+		},
+		{
+			name:        "Неверный курсор",
+			cursor:      domain.EncodeCursor(3),
+			expectedErr: domain.ErrInvalidCursor,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockStorage := &mocks.MockStorage{}
-			tt.mockBehavior(mockStorage)
+			mockStorage := &mocks.MockStorage{
+				GetUserWithdrawalsFunc: func(ctx context.Context, userID int64, filter domain.WithdrawalFilter, after *domain.WithdrawalCursor, limit int) ([]domain.Withdrawal, error) {
+					var afterID int64
+					if after != nil {
+						afterID = after.ID
+					}
+					if afterID != tt.wantAfterID || limit != tt.wantLimit {
+						t.Errorf("GetUserWithdrawals(after=%d, limit=%d), want after=%d, limit=%d",
+							afterID, limit, tt.wantAfterID, tt.wantLimit)
+					}
+					if tt.storageErr != nil {
+						return nil, tt.storageErr
+					}
+					withdrawals := stored(tt.stored)
+					if limit > 0 && len(withdrawals) > limit {
+						withdrawals = withdrawals[:limit]
+					}
+					return withdrawals, nil
+				},
+			}
 
 			uc := NewBalanceUseCase(mockStorage)
 
-			withdrawals, err := uc.GetWithdrawals(context.Background(), tt.userID)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetWithdrawals() error = %v, wantErr %v", err, tt.wantErr)
+			page, err := uc.GetWithdrawals(context.Background(), 1, domain.WithdrawalFilter{}, tt.cursor, tt.limit)
+			if err != tt.expectedErr {
+				t.Fatalf("GetWithdrawals() error = %v, want %v", err, tt.expectedErr)
+			}
+			if err != nil {
 				return
 			}
-			if !tt.wantErr {
-				if len(withdrawals) != len(tt.wantWithdraws) {
-					t.Errorf("GetWithdrawals() got %v withdrawals, want %v", len(withdrawals), len(tt.wantWithdraws))
-					return
-				}
-				if len(withdrawals) > 0 {
-					if withdrawals[0].OrderNumber != tt.wantWithdraws[0].OrderNumber {
-						t.Errorf("GetWithdrawals() order = %v, want %v", withdrawals[0].OrderNumber, tt.wantWithdraws[0].OrderNumber)
+			if len(page.Withdrawals) != tt.wantCount {
+				t.Errorf("GetWithdrawals() got %d withdrawals, want %d", len(page.Withdrawals), tt.wantCount)
+			}
+			if page.NextCursor != tt.wantNext {
+				t.Errorf("GetWithdrawals() next cursor = %q, want %q", page.NextCursor, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestBalanceUseCase_GetTransactions(t *testing.T) {
+	// ledger возвращает операции с идентификаторами от n до 1
+	ledger := func(n int) []domain.Transaction {
+		transactions := make([]domain.Transaction, 0, n)
+		for id := n; id > 0; id-- {
+			transactions = append(transactions, domain.Transaction{
+				ID:     int64(id),
+				Kind:   domain.LedgerAccrual,
+				Amount: domain.Rubles(10),
+			})
+		}
+		return transactions
+	}
+
+	tests := []struct {
+		name        string
+		cursor      string
+		limit       int
+		stored      int
+		wantBefore  int64
+		wantLimit   int
+		wantCount   int
+		wantNext    string
+		expectedErr error
+	}{
+		{
+			name:      "Первая страница с продолжением",
+			limit:     2,
+			stored:    5,
+			wantLimit: 3,
+			wantCount: 2,
+			wantNext:  domain.EncodeCursor(4),
+		},
+		{
+			name:       "Последняя страница",
+			cursor:     domain.EncodeCursor(3),
+			limit:      2,
+			stored:     2,
+			wantBefore: 3,
+			wantLimit:  3,
+			wantCount:  2,
+		},
+		{
+			name:      "Размер страницы по умолчанию",
+			stored:    1,
+			wantLimit: defaultTransactionsLimit + 1,
+			wantCount: 1,
+		},
+		{
+			name:      "Размер страницы ограничен сверху",
+			limit:     1000,
+			wantLimit: maxTransactionsLimit + 1,
+		},
+		{
+			name:        "Неверный курсор",
+			cursor:      "not a cursor",
+			expectedErr: domain.ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mocks.MockStorage{
+				GetUserTransactionsFunc: func(ctx context.Context, userID int64, before int64, limit int) ([]domain.Transaction, error) {
+					if before != tt.wantBefore || limit != tt.wantLimit {
+						t.Errorf("GetUserTransactions(before=%d, limit=%d), want before=%d, limit=%d",
+							before, limit, tt.wantBefore, tt.wantLimit)
 					}
-					if withdrawals[0].Sum != tt.wantWithdraws[0].Sum {
-						t.Errorf("GetWithdrawals() sum = %v, want %v", withdrawals[0].Sum, tt.wantWithdraws[0].Sum)
+					transactions := ledger(tt.stored)
+					if len(transactions) > limit {
+						transactions = transactions[:limit]
 					}
-				}
+					return transactions, nil
+				},
+			}
+
+			uc := NewBalanceUseCase(mockStorage)
+
+			page, err := uc.GetTransactions(context.Background(), 1, tt.cursor, tt.limit)
+			if err != tt.expectedErr {
+				t.Fatalf("GetTransactions() error = %v, want %v", err, tt.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(page.Transactions) != tt.wantCount {
+				t.Errorf("GetTransactions() got %d transactions, want %d", len(page.Transactions), tt.wantCount)
+			}
+			if page.NextCursor != tt.wantNext {
+				t.Errorf("GetTransactions() next cursor = %q, want %q", page.NextCursor, tt.wantNext)
 			}
 		})
 	}
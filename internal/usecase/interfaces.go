@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"gophermart/internal/domain"
 )
@@ -13,15 +14,38 @@ type Storage interface {
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
 
 	// Заказы
-	CreateOrder(ctx context.Context, userID int64, number string) error
-	GetUserOrders(ctx context.Context, userID int64) ([]domain.Order, error)
+	CreateOrder(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error
+	GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, after *domain.OrderCursor, limit int) ([]domain.Order, error)
 	GetOrderByNumber(ctx context.Context, number string) (*domain.Order, error)
-	UpdateOrderStatusAndBalance(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error
+	UpdateOrderStatusAndBalance(ctx context.Context, change domain.OrderStatusChange, userID int64) error
+	GetOrderStatusHistory(ctx context.Context, number string) ([]domain.OrderStatusTransition, error)
+
+	// Очередь опроса системы начислений
+	ClaimAccrualJobs(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error)
+	RescheduleAccrualJob(ctx context.Context, orderNumber, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error
+	MarkOrderStuck(ctx context.Context, orderNumber, owner string, lastError string) error
+	ExtendAccrualLeases(ctx context.Context, owner string, lockedUntil time.Time) error
+
+	// Сверка начислений
+	// TryLockReconciliation не дает нескольким репликам сверять одновременно: если блокировку
+	// держит другая реплика, возвращается domain.ErrReconciliationLocked
+	TryLockReconciliation(ctx context.Context) (unlock func(), err error)
+	GetProcessedOrdersSince(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error)
+	ApplyAccrualAdjustment(ctx context.Context, orderNumber string, previousAccrual, newAccrual domain.Money) (*domain.AccrualAdjustment, error)
+	// Отчет сверки хранится общим для всех реплик: проход выполняет одна из них
+	SaveReconciliationReport(ctx context.Context, report *domain.ReconciliationReport) error
+	GetLastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error)
+
+	// Административные операции
+	RequeueOrder(ctx context.Context, number string, entry domain.AdminAuditEntry) error
+	RequeueOrders(ctx context.Context, filter domain.RequeueFilter, entry domain.AdminAuditEntry) (int, error)
+	ForceOrderStatus(ctx context.Context, change domain.OrderStatusChange, entry domain.AdminAuditEntry) error
 
 	// Баланс и списания
 	GetBalance(ctx context.Context, userID int64) (*domain.Balance, error)
-	CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum float64) error
-	GetUserWithdrawals(ctx context.Context, userID int64) ([]domain.Withdrawal, error)
+	CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum domain.Money, idempotencyKey string) (*domain.Withdrawal, error)
+	GetUserWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, after *domain.WithdrawalCursor, limit int) ([]domain.Withdrawal, error)
+	GetUserTransactions(ctx context.Context, userID int64, before int64, limit int) ([]domain.Transaction, error)
 
 	// Служебные методы
 	Ping(ctx context.Context) error
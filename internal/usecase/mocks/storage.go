@@ -2,6 +2,8 @@ package mocks
 
 import (
 	"context"
+	"time"
+
 	"gophermart/internal/domain"
 )
 
@@ -12,15 +14,35 @@ type MockStorage struct {
 	GetUserByLoginFunc func(ctx context.Context, login string) (*domain.User, error)
 
 	// Заказы
-	CreateOrderFunc                 func(ctx context.Context, userID int64, number string) error
-	GetUserOrdersFunc               func(ctx context.Context, userID int64) ([]domain.Order, error)
+	CreateOrderFunc                 func(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error
+	GetUserOrdersFunc               func(ctx context.Context, userID int64, filter domain.OrderFilter, after *domain.OrderCursor, limit int) ([]domain.Order, error)
 	GetOrderByNumberFunc            func(ctx context.Context, number string) (*domain.Order, error)
-	UpdateOrderStatusAndBalanceFunc func(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error
+	UpdateOrderStatusAndBalanceFunc func(ctx context.Context, change domain.OrderStatusChange, userID int64) error
+	GetOrderStatusHistoryFunc       func(ctx context.Context, number string) ([]domain.OrderStatusTransition, error)
+
+	// Очередь опроса системы начислений
+	ClaimAccrualJobsFunc     func(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error)
+	RescheduleAccrualJobFunc func(ctx context.Context, orderNumber, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error
+	ExtendAccrualLeasesFunc  func(ctx context.Context, owner string, lockedUntil time.Time) error
+	MarkOrderStuckFunc       func(ctx context.Context, orderNumber, owner string, lastError string) error
+
+	// Сверка начислений
+	TryLockReconciliationFunc       func(ctx context.Context) (func(), error)
+	GetProcessedOrdersSinceFunc     func(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error)
+	ApplyAccrualAdjustmentFunc      func(ctx context.Context, orderNumber string, previousAccrual, newAccrual domain.Money) (*domain.AccrualAdjustment, error)
+	SaveReconciliationReportFunc    func(ctx context.Context, report *domain.ReconciliationReport) error
+	GetLastReconciliationReportFunc func(ctx context.Context) (*domain.ReconciliationReport, error)
+
+	// Административные операции
+	RequeueOrderFunc     func(ctx context.Context, number string, entry domain.AdminAuditEntry) error
+	RequeueOrdersFunc    func(ctx context.Context, filter domain.RequeueFilter, entry domain.AdminAuditEntry) (int, error)
+	ForceOrderStatusFunc func(ctx context.Context, change domain.OrderStatusChange, entry domain.AdminAuditEntry) error
 
 	// Баланс и списания
-	GetBalanceFunc         func(ctx context.Context, userID int64) (*domain.Balance, error)
-	CreateWithdrawalFunc   func(ctx context.Context, userID int64, orderNumber string, sum float64) error
-	GetUserWithdrawalsFunc func(ctx context.Context, userID int64) ([]domain.Withdrawal, error)
+	GetBalanceFunc          func(ctx context.Context, userID int64) (*domain.Balance, error)
+	CreateWithdrawalFunc    func(ctx context.Context, userID int64, orderNumber string, sum domain.Money, idempotencyKey string) (*domain.Withdrawal, error)
+	GetUserWithdrawalsFunc  func(ctx context.Context, userID int64, filter domain.WithdrawalFilter, after *domain.WithdrawalCursor, limit int) ([]domain.Withdrawal, error)
+	GetUserTransactionsFunc func(ctx context.Context, userID int64, before int64, limit int) ([]domain.Transaction, error)
 
 	// Служебные методы
 	PingFunc  func(ctx context.Context) error
@@ -43,16 +65,16 @@ func (m *MockStorage) GetUserByLogin(ctx context.Context, login string) (*domain
 }
 
 // Заказы
-func (m *MockStorage) CreateOrder(ctx context.Context, userID int64, number string) error {
+func (m *MockStorage) CreateOrder(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
 	if m.CreateOrderFunc != nil {
-		return m.CreateOrderFunc(ctx, userID, number)
+		return m.CreateOrderFunc(ctx, userID, number, firstAttemptAt)
 	}
 	return nil
 }
 
-func (m *MockStorage) GetUserOrders(ctx context.Context, userID int64) ([]domain.Order, error) {
+func (m *MockStorage) GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, after *domain.OrderCursor, limit int) ([]domain.Order, error) {
 	if m.GetUserOrdersFunc != nil {
-		return m.GetUserOrdersFunc(ctx, userID)
+		return m.GetUserOrdersFunc(ctx, userID, filter, after, limit)
 	}
 	return nil, nil
 }
@@ -64,9 +86,103 @@ func (m *MockStorage) GetOrderByNumber(ctx context.Context, number string) (*dom
 	return nil, nil
 }
 
-func (m *MockStorage) UpdateOrderStatusAndBalance(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error {
+func (m *MockStorage) UpdateOrderStatusAndBalance(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
 	if m.UpdateOrderStatusAndBalanceFunc != nil {
-		return m.UpdateOrderStatusAndBalanceFunc(ctx, number, status, accrual, userID)
+		return m.UpdateOrderStatusAndBalanceFunc(ctx, change, userID)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetOrderStatusHistory(ctx context.Context, number string) ([]domain.OrderStatusTransition, error) {
+	if m.GetOrderStatusHistoryFunc != nil {
+		return m.GetOrderStatusHistoryFunc(ctx, number)
+	}
+	return nil, nil
+}
+
+// Очередь опроса системы начислений
+func (m *MockStorage) ClaimAccrualJobs(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error) {
+	if m.ClaimAccrualJobsFunc != nil {
+		return m.ClaimAccrualJobsFunc(ctx, owner, limit, leaseTTL)
+	}
+	return nil, nil
+}
+
+func (m *MockStorage) RescheduleAccrualJob(ctx context.Context, orderNumber, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+	if m.RescheduleAccrualJobFunc != nil {
+		return m.RescheduleAccrualJobFunc(ctx, orderNumber, owner, nextAttemptAt, errorClass, lastError)
+	}
+	return nil
+}
+
+func (m *MockStorage) MarkOrderStuck(ctx context.Context, orderNumber, owner string, lastError string) error {
+	if m.MarkOrderStuckFunc != nil {
+		return m.MarkOrderStuckFunc(ctx, orderNumber, owner, lastError)
+	}
+	return nil
+}
+
+func (m *MockStorage) ExtendAccrualLeases(ctx context.Context, owner string, lockedUntil time.Time) error {
+	if m.ExtendAccrualLeasesFunc != nil {
+		return m.ExtendAccrualLeasesFunc(ctx, owner, lockedUntil)
+	}
+	return nil
+}
+
+// Сверка начислений
+func (m *MockStorage) TryLockReconciliation(ctx context.Context) (func(), error) {
+	if m.TryLockReconciliationFunc != nil {
+		return m.TryLockReconciliationFunc(ctx)
+	}
+	return func() {}, nil
+}
+
+func (m *MockStorage) GetProcessedOrdersSince(ctx context.Context, since time.Time, after *domain.ProcessedOrderCursor, limit int) ([]domain.Order, error) {
+	if m.GetProcessedOrdersSinceFunc != nil {
+		return m.GetProcessedOrdersSinceFunc(ctx, since, after, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockStorage) ApplyAccrualAdjustment(ctx context.Context, orderNumber string, previousAccrual, newAccrual domain.Money) (*domain.AccrualAdjustment, error) {
+	if m.ApplyAccrualAdjustmentFunc != nil {
+		return m.ApplyAccrualAdjustmentFunc(ctx, orderNumber, previousAccrual, newAccrual)
+	}
+	return nil, nil
+}
+
+func (m *MockStorage) SaveReconciliationReport(ctx context.Context, report *domain.ReconciliationReport) error {
+	if m.SaveReconciliationReportFunc != nil {
+		return m.SaveReconciliationReportFunc(ctx, report)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetLastReconciliationReport(ctx context.Context) (*domain.ReconciliationReport, error) {
+	if m.GetLastReconciliationReportFunc != nil {
+		return m.GetLastReconciliationReportFunc(ctx)
+	}
+	return nil, domain.ErrReconciliationReportNotFound
+}
+
+// Административные операции
+func (m *MockStorage) RequeueOrder(ctx context.Context, number string, entry domain.AdminAuditEntry) error {
+	if m.RequeueOrderFunc != nil {
+		return m.RequeueOrderFunc(ctx, number, entry)
+	}
+	return nil
+}
+
+func (m *MockStorage) RequeueOrders(ctx context.Context, filter domain.RequeueFilter, entry domain.AdminAuditEntry) (int, error) {
+	if m.RequeueOrdersFunc != nil {
+		return m.RequeueOrdersFunc(ctx, filter, entry)
+	}
+	return 0, nil
+}
+
+func (m *MockStorage) ForceOrderStatus(ctx context.Context, change domain.OrderStatusChange, entry domain.AdminAuditEntry) error {
+	if m.ForceOrderStatusFunc != nil {
+		return m.ForceOrderStatusFunc(ctx, change, entry)
 	}
 	return nil
 }
@@ -79,16 +195,23 @@ func (m *MockStorage) GetBalance(ctx context.Context, userID int64) (*domain.Bal
 	return nil, nil
 }
 
-func (m *MockStorage) CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum float64) error {
+func (m *MockStorage) CreateWithdrawal(ctx context.Context, userID int64, orderNumber string, sum domain.Money, idempotencyKey string) (*domain.Withdrawal, error) {
 	if m.CreateWithdrawalFunc != nil {
-		return m.CreateWithdrawalFunc(ctx, userID, orderNumber, sum)
+		return m.CreateWithdrawalFunc(ctx, userID, orderNumber, sum, idempotencyKey)
 	}
-	return nil
+	return &domain.Withdrawal{OrderNumber: orderNumber, Sum: sum}, nil
 }
 
-func (m *MockStorage) GetUserWithdrawals(ctx context.Context, userID int64) ([]domain.Withdrawal, error) {
+func (m *MockStorage) GetUserWithdrawals(ctx context.Context, userID int64, filter domain.WithdrawalFilter, after *domain.WithdrawalCursor, limit int) ([]domain.Withdrawal, error) {
 	if m.GetUserWithdrawalsFunc != nil {
-		return m.GetUserWithdrawalsFunc(ctx, userID)
+		return m.GetUserWithdrawalsFunc(ctx, userID, filter, after, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockStorage) GetUserTransactions(ctx context.Context, userID int64, before int64, limit int) ([]domain.Transaction, error) {
+	if m.GetUserTransactionsFunc != nil {
+		return m.GetUserTransactionsFunc(ctx, userID, before, limit)
 	}
 	return nil, nil
 }
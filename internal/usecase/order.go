@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"sync"
 	"time"
 
 	"gophermart/internal/domain"
@@ -13,20 +14,32 @@ import (
 )
 
 type orderUseCase struct {
-	storage Storage
-	accrual AccrualService
-	ctx     context.Context
-	cancel  context.CancelFunc
+	storage    Storage
+	accrual    AccrualService
+	throttle   *accrualThrottle
+	instanceID string
+	leaseTTL   time.Duration
+	workers    int
+	retry      AccrualRetryPolicies
+	schedule   PollSchedule
+	// callbackTimeout - время ожидания уведомления о расчёте до первого опроса
+	callbackTimeout time.Duration
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
 }
 
 // NewOrderUseCase создает новый экземпляр OrderUseCase
 func NewOrderUseCase(storage Storage, accrual AccrualService) *orderUseCase {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &orderUseCase{
-		storage: storage,
-		accrual: accrual,
-		ctx:     ctx,
-		cancel:  cancel,
+		storage:  storage,
+		accrual:  accrual,
+		throttle: newAccrualThrottle(0),
+		retry:    DefaultAccrualRetryPolicies(),
+		schedule: DefaultPollSchedule(),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
@@ -34,9 +47,15 @@ func NewOrderUseCase(storage Storage, accrual AccrualService) *orderUseCase {
 func (uc *orderUseCase) Shutdown(ctx context.Context) {
 	uc.cancel()
 
-	// Ждем завершения контекста или таймаута
+	done := make(chan struct{})
+	go func() {
+		uc.wg.Wait()
+		close(done)
+	}()
+
+	// Ждем завершения фоновых процессов или таймаута
 	select {
-	case <-uc.ctx.Done():
+	case <-done:
 		logger.Info("Order processing gracefully stopped")
 	case <-ctx.Done():
 		logger.Warn("Order processing shutdown timeout")
@@ -66,104 +85,6 @@ func validateLuhn(number string) bool {
 	return sum%10 == 0
 }
 
-// processOrderAccrual обрабатывает начисление баллов за заказ
-func (uc *orderUseCase) processOrderAccrual(orderNumber string) {
-	logger.Info("Starting accrual processing",
-		zap.String("order", orderNumber))
-
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	retryAfter := time.Duration(0)
-
-	for {
-		select {
-		case <-uc.ctx.Done():
-			logger.Info("Context cancelled, stopping accrual processing",
-				zap.String("order", orderNumber))
-			return
-		case <-ticker.C:
-			// Если есть задержка для повторного запроса, проверяем её
-			if retryAfter > 0 {
-				logger.Info("Waiting for retry delay to expire",
-					zap.Duration("retry_after", retryAfter),
-					zap.String("order", orderNumber))
-				if time.Now().Before(time.Now().Add(-retryAfter)) {
-					continue
-				}
-				retryAfter = 0
-			}
-
-			// Получаем информацию о начислении
-			logger.Info("Requesting accrual info",
-				zap.String("order", orderNumber))
-			order, err := uc.accrual.GetOrderAccrual(uc.ctx, orderNumber)
-			if err != nil {
-				if errors.Is(err, context.Canceled) {
-					return
-				}
-
-				// Проверяем, является ли ошибка TooManyRequests
-				var tooManyRequestsErr *domain.TooManyRequestsError
-				if errors.As(err, &tooManyRequestsErr) {
-					retryAfter = tooManyRequestsErr.RetryAfter
-					logger.Warn("Too many requests to accrual service",
-						zap.Duration("retry_after", retryAfter),
-						zap.String("order", orderNumber))
-					continue
-				}
-
-				logger.Error("Failed to get order accrual",
-					zap.Error(err),
-					zap.String("order", orderNumber))
-				continue
-			}
-
-			if order == nil {
-				logger.Info("Order not found in accrual system, continuing to retry",
-					zap.String("order", orderNumber))
-				continue
-			}
-
-			logger.Info("Received accrual response",
-				zap.String("order", orderNumber),
-				zap.String("status", string(order.Status)),
-				zap.Float64("accrual", order.Accrual))
-
-			// Получаем существующий заказ для определения userID
-			existingOrder, err := uc.storage.GetOrderByNumber(uc.ctx, orderNumber)
-			if err != nil {
-				logger.Error("Failed to get existing order",
-					zap.Error(err),
-					zap.String("order", orderNumber))
-				continue
-			}
-
-			// Атомарно обновляем статус заказа и баланс
-			if err := uc.storage.UpdateOrderStatusAndBalance(uc.ctx, orderNumber, order.Status, order.Accrual, existingOrder.UserID); err != nil {
-				logger.Error("Failed to update order status and balance",
-					zap.Error(err),
-					zap.String("order", orderNumber))
-				continue
-			}
-
-			logger.Info("Updated order status and balance in database",
-				zap.String("order", orderNumber),
-				zap.String("status", string(order.Status)),
-				zap.Float64("accrual", order.Accrual))
-
-			// Если статус окончательный, завершаем обработку
-			if order.Status == domain.StatusProcessed || order.Status == domain.StatusInvalid {
-				logger.Info("Order processing completed",
-					zap.String("order", orderNumber),
-					zap.String("status", string(order.Status)),
-					zap.Float64("accrual", order.Accrual))
-				return
-			}
-		}
-	}
-}
-
 // UploadOrder загружает новый номер заказа
 func (uc *orderUseCase) UploadOrder(ctx context.Context, userID int64, orderNumber string) error {
 	// Проверяем, что номер заказа состоит только из цифр
@@ -180,26 +101,29 @@ func (uc *orderUseCase) UploadOrder(ctx context.Context, userID int64, orderNumb
 
 	// Проверяем существование заказа
 	existingOrder, err := uc.storage.GetOrderByNumber(ctx, orderNumber)
-	if err != nil {
-		if errors.Is(err, domain.ErrOrderNotFound) {
-			// Если заказ не найден, создаем новый
-			if err := uc.storage.CreateOrder(ctx, userID, orderNumber); err != nil {
-				logger.Error("Failed to create order",
-					zap.Error(err),
-					zap.String("number", orderNumber),
-					zap.Int64("user_id", userID))
-				return err
-			}
-
-			// Запускаем обработку начисления в фоновом режиме
-			go uc.processOrderAccrual(orderNumber)
-
+	if errors.Is(err, domain.ErrOrderNotFound) {
+		// Если заказ не найден, создаем новый вместе с заданием на опрос начислений.
+		// Пока ожидается уведомление от системы начислений, опрос откладывается.
+		firstAttemptAt := time.Now().Add(uc.callbackTimeout)
+		err = uc.storage.CreateOrder(ctx, userID, orderNumber, firstAttemptAt)
+		if err == nil {
 			logger.Info("Order uploaded successfully",
 				zap.String("number", orderNumber),
 				zap.Int64("user_id", userID))
 			return nil
 		}
-		// Если это любая другая ошибка
+		if !errors.Is(err, domain.ErrOrderExists) {
+			logger.Error("Failed to create order",
+				zap.Error(err),
+				zap.String("number", orderNumber),
+				zap.Int64("user_id", userID))
+			return err
+		}
+
+		// Заказ с тем же номером загружен параллельно: ответ зависит от его владельца
+		existingOrder, err = uc.storage.GetOrderByNumber(ctx, orderNumber)
+	}
+	if err != nil {
 		logger.Error("Failed to check order existence", zap.Error(err))
 		return err
 	}
@@ -220,9 +144,47 @@ func (uc *orderUseCase) UploadOrder(ctx context.Context, userID int64, orderNumb
 	return domain.ErrOrderBelongsToAnotherUser
 }
 
-// GetUserOrders возвращает список заказов пользователя
-func (uc *orderUseCase) GetUserOrders(ctx context.Context, userID int64) ([]domain.Order, error) {
-	orders, err := uc.storage.GetUserOrders(ctx, userID)
+// userOrderStatus возвращает статус заказа, который видит пользователь. STUCK - внутреннее
+// состояние для разбора администратором, пользователю такой заказ показывается как PROCESSING.
+func userOrderStatus(status domain.OrderStatus) domain.OrderStatus {
+	if status == domain.StatusStuck {
+		return domain.StatusProcessing
+	}
+	return status
+}
+
+// GetUserOrders возвращает страницу заказов пользователя, отобранных filter, от новых к старым.
+// Пустой cursor - первая страница; limit ограничивается maxListLimit.
+// Фильтр PROCESSING отбирает и заказы в STUCK, сам STUCK пользователю не доступен.
+func (uc *orderUseCase) GetUserOrders(ctx context.Context, userID int64, filter domain.OrderFilter, cursor string, limit int) (*domain.OrderPage, error) {
+	statuses := make([]domain.OrderStatus, 0, len(filter.Statuses)+1)
+	for _, status := range filter.Statuses {
+		switch status {
+		case domain.StatusNew, domain.StatusInvalid, domain.StatusProcessed:
+		case domain.StatusProcessing:
+			statuses = append(statuses, domain.StatusStuck)
+		default:
+			logger.Warn("Invalid orders status filter",
+				zap.Int64("user_id", userID),
+				zap.String("status", string(status)))
+			return nil, domain.ErrInvalidOrderStatus
+		}
+		statuses = append(statuses, status)
+	}
+	if len(filter.Statuses) > 0 {
+		filter.Statuses = statuses
+	}
+
+	after, err := domain.DecodeOrderCursor(cursor)
+	if err != nil {
+		logger.Warn("Invalid orders cursor",
+			zap.Int64("user_id", userID),
+			zap.String("cursor", cursor))
+		return nil, err
+	}
+
+	limit, fetch := listFetchLimit(limit, after != nil)
+	orders, err := uc.storage.GetUserOrders(ctx, userID, filter, after, fetch)
 	if err != nil {
 		logger.Error("Failed to get user orders",
 			zap.Error(err),
@@ -230,13 +192,72 @@ func (uc *orderUseCase) GetUserOrders(ctx context.Context, userID int64) ([]doma
 		return nil, err
 	}
 
-	if len(orders) == 0 {
-		logger.Info("No orders found for user", zap.Int64("user_id", userID))
-		return nil, nil
+	for i := range orders {
+		orders[i].Status = userOrderStatus(orders[i].Status)
+	}
+	page := &domain.OrderPage{Orders: orders}
+	if fetch > 0 && len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.NextCursor = domain.EncodeOrderCursor(page.Orders[limit-1])
 	}
 
 	logger.Info("Retrieved user orders",
 		zap.Int64("user_id", userID),
-		zap.Int("count", len(orders)))
-	return orders, nil
+		zap.Int("count", len(page.Orders)),
+		zap.Bool("has_more", page.NextCursor != ""))
+	return page, nil
+}
+
+// GetUserOrder возвращает заказ пользователя по номеру.
+// Для чужого заказа возвращается domain.ErrOrderNotFound, чтобы не раскрывать его существование.
+func (uc *orderUseCase) GetUserOrder(ctx context.Context, userID int64, orderNumber string) (*domain.Order, error) {
+	order, err := uc.storage.GetOrderByNumber(ctx, orderNumber)
+	if err != nil {
+		if !errors.Is(err, domain.ErrOrderNotFound) {
+			logger.Error("Failed to get order",
+				zap.Error(err),
+				zap.String("number", orderNumber))
+		}
+		return nil, err
+	}
+
+	if order.UserID != userID {
+		logger.Warn("Order requested by another user",
+			zap.String("number", orderNumber),
+			zap.Int64("user_id", userID))
+		return nil, domain.ErrOrderNotFound
+	}
+
+	order.Status = userOrderStatus(order.Status)
+	return order, nil
+}
+
+// GetOrderHistory возвращает историю статусов заказа пользователя.
+// Для чужого заказа, как и в GetUserOrder, возвращается domain.ErrOrderNotFound.
+func (uc *orderUseCase) GetOrderHistory(ctx context.Context, userID int64, orderNumber string) ([]domain.OrderStatusTransition, error) {
+	if _, err := uc.GetUserOrder(ctx, userID, orderNumber); err != nil {
+		return nil, err
+	}
+
+	history, err := uc.storage.GetOrderStatusHistory(ctx, orderNumber)
+	if err != nil {
+		logger.Error("Failed to get order status history",
+			zap.Error(err),
+			zap.String("number", orderNumber))
+		return nil, err
+	}
+
+	// Переход в STUCK пользователь не видит: для него заказ остается в PROCESSING
+	visible := make([]domain.OrderStatusTransition, 0, len(history))
+	for _, transition := range history {
+		stuck := transition.To == domain.StatusStuck
+		transition.From = userOrderStatus(transition.From)
+		transition.To = userOrderStatus(transition.To)
+		if stuck && transition.From == transition.To {
+			continue
+		}
+		visible = append(visible, transition)
+	}
+
+	return visible, nil
 }
@@ -2,7 +2,9 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -61,11 +63,11 @@ func TestOrderUseCase(t *testing.T) {
 						Status: domain.StatusNew,
 					}, nil
 				}
-				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string) error {
+				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
 					return nil
 				}
 				// Мокаем обновление статуса
-				s.UpdateOrderStatusAndBalanceFunc = func(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error {
+				s.UpdateOrderStatusAndBalanceFunc = func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
 					return nil
 				}
 				// Мокаем ответ от сервиса начислений, чтобы горутина сразу завершалась
@@ -73,7 +75,7 @@ func TestOrderUseCase(t *testing.T) {
 					return &domain.Order{
 						Number:  orderNumber,
 						Status:  domain.StatusProcessed,
-						Accrual: 500,
+						Accrual: domain.Rubles(500),
 					}, nil
 				}
 			},
@@ -115,18 +117,43 @@ func TestOrderUseCase(t *testing.T) {
 			expectedError: domain.ErrOrderBelongsToUser,
 		},
 		{
-			name:        "Ошибка при создании заказа",
+			name:        "Параллельная загрузка того же заказа текущим пользователем",
 			userID:      1,
 			orderNumber: "12345678903",
 			mockBehavior: func(s *mocks.MockStorage, a *mocks.MockAccrualService) {
+				created := false
 				s.GetOrderByNumberFunc = func(ctx context.Context, number string) (*domain.Order, error) {
-					return nil, domain.ErrOrderNotFound
+					if !created {
+						return nil, domain.ErrOrderNotFound
+					}
+					return &domain.Order{UserID: 1, Number: number}, nil
 				}
-				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string) error {
-					return domain.ErrOrderExists
+				// Другой запрос успел создать заказ между проверкой и вставкой
+				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
+					created = true
+					return fmt.Errorf("error creating order: %w", domain.ErrOrderExists)
 				}
 			},
-			expectedError: domain.ErrOrderExists,
+			expectedError: domain.ErrOrderBelongsToUser,
+		},
+		{
+			name:        "Параллельная загрузка того же заказа другим пользователем",
+			userID:      1,
+			orderNumber: "12345678903",
+			mockBehavior: func(s *mocks.MockStorage, a *mocks.MockAccrualService) {
+				created := false
+				s.GetOrderByNumberFunc = func(ctx context.Context, number string) (*domain.Order, error) {
+					if !created {
+						return nil, domain.ErrOrderNotFound
+					}
+					return &domain.Order{UserID: 2, Number: number}, nil
+				}
+				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
+					created = true
+					return fmt.Errorf("error creating order: %w", domain.ErrOrderExists)
+				}
+			},
+			expectedError: domain.ErrOrderBelongsToAnotherUser,
 		},
 		{
 			name:        "Ошибка при проверке существования заказа",
@@ -136,7 +163,7 @@ func TestOrderUseCase(t *testing.T) {
 				s.GetOrderByNumberFunc = func(ctx context.Context, number string) (*domain.Order, error) {
 					return nil, domain.ErrOrderNotFound
 				}
-				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string) error {
+				s.CreateOrderFunc = func(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
 					return domain.ErrOrderNotFound
 				}
 			},
@@ -170,91 +197,161 @@ func TestOrderUseCase(t *testing.T) {
 			}
 		})
 	}
+}
 
-	// Тесты для получения списка заказов
-	getTests := []struct {
-		name           string
-		userID         int64
-		mockBehavior   func(*mocks.MockStorage, *mocks.MockAccrualService)
-		expectedOrders []domain.Order
-		expectedError  error
+func TestOrderUseCase_GetUserOrders(t *testing.T) {
+	uploadedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// stored возвращает n заказов от новых к старым
+	stored := func(n int, status domain.OrderStatus) []domain.Order {
+		if status == "" {
+			status = domain.StatusProcessed
+		}
+		orders := make([]domain.Order, 0, n)
+		for i := n; i > 0; i-- {
+			orders = append(orders, domain.Order{
+				UserID:     1,
+				Number:     fmt.Sprintf("order-%d", i),
+				Status:     status,
+				UploadedAt: uploadedAt.Add(time.Duration(i) * time.Minute),
+			})
+		}
+		return orders
+	}
+	cursor := domain.EncodeOrderCursor(domain.Order{Number: "order-3", UploadedAt: uploadedAt.Add(3 * time.Minute)})
+
+	tests := []struct {
+		name         string
+		filter       domain.OrderFilter
+		cursor       string
+		limit        int
+		stored       int
+		storedStatus domain.OrderStatus
+		// wantStatuses - статусы фильтра, передаваемые хранилищу; nil - как в filter
+		wantStatuses []domain.OrderStatus
+		wantStatus   domain.OrderStatus
+		wantAfter    *domain.OrderCursor
+		wantLimit    int
+		wantCount    int
+		wantNext     string
+		expectedErr  error
 	}{
 		{
-			name:   "Успешное получение заказов",
-			userID: 1,
-			mockBehavior: func(s *mocks.MockStorage, a *mocks.MockAccrualService) {
-				s.GetUserOrdersFunc = func(ctx context.Context, userID int64) ([]domain.Order, error) {
-					return []domain.Order{
-						{
-							UserID:     1,
-							Number:     "12345678903",
-							Status:     domain.StatusProcessed,
-							Accrual:    500,
-							UploadedAt: time.Now(),
-						},
-					}, nil
-				}
-			},
-			expectedOrders: []domain.Order{
-				{
-					UserID:     1,
-					Number:     "12345678903",
-					Status:     domain.StatusProcessed,
-					Accrual:    500,
-					UploadedAt: time.Now(),
-				},
-			},
-			expectedError: nil,
+			name:      "Первая страница с продолжением",
+			limit:     2,
+			stored:    5,
+			wantLimit: 3,
+			wantCount: 2,
+			wantNext:  domain.EncodeOrderCursor(stored(5, "")[1]),
 		},
 		{
-			name:   "Нет заказов",
-			userID: 1,
-			mockBehavior: func(s *mocks.MockStorage, a *mocks.MockAccrualService) {
-				s.GetUserOrdersFunc = func(ctx context.Context, userID int64) ([]domain.Order, error) {
-					return nil, nil
-				}
-			},
-			expectedOrders: nil,
-			expectedError:  nil,
+			name:      "Последняя страница",
+			cursor:    cursor,
+			limit:     2,
+			stored:    2,
+			wantAfter: &domain.OrderCursor{Number: "order-3", UploadedAt: uploadedAt.Add(3 * time.Minute)},
+			wantLimit: 3,
+			wantCount: 2,
+		},
+		{
+			name: "Нет заказов",
+		},
+		{
+			name:      "Без limit и курсора список отдается целиком",
+			stored:    defaultListLimit + 50,
+			wantCount: defaultListLimit + 50,
+		},
+		{
+			name:      "Курсор без limit",
+			cursor:    cursor,
+			stored:    defaultListLimit + 1,
+			wantAfter: &domain.OrderCursor{Number: "order-3", UploadedAt: uploadedAt.Add(3 * time.Minute)},
+			wantLimit: defaultListLimit + 1,
+			wantCount: defaultListLimit,
+			wantNext:  domain.EncodeOrderCursor(stored(defaultListLimit+1, "")[defaultListLimit-1]),
+		},
+		{
+			name:      "Размер страницы ограничен сверху",
+			limit:     maxListLimit + 1,
+			stored:    1,
+			wantLimit: maxListLimit + 1,
+			wantCount: 1,
+		},
+		{
+			name:      "Фильтр по статусу",
+			filter:    domain.OrderFilter{Statuses: []domain.OrderStatus{domain.StatusProcessed, domain.StatusInvalid}},
+			stored:    1,
+			wantCount: 1,
+		},
+		{
+			name:         "Зависший заказ показывается как PROCESSING",
+			filter:       domain.OrderFilter{Statuses: []domain.OrderStatus{domain.StatusProcessing}},
+			stored:       1,
+			storedStatus: domain.StatusStuck,
+			wantStatuses: []domain.OrderStatus{domain.StatusStuck, domain.StatusProcessing},
+			wantStatus:   domain.StatusProcessing,
+			wantCount:    1,
+		},
+		{
+			name:        "Фильтр по STUCK недоступен пользователю",
+			filter:      domain.OrderFilter{Statuses: []domain.OrderStatus{domain.StatusStuck}},
+			expectedErr: domain.ErrInvalidOrderStatus,
+		},
+		{
+			name:        "Неизвестный статус",
+			filter:      domain.OrderFilter{Statuses: []domain.OrderStatus{"DONE"}},
+			expectedErr: domain.ErrInvalidOrderStatus,
+		},
+		{
+			name:        "Неверный курсор",
+			cursor:      "not a cursor",
+			expectedErr: domain.ErrInvalidCursor,
 		},
 	}
 
-	for _, tt := range getTests {
+	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Подготовка моков
-			mockStorage := &mocks.MockStorage{}
-			mockAccrual := &mocks.MockAccrualService{}
-			tt.mockBehavior(mockStorage, mockAccrual)
-
-			// Создание usecase
-			uc := NewOrderUseCase(mockStorage, mockAccrual)
-
-			// Выполнение операции
-			orders, err := uc.GetUserOrders(context.Background(), tt.userID)
-
-			// Проверка результатов
-			if err != tt.expectedError {
-				t.Errorf("Expected error %v, got %v", tt.expectedError, err)
-			}
-
-			if tt.expectedOrders != nil {
-				if len(orders) != len(tt.expectedOrders) {
-					t.Errorf("Expected %d orders, got %d", len(tt.expectedOrders), len(orders))
-				}
-
-				if len(orders) > 0 {
-					if orders[0].Number != tt.expectedOrders[0].Number {
-						t.Errorf("Expected order number %s, got %s",
-							tt.expectedOrders[0].Number, orders[0].Number)
+			mockStorage := &mocks.MockStorage{
+				GetUserOrdersFunc: func(ctx context.Context, userID int64, filter domain.OrderFilter, after *domain.OrderCursor, limit int) ([]domain.Order, error) {
+					if limit != tt.wantLimit {
+						t.Errorf("GetUserOrders(limit=%d), want limit=%d", limit, tt.wantLimit)
+					}
+					if (after == nil) != (tt.wantAfter == nil) ||
+						after != nil && (after.Number != tt.wantAfter.Number || !after.UploadedAt.Equal(tt.wantAfter.UploadedAt)) {
+						t.Errorf("GetUserOrders(after=%+v), want after=%+v", after, tt.wantAfter)
+					}
+					wantStatuses := tt.wantStatuses
+					if wantStatuses == nil {
+						wantStatuses = tt.filter.Statuses
 					}
-					if orders[0].Status != tt.expectedOrders[0].Status {
-						t.Errorf("Expected order status %s, got %s",
-							tt.expectedOrders[0].Status, orders[0].Status)
+					if fmt.Sprint(filter.Statuses) != fmt.Sprint(wantStatuses) {
+						t.Errorf("GetUserOrders(statuses=%v), want statuses=%v", filter.Statuses, wantStatuses)
 					}
-					if orders[0].Accrual != tt.expectedOrders[0].Accrual {
-						t.Errorf("Expected order accrual %f, got %f",
-							tt.expectedOrders[0].Accrual, orders[0].Accrual)
+					orders := stored(tt.stored, tt.storedStatus)
+					if limit > 0 && len(orders) > limit {
+						orders = orders[:limit]
 					}
+					return orders, nil
+				},
+			}
+
+			uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+
+			page, err := uc.GetUserOrders(context.Background(), 1, tt.filter, tt.cursor, tt.limit)
+			if err != tt.expectedErr {
+				t.Fatalf("GetUserOrders() error = %v, want %v", err, tt.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(page.Orders) != tt.wantCount {
+				t.Errorf("GetUserOrders() got %d orders, want %d", len(page.Orders), tt.wantCount)
+			}
+			if page.NextCursor != tt.wantNext {
+				t.Errorf("GetUserOrders() next cursor = %q, want %q", page.NextCursor, tt.wantNext)
+			}
+			for _, order := range page.Orders {
+				if tt.wantStatus != "" && order.Status != tt.wantStatus {
+					t.Errorf("GetUserOrders() order status = %s, want %s", order.Status, tt.wantStatus)
 				}
 			}
 		})
@@ -265,7 +362,8 @@ func TestOrderUseCase_ProcessOrderAccrual(t *testing.T) {
 	// Подготавливаем тестовые данные
 	orderNumber := "12345678903"
 	userID := int64(1)
-	accrual := float64(500)
+	accrual := domain.Rubles(500)
+	updated := false
 
 	// Создаем моки
 	mockStorage := &mocks.MockStorage{
@@ -276,22 +374,30 @@ func TestOrderUseCase_ProcessOrderAccrual(t *testing.T) {
 				Status: domain.StatusNew,
 			}, nil
 		},
-		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error {
+		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+			updated = true
 			// Проверяем, что параметры правильные
-			if number != orderNumber {
-				t.Errorf("Expected order number %s, got %s", orderNumber, number)
+			if change.OrderNumber != orderNumber {
+				t.Errorf("Expected order number %s, got %s", orderNumber, change.OrderNumber)
+			}
+			if change.Status != domain.StatusProcessed {
+				t.Errorf("Expected status %s, got %s", domain.StatusProcessed, change.Status)
 			}
-			if status != domain.StatusProcessed {
-				t.Errorf("Expected status %s, got %s", domain.StatusProcessed, status)
+			if change.Accrual != domain.Rubles(500) {
+				t.Errorf("Expected accrual %s, got %s", domain.Rubles(500), change.Accrual)
 			}
-			if accrual != 500 {
-				t.Errorf("Expected accrual %f, got %f", 500.0, accrual)
+			if change.Source != domain.StatusSourcePoll || change.Attempt != 1 {
+				t.Errorf("Expected first poll attempt, got %s attempt %d", change.Source, change.Attempt)
 			}
 			if userID != 1 {
 				t.Errorf("Expected user ID %d, got %d", 1, userID)
 			}
 			return nil
 		},
+		RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+			t.Errorf("Processed order must not be rescheduled")
+			return nil
+		},
 	}
 
 	mockAccrual := &mocks.MockAccrualService{
@@ -307,17 +413,19 @@ func TestOrderUseCase_ProcessOrderAccrual(t *testing.T) {
 	// Создаем usecase
 	uc := NewOrderUseCase(mockStorage, mockAccrual)
 
-	// Запускаем обработку заказа
-	uc.processOrderAccrual(orderNumber)
+	// Выполняем попытку обработки заказа
+	uc.processOrderAccrual(context.Background(), domain.AccrualJob{OrderNumber: orderNumber})
 
-	// Проверяем, что все методы были вызваны
-	// Добавьте здесь дополнительные проверки, если необходимо
+	if !updated {
+		t.Errorf("Expected order status to be updated")
+	}
 }
 
 func TestOrderUseCase_ProcessOrderAccrual_Error(t *testing.T) {
 	// Подготавливаем тестовые данные
 	orderNumber := "12345678903"
 	userID := int64(1)
+	var lastError string
 
 	// Создаем моки
 	mockStorage := &mocks.MockStorage{
@@ -328,9 +436,13 @@ func TestOrderUseCase_ProcessOrderAccrual_Error(t *testing.T) {
 				Status: domain.StatusNew,
 			}, nil
 		},
-		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error {
+		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
 			return fmt.Errorf("database error")
 		},
+		RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastErr string) error {
+			lastError = lastErr
+			return nil
+		},
 	}
 
 	mockAccrual := &mocks.MockAccrualService{
@@ -338,25 +450,92 @@ func TestOrderUseCase_ProcessOrderAccrual_Error(t *testing.T) {
 			return &domain.Order{
 				Number:  orderNumber,
 				Status:  domain.StatusProcessed,
-				Accrual: 500,
+				Accrual: domain.Rubles(500),
 			}, nil
 		},
 	}
 
-	// Создаем usecase с контекстом и таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
+	uc := NewOrderUseCase(mockStorage, mockAccrual)
+
+	// Ошибка хранилища не должна терять задание: оно откладывается с сохранением ошибки
+	uc.processOrderAccrual(context.Background(), domain.AccrualJob{OrderNumber: orderNumber})
+
+	if lastError != "database error" {
+		t.Errorf("Expected last error %q, got %q", "database error", lastError)
+	}
+}
+
+func TestOrderUseCase_ProcessOrderAccrual_TooManyRequests(t *testing.T) {
+	orderNumber := "12345678903"
+	var nextAttempt, leasedUntil time.Time
+
+	mockStorage := &mocks.MockStorage{
+		RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+			nextAttempt = nextAttemptAt
+			return nil
+		},
+		ExtendAccrualLeasesFunc: func(ctx context.Context, owner string, lockedUntil time.Time) error {
+			if owner != "replica-1" {
+				t.Errorf("Expected leases of %s to be extended, got %s", "replica-1", owner)
+			}
+			leasedUntil = lockedUntil
+			return nil
+		},
+	}
+
+	mockAccrual := &mocks.MockAccrualService{
+		GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+			return nil, domain.NewTooManyRequestsError(time.Minute)
+		},
+	}
 
 	uc := NewOrderUseCase(mockStorage, mockAccrual)
+	uc.instanceID = "replica-1"
+	uc.leaseTTL = 30 * time.Second
+
+	uc.processOrderAccrual(context.Background(), domain.AccrualJob{OrderNumber: orderNumber})
 
-	// Запускаем обработку заказа
-	go uc.processOrderAccrual(orderNumber)
+	if time.Until(nextAttempt) < 50*time.Second {
+		t.Errorf("Expected job to be postponed by Retry-After, got %v", time.Until(nextAttempt))
+	}
 
-	// Ждем завершения контекста
-	<-ctx.Done()
+	// Аренда остальных заданий пачки переживает паузу, которая длиннее срока аренды
+	if leasedUntil.Before(uc.throttle.PausedUntil().Add(uc.leaseTTL)) {
+		t.Errorf("Expected leases extended past pause, got %v", leasedUntil)
+	}
 
-	// Останавливаем обработку
-	uc.Shutdown(context.Background())
+	// Пауза распространяется на все обработчики
+	if time.Until(uc.throttle.PausedUntil()) < 50*time.Second {
+		t.Errorf("Expected all workers to be paused, paused until %v", uc.throttle.PausedUntil())
+	}
+}
+
+func TestOrderUseCase_ProcessOrderAccrual_CircuitOpen(t *testing.T) {
+	var class domain.AccrualErrorClass
+
+	mockStorage := &mocks.MockStorage{
+		RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+			class = errorClass
+			return nil
+		},
+	}
+
+	mockAccrual := &mocks.MockAccrualService{
+		GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+			return nil, domain.NewCircuitOpenError(time.Minute)
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, mockAccrual)
+	uc.processOrderAccrual(context.Background(), domain.AccrualJob{OrderNumber: "12345678903"})
+
+	// Опрос приостанавливается целиком, попытка не расходуется
+	if time.Until(uc.throttle.PausedUntil()) < 50*time.Second {
+		t.Errorf("Expected all workers to be paused, paused until %v", uc.throttle.PausedUntil())
+	}
+	if class != domain.AccrualThrottled {
+		t.Errorf("Expected error class %s, got %s", domain.AccrualThrottled, class)
+	}
 }
 
 func TestOrderUseCase_ProcessOrderAccrual_InvalidStatus(t *testing.T) {
@@ -373,9 +552,9 @@ func TestOrderUseCase_ProcessOrderAccrual_InvalidStatus(t *testing.T) {
 				Status: domain.StatusNew,
 			}, nil
 		},
-		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, number string, status domain.OrderStatus, accrual float64, userID int64) error {
-			if status != domain.StatusInvalid {
-				t.Errorf("Expected status %s, got %s", domain.StatusInvalid, status)
+		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+			if change.Status != domain.StatusInvalid {
+				t.Errorf("Expected status %s, got %s", domain.StatusInvalid, change.Status)
 			}
 			return nil
 		},
@@ -393,9 +572,476 @@ func TestOrderUseCase_ProcessOrderAccrual_InvalidStatus(t *testing.T) {
 	// Создаем usecase
 	uc := NewOrderUseCase(mockStorage, mockAccrual)
 
-	// Запускаем обработку заказа
-	uc.processOrderAccrual(orderNumber)
+	// Выполняем попытку обработки заказа
+	uc.processOrderAccrual(context.Background(), domain.AccrualJob{OrderNumber: orderNumber})
+}
+
+func TestOrderUseCase_DispatchWaitsForPause(t *testing.T) {
+	var claimedAt time.Time
+	mockStorage := &mocks.MockStorage{
+		ClaimAccrualJobsFunc: func(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error) {
+			claimedAt = time.Now()
+			return nil, nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+	pausedUntil := uc.throttle.Pause(50 * time.Millisecond)
+
+	// Задания арендуются только после паузы, чтобы аренда не истекла в ожидании опроса
+	uc.dispatchDueAccrualJobs(make(chan accrualTask))
+
+	if claimedAt.Before(pausedUntil) {
+		t.Errorf("Expected jobs to be claimed after pause until %v, claimed at %v", pausedUntil, claimedAt)
+	}
+}
+
+func TestOrderUseCase_AccrualWorker_ProcessesPendingJobsOnStart(t *testing.T) {
+	processed := make(chan string, 1)
+
+	mockStorage := &mocks.MockStorage{
+		ClaimAccrualJobsFunc: func(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error) {
+			if owner != "replica-1" {
+				t.Errorf("Expected jobs to be leased by %s, got %s", "replica-1", owner)
+			}
+			if leaseTTL != time.Minute {
+				t.Errorf("Expected lease TTL %v, got %v", time.Minute, leaseTTL)
+			}
+			// Задание осталось в очереди после перезапуска
+			return []domain.AccrualJob{{OrderNumber: "12345678903", Attempts: 3}}, nil
+		},
+		GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+			return &domain.Order{Number: number, UserID: 1, Status: domain.StatusProcessing}, nil
+		},
+		UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+			select {
+			case processed <- change.OrderNumber:
+			default:
+			}
+			return nil
+		},
+	}
+
+	mockAccrual := &mocks.MockAccrualService{
+		GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+			return &domain.Order{Number: orderNumber, Status: domain.StatusProcessed, Accrual: domain.Rubles(100)}, nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, mockAccrual)
+	uc.StartAccrualWorker(AccrualWorkerConfig{Workers: 2, InstanceID: "replica-1", LeaseTTL: time.Minute})
+	defer uc.Shutdown(context.Background())
+
+	select {
+	case number := <-processed:
+		if number != "12345678903" {
+			t.Errorf("Expected order %s, got %s", "12345678903", number)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pending job was not processed on start")
+	}
+}
+
+func TestOrderUseCase_AccrualWorker_BoundedConcurrency(t *testing.T) {
+	const workers = 3
+
+	var (
+		mu      sync.Mutex
+		active  int
+		maxSeen int
+		served  int
+	)
+	jobs := make([]domain.AccrualJob, 10)
+	for i := range jobs {
+		jobs[i] = domain.AccrualJob{OrderNumber: fmt.Sprintf("order-%d", i)}
+	}
+
+	var fetched bool
+	mockStorage := &mocks.MockStorage{
+		ClaimAccrualJobsFunc: func(ctx context.Context, owner string, limit int, leaseTTL time.Duration) ([]domain.AccrualJob, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if fetched {
+				return nil, nil
+			}
+			fetched = true
+			return jobs, nil
+		},
+	}
+
+	done := make(chan struct{})
+	mockAccrual := &mocks.MockAccrualService{
+		GetOrderAccrualFunc: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+			mu.Lock()
+			active++
+			if active > maxSeen {
+				maxSeen = active
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			served++
+			if served == len(jobs) {
+				close(done)
+			}
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, mockAccrual)
+	uc.StartAccrualWorker(AccrualWorkerConfig{Workers: workers})
+	defer uc.Shutdown(context.Background())
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Jobs were not processed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxSeen > workers {
+		t.Errorf("Expected at most %d concurrent requests, got %d", workers, maxSeen)
+	}
+}
+
+func TestOrderUseCase_ProcessOrderAccrual_RetriesExhausted(t *testing.T) {
+	tests := []struct {
+		name      string
+		job       domain.AccrualJob
+		accrual   func(ctx context.Context, orderNumber string) (*domain.Order, error)
+		wantStuck bool
+		wantClass domain.AccrualErrorClass
+	}{
+		{
+			name: "Превышено число попыток при ошибке хранилища",
+			job: domain.AccrualJob{
+				OrderNumber:   "12345678903",
+				ErrorClass:    domain.AccrualErrorStorage,
+				ClassAttempts: 19,
+				CreatedAt:     time.Now(),
+			},
+			accrual: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+				return &domain.Order{Number: orderNumber, Status: domain.StatusProcessed, Accrual: domain.Rubles(10)}, nil
+			},
+			wantStuck: true,
+		},
+		{
+			name: "Превышен возраст задания для незарегистрированного заказа",
+			job: domain.AccrualJob{
+				OrderNumber: "12345678903",
+				CreatedAt:   time.Now().Add(-73 * time.Hour),
+			},
+			accrual: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+				return nil, nil
+			},
+			wantStuck: true,
+		},
+		{
+			name: "Смена класса ошибки сбрасывает счетчик попыток",
+			job: domain.AccrualJob{
+				OrderNumber:   "12345678903",
+				ErrorClass:    domain.AccrualErrorUnavailable,
+				ClassAttempts: 19,
+				CreatedAt:     time.Now(),
+			},
+			accrual: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+				return &domain.Order{Number: orderNumber, Status: domain.StatusProcessed, Accrual: domain.Rubles(10)}, nil
+			},
+			wantClass: domain.AccrualErrorStorage,
+		},
+		{
+			name: "Некорректный ответ системы начислений",
+			job: domain.AccrualJob{
+				OrderNumber: "12345678903",
+				CreatedAt:   time.Now(),
+			},
+			accrual: func(ctx context.Context, orderNumber string) (*domain.Order, error) {
+				return nil, domain.NewAccrualResponseError(orderNumber, domain.ErrAccrualUnknownStatus, "got \"DONE\"")
+			},
+			wantClass: domain.AccrualErrorInvalidResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stuckError string
+			var rescheduledClass domain.AccrualErrorClass
+
+			mockStorage := &mocks.MockStorage{
+				GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+					return &domain.Order{Number: number, UserID: 1, Status: domain.StatusNew}, nil
+				},
+				UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+					return fmt.Errorf("database error")
+				},
+				RescheduleAccrualJobFunc: func(ctx context.Context, number, owner string, nextAttemptAt time.Time, errorClass domain.AccrualErrorClass, lastError string) error {
+					rescheduledClass = errorClass
+					return nil
+				},
+				MarkOrderStuckFunc: func(ctx context.Context, number, owner string, lastError string) error {
+					stuckError = lastError
+					return nil
+				},
+			}
+
+			uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{GetOrderAccrualFunc: tt.accrual})
+			uc.processOrderAccrual(context.Background(), tt.job)
+
+			if tt.wantStuck && stuckError == "" {
+				t.Errorf("Expected order to be marked as stuck with last error")
+			}
+			if !tt.wantStuck && stuckError != "" {
+				t.Errorf("Expected order not to be stuck, got %q", stuckError)
+			}
+			if !tt.wantStuck && rescheduledClass != tt.wantClass {
+				t.Errorf("Expected error class %s, got %s", tt.wantClass, rescheduledClass)
+			}
+		})
+	}
+}
+
+func TestOrderUseCase_ApplyAccrualCallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		order         *domain.Order
+		lookupErr     error
+		expectedError error
+		expectUpdate  bool
+	}{
+		{
+			name:         "Окончательный статус из уведомления",
+			order:        &domain.Order{Number: "12345678903", Status: domain.StatusProcessed, Accrual: domain.Rubles(500)},
+			expectUpdate: true,
+		},
+		{
+			name:         "Промежуточный статус из уведомления",
+			order:        &domain.Order{Number: "12345678903", Status: domain.StatusProcessing},
+			expectUpdate: true,
+		},
+		{
+			name:          "Уведомление о неизвестном заказе",
+			order:         &domain.Order{Number: "79927398713", Status: domain.StatusProcessed, Accrual: domain.Rubles(500)},
+			lookupErr:     domain.ErrOrderNotFound,
+			expectedError: domain.ErrOrderNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updatedUserID int64
+			mockStorage := &mocks.MockStorage{
+				GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+					if tt.lookupErr != nil {
+						return nil, tt.lookupErr
+					}
+					return &domain.Order{Number: number, UserID: 7, Status: domain.StatusNew}, nil
+				},
+				UpdateOrderStatusAndBalanceFunc: func(ctx context.Context, change domain.OrderStatusChange, userID int64) error {
+					if change.Status != tt.order.Status || change.Accrual != tt.order.Accrual {
+						t.Errorf("Expected %s with accrual %v, got %s with %v", tt.order.Status, tt.order.Accrual, change.Status, change.Accrual)
+					}
+					if change.Source != domain.StatusSourceCallback {
+						t.Errorf("Expected source %s, got %s", domain.StatusSourceCallback, change.Source)
+					}
+					updatedUserID = userID
+					return nil
+				},
+			}
+
+			uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+			err := uc.ApplyAccrualCallback(context.Background(), tt.order)
+
+			if !errors.Is(err, tt.expectedError) {
+				t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if tt.expectUpdate && updatedUserID != 7 {
+				t.Errorf("Expected balance of owner 7 to be updated, got %d", updatedUserID)
+			}
+		})
+	}
+}
+
+func TestOrderUseCase_UploadOrder_DelaysPollingForCallback(t *testing.T) {
+	var firstAttempt time.Time
+	mockStorage := &mocks.MockStorage{
+		GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+			return nil, domain.ErrOrderNotFound
+		},
+		CreateOrderFunc: func(ctx context.Context, userID int64, number string, firstAttemptAt time.Time) error {
+			firstAttempt = firstAttemptAt
+			return nil
+		},
+	}
+
+	uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+	uc.callbackTimeout = time.Minute
+
+	if err := uc.UploadOrder(context.Background(), 1, "12345678903"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wait := time.Until(firstAttempt); wait < 50*time.Second {
+		t.Errorf("Expected first poll to wait for callback timeout, got %v", wait)
+	}
+}
+
+func TestOrderUseCase_GetUserOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         int64
+		status         domain.OrderStatus
+		lookupErr      error
+		expectedError  error
+		expectedStatus domain.OrderStatus
+	}{
+		{
+			name:           "Свой заказ",
+			userID:         1,
+			status:         domain.StatusProcessed,
+			expectedStatus: domain.StatusProcessed,
+		},
+		{
+			name:           "Зависший заказ показывается как PROCESSING",
+			userID:         1,
+			status:         domain.StatusStuck,
+			expectedStatus: domain.StatusProcessing,
+		},
+		{
+			name:          "Заказ другого пользователя",
+			userID:        2,
+			expectedError: domain.ErrOrderNotFound,
+		},
+		{
+			name:          "Неизвестный заказ",
+			userID:        1,
+			lookupErr:     domain.ErrOrderNotFound,
+			expectedError: domain.ErrOrderNotFound,
+		},
+		{
+			name:          "Ошибка хранилища",
+			userID:        1,
+			lookupErr:     errors.New("database error"),
+			expectedError: errors.New("database error"),
+		},
+	}
 
-	// Проверяем, что все методы были вызваны
-	// Добавьте здесь дополнительные проверки, если необходимо
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mocks.MockStorage{
+				GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+					if tt.lookupErr != nil {
+						return nil, tt.lookupErr
+					}
+					return &domain.Order{Number: number, UserID: 1, Status: tt.status}, nil
+				},
+			}
+
+			uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+			order, err := uc.GetUserOrder(context.Background(), tt.userID, "12345678903")
+
+			if tt.expectedError != nil {
+				if err == nil || err.Error() != tt.expectedError.Error() {
+					t.Fatalf("Expected error %v, got %v", tt.expectedError, err)
+				}
+				if order != nil {
+					t.Errorf("Expected no order, got %+v", order)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if order.Number != "12345678903" {
+				t.Errorf("Expected order 12345678903, got %s", order.Number)
+			}
+			if order.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, order.Status)
+			}
+		})
+	}
+}
+
+func TestOrderUseCase_GetOrderHistory(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        int64
+		lookupErr     error
+		history       []domain.OrderStatusTransition
+		expectedError error
+		expected      []domain.OrderStatusTransition
+	}{
+		{
+			name:   "История своего заказа",
+			userID: 1,
+			history: []domain.OrderStatusTransition{
+				{To: domain.StatusNew, Source: domain.StatusSourceUpload},
+				{From: domain.StatusNew, To: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 2},
+			},
+			expected: []domain.OrderStatusTransition{
+				{To: domain.StatusNew, Source: domain.StatusSourceUpload},
+				{From: domain.StatusNew, To: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 2},
+			},
+		},
+		{
+			name:   "Переход в STUCK скрыт от пользователя",
+			userID: 1,
+			history: []domain.OrderStatusTransition{
+				{To: domain.StatusNew, Source: domain.StatusSourceUpload},
+				{From: domain.StatusNew, To: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 2},
+				{From: domain.StatusProcessing, To: domain.StatusStuck, Source: domain.StatusSourceRetryPolicy},
+				{From: domain.StatusStuck, To: domain.StatusNew, Source: domain.StatusSourceAdmin},
+			},
+			expected: []domain.OrderStatusTransition{
+				{To: domain.StatusNew, Source: domain.StatusSourceUpload},
+				{From: domain.StatusNew, To: domain.StatusProcessing, Source: domain.StatusSourcePoll, Attempt: 2},
+				{From: domain.StatusProcessing, To: domain.StatusNew, Source: domain.StatusSourceAdmin},
+			},
+		},
+		{
+			name:          "Заказ другого пользователя",
+			userID:        2,
+			expectedError: domain.ErrOrderNotFound,
+		},
+		{
+			name:          "Неизвестный заказ",
+			userID:        1,
+			lookupErr:     domain.ErrOrderNotFound,
+			expectedError: domain.ErrOrderNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mocks.MockStorage{
+				GetOrderByNumberFunc: func(ctx context.Context, number string) (*domain.Order, error) {
+					if tt.lookupErr != nil {
+						return nil, tt.lookupErr
+					}
+					return &domain.Order{Number: number, UserID: 1, Status: domain.StatusProcessing}, nil
+				},
+				GetOrderStatusHistoryFunc: func(ctx context.Context, number string) ([]domain.OrderStatusTransition, error) {
+					return tt.history, nil
+				},
+			}
+
+			uc := NewOrderUseCase(mockStorage, &mocks.MockAccrualService{})
+			history, err := uc.GetOrderHistory(context.Background(), tt.userID, "12345678903")
+
+			if !errors.Is(err, tt.expectedError) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if len(history) != len(tt.expected) {
+				t.Fatalf("Expected %d transitions, got %+v", len(tt.expected), history)
+			}
+			for i, transition := range history {
+				if transition != tt.expected[i] {
+					t.Errorf("Transition %d: expected %+v, got %+v", i, tt.expected[i], transition)
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,32 @@
+package usecase
+
+const (
+	// defaultListLimit - размер страницы списков заказов и списаний, если задан только курсор
+	defaultListLimit = 100
+	// maxListLimit - наибольший размер страницы списков заказов и списаний
+	maxListLimit = 1000
+)
+
+// pageLimit приводит запрошенный размер страницы к допустимому: неположительный
+// заменяется на defaultLimit, превышающий maxLimit ограничивается им
+func pageLimit(limit, defaultLimit, maxLimit int) int {
+	switch {
+	case limit <= 0:
+		return defaultLimit
+	case limit > maxLimit:
+		return maxLimit
+	}
+	return limit
+}
+
+// listFetchLimit возвращает размер страницы списка заказов или списаний и число записей,
+// запрашиваемых у хранилища. Без limit и курсора список, как и раньше, отдается целиком,
+// и хранилище получает 0. Иначе запрашивается лишняя запись: она показывает, есть ли
+// следующая страница.
+func listFetchLimit(limit int, hasCursor bool) (pageSize, fetch int) {
+	if limit <= 0 && !hasCursor {
+		return 0, 0
+	}
+	pageSize = pageLimit(limit, defaultListLimit, maxListLimit)
+	return pageSize, pageSize + 1
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_accrual_jobs_next_attempt_at;
+DROP TABLE IF EXISTS accrual_jobs;
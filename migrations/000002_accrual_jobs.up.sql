@@ -0,0 +1,15 @@
+-- Очередь заданий на опрос системы начислений
+CREATE TABLE IF NOT EXISTS accrual_jobs (
+    order_number TEXT PRIMARY KEY REFERENCES orders(number) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_jobs_next_attempt_at ON accrual_jobs(next_attempt_at);
+
+-- Ставим в очередь заказы, которые ещё не получили окончательный статус
+INSERT INTO accrual_jobs (order_number)
+SELECT number FROM orders WHERE status IN ('NEW', 'PROCESSING')
+ON CONFLICT (order_number) DO NOTHING;
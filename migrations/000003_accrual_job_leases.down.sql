@@ -0,0 +1,2 @@
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS locked_by;
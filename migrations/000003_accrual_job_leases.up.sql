@@ -0,0 +1,3 @@
+-- Аренда заданий: задание обрабатывает только одна реплика до истечения срока аренды
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS locked_by TEXT;
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,12 @@
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS class_attempts;
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS error_class;
+
+-- Зависшие заказы возвращаются в очередь опроса
+INSERT INTO accrual_jobs (order_number)
+SELECT number FROM orders WHERE status = 'STUCK'
+ON CONFLICT (order_number) DO NOTHING;
+UPDATE orders SET status = 'NEW' WHERE status = 'STUCK';
+
+ALTER TABLE orders DROP COLUMN IF EXISTS last_error;
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status CHECK (status IN ('NEW', 'PROCESSING', 'INVALID', 'PROCESSED'));
@@ -0,0 +1,8 @@
+-- Статус STUCK: попытки опроса исчерпаны, заказ требует ручного разбора
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS valid_status;
+ALTER TABLE orders ADD CONSTRAINT valid_status CHECK (status IN ('NEW', 'PROCESSING', 'INVALID', 'PROCESSED', 'STUCK'));
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+-- Класс последней ошибки и число подряд идущих попыток с этим классом
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS error_class TEXT;
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS class_attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_processed_at;
+DROP TABLE IF EXISTS accrual_adjustments;
+ALTER TABLE balances DROP COLUMN IF EXISTS debt;
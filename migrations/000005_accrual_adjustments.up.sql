@@ -0,0 +1,17 @@
+-- Долг пользователя: часть отрицательных корректировок, не покрытая балансом
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS debt DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- Корректировки начислений, найденные при сверке с системой начислений
+CREATE TABLE IF NOT EXISTS accrual_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    order_number TEXT NOT NULL REFERENCES orders(number),
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    previous_accrual DECIMAL(10, 2) NOT NULL,
+    new_accrual DECIMAL(10, 2) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    debt DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_accrual_adjustments_order_number ON accrual_adjustments(order_number);
+CREATE INDEX IF NOT EXISTS idx_orders_processed_at ON orders(processed_at) WHERE status = 'PROCESSED';
@@ -0,0 +1,22 @@
+-- История переходов заказов между статусами
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_number TEXT NOT NULL REFERENCES orders(number),
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    accrual DECIMAL(10, 2),
+    source VARCHAR(50) NOT NULL,
+    attempt INTEGER,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_number ON order_status_history(order_number, id);
+
+-- Для существующих заказов восстанавливаем загрузку и текущий статус
+INSERT INTO order_status_history (order_number, to_status, source, changed_at)
+SELECT number, 'NEW', 'upload', uploaded_at FROM orders;
+
+INSERT INTO order_status_history (order_number, from_status, to_status, accrual, source, changed_at)
+SELECT number, 'NEW', status, accrual, 'backfill', COALESCE(processed_at, uploaded_at)
+FROM orders
+WHERE status <> 'NEW';
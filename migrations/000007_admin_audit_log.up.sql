@@ -0,0 +1,13 @@
+-- Журнал административных операций с заказами
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    order_number TEXT,
+    details TEXT,
+    reason TEXT,
+    affected INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_order_number ON admin_audit_log(order_number);
@@ -0,0 +1,112 @@
+-- Журнал операций с баллами по двойной записи. Каждая операция проводится несколькими
+-- записями с общим transaction_id по разным счетам, сумма записей операции равна нулю.
+CREATE SEQUENCE IF NOT EXISTS ledger_transaction_id_seq;
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    account VARCHAR(20) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount <> 0),
+    -- Источник операции: заказ начисления или списания и корректировка сверки
+    order_number TEXT,
+    adjustment_id BIGINT REFERENCES accrual_adjustments(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_ledger_account CHECK (account IN ('current', 'withdrawn', 'debt', 'accrual_system')),
+    CONSTRAINT valid_ledger_kind CHECK (kind IN ('accrual', 'withdrawal', 'adjustment', 'reversal'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user_account ON ledger_entries(user_id, account);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_user_transaction ON ledger_entries(user_id, transaction_id DESC);
+
+-- Записи журнала не изменяются и не удаляются: ошибки исправляются новыми операциями
+CREATE OR REPLACE FUNCTION ledger_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_entries_append_only();
+
+-- Переносим в журнал начисления, списания и корректировки в порядке их проведения.
+-- Начисление, исправленное сверкой, восстанавливается до первой корректировки.
+WITH sources AS (
+    SELECT 'accrual' AS kind, o.user_id, o.number AS order_number, NULL::BIGINT AS adjustment_id,
+           COALESCE(first_adjustment.previous_accrual, o.accrual) AS amount, 0::DECIMAL AS debt,
+           COALESCE(o.processed_at, o.uploaded_at) AS created_at
+    FROM orders o
+    LEFT JOIN LATERAL (
+        SELECT a.previous_accrual
+        FROM accrual_adjustments a
+        WHERE a.order_number = o.number
+        ORDER BY a.id
+        LIMIT 1
+    ) first_adjustment ON true
+    WHERE o.status = 'PROCESSED'
+      AND COALESCE(first_adjustment.previous_accrual, o.accrual) > 0
+
+    UNION ALL
+
+    SELECT 'withdrawal', user_id, order_number, NULL, sum, 0, processed_at
+    FROM withdrawals
+
+    UNION ALL
+
+    SELECT CASE WHEN amount > 0 THEN 'adjustment' ELSE 'reversal' END,
+           user_id, order_number, id, amount, debt, created_at
+    FROM accrual_adjustments
+    WHERE amount <> 0
+),
+numbered AS (
+    SELECT row_number() OVER (ORDER BY created_at, order_number, kind) AS transaction_id, *
+    FROM sources
+)
+INSERT INTO ledger_entries (transaction_id, user_id, account, kind, amount, order_number, adjustment_id, created_at)
+SELECT n.transaction_id, n.user_id, legs.account, n.kind, legs.amount, n.order_number, n.adjustment_id, n.created_at
+FROM numbered n
+CROSS JOIN LATERAL (VALUES
+    ('accrual_system', CASE WHEN n.kind = 'withdrawal' THEN 0 ELSE -n.amount END),
+    ('current', CASE WHEN n.kind = 'withdrawal' THEN -n.amount ELSE n.amount + n.debt END),
+    ('withdrawn', CASE WHEN n.kind = 'withdrawal' THEN n.amount ELSE 0 END),
+    ('debt', -n.debt)
+) AS legs(account, amount)
+WHERE legs.amount <> 0;
+
+-- Погашение долга доначислениями раньше не сохранялось: расхождение с балансами
+-- проводится одной корректировкой на пользователя без заказа
+WITH ledger AS (
+    SELECT user_id,
+           COALESCE(SUM(amount) FILTER (WHERE account = 'current'), 0) AS current,
+           COALESCE(SUM(amount) FILTER (WHERE account = 'withdrawn'), 0) AS withdrawn,
+           -COALESCE(SUM(amount) FILTER (WHERE account = 'debt'), 0) AS debt
+    FROM ledger_entries
+    GROUP BY user_id
+),
+corrections AS (
+    SELECT (SELECT COALESCE(MAX(transaction_id), 0) FROM ledger_entries)
+               + row_number() OVER (ORDER BY b.user_id) AS transaction_id,
+           b.user_id,
+           b.current - COALESCE(l.current, 0) AS current,
+           b.withdrawn - COALESCE(l.withdrawn, 0) AS withdrawn,
+           b.debt - COALESCE(l.debt, 0) AS debt
+    FROM balances b
+    LEFT JOIN ledger l ON l.user_id = b.user_id
+    WHERE b.current <> COALESCE(l.current, 0)
+       OR b.withdrawn <> COALESCE(l.withdrawn, 0)
+       OR b.debt <> COALESCE(l.debt, 0)
+)
+INSERT INTO ledger_entries (transaction_id, user_id, account, kind, amount)
+SELECT c.transaction_id, c.user_id, legs.account, 'adjustment', legs.amount
+FROM corrections c
+CROSS JOIN LATERAL (VALUES
+    ('current', c.current),
+    ('withdrawn', c.withdrawn),
+    ('debt', -c.debt),
+    ('accrual_system', c.debt - c.current - c.withdrawn)
+) AS legs(account, amount)
+WHERE legs.amount <> 0;
+
+SELECT setval('ledger_transaction_id_seq', COALESCE((SELECT MAX(transaction_id) FROM ledger_entries), 0) + 1, false);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS withdrawal_idempotency_keys;
+DROP INDEX IF EXISTS idx_withdrawals_order_number;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS duplicate_of;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS id;
@@ -0,0 +1,28 @@
+-- Идентификатор списания возвращается клиенту
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS id BIGSERIAL PRIMARY KEY;
+
+-- Повторные списания по одному заказу, проведенные до введения уникальности, остаются
+-- в истории и ссылаются на первое списание
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS duplicate_of BIGINT REFERENCES withdrawals(id);
+
+UPDATE withdrawals w
+SET duplicate_of = first_withdrawal.id
+FROM (
+    SELECT order_number, MIN(id) AS id
+    FROM withdrawals
+    GROUP BY order_number
+    HAVING COUNT(*) > 1
+) first_withdrawal
+WHERE w.order_number = first_withdrawal.order_number
+  AND w.id <> first_withdrawal.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_withdrawals_order_number ON withdrawals(order_number) WHERE duplicate_of IS NULL;
+
+-- Ключи идемпотентности запросов на списание
+CREATE TABLE IF NOT EXISTS withdrawal_idempotency_keys (
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    key VARCHAR(255) NOT NULL,
+    withdrawal_id BIGINT NOT NULL REFERENCES withdrawals(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, key)
+);
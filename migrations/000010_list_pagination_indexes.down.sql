@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders(user_id);
+CREATE INDEX IF NOT EXISTS idx_withdrawals_user_id ON withdrawals(user_id);
+
+DROP INDEX IF EXISTS idx_withdrawals_user_processed;
+DROP INDEX IF EXISTS idx_orders_user_processed;
+DROP INDEX IF EXISTS idx_orders_user_status_uploaded;
+DROP INDEX IF EXISTS idx_orders_user_uploaded;
@@ -0,0 +1,15 @@
+-- Постраничная выборка заказов пользователя от новых к старым
+CREATE INDEX IF NOT EXISTS idx_orders_user_uploaded ON orders(user_id, uploaded_at DESC, number DESC);
+
+-- Выборка заказов пользователя с фильтром по статусу
+CREATE INDEX IF NOT EXISTS idx_orders_user_status_uploaded ON orders(user_id, status, uploaded_at DESC, number DESC);
+
+-- Фильтр заказов пользователя по времени обработки
+CREATE INDEX IF NOT EXISTS idx_orders_user_processed ON orders(user_id, processed_at) WHERE processed_at IS NOT NULL;
+
+-- Постраничная выборка списаний пользователя от новых к старым
+CREATE INDEX IF NOT EXISTS idx_withdrawals_user_processed ON withdrawals(user_id, processed_at DESC, id DESC);
+
+-- Новые индексы начинаются с user_id и заменяют индексы только по пользователю
+DROP INDEX IF EXISTS idx_orders_user_id;
+DROP INDEX IF EXISTS idx_withdrawals_user_id;
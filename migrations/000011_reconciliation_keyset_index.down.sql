@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_orders_processed_at ON orders(processed_at) WHERE status = 'PROCESSED';
+
+DROP INDEX IF EXISTS idx_orders_processed_keyset;
@@ -0,0 +1,4 @@
+-- Сверка читает обработанные заказы пачками от новых к старым с продолжением по (processed_at, number)
+CREATE INDEX IF NOT EXISTS idx_orders_processed_keyset ON orders(processed_at DESC, number DESC) WHERE status = 'PROCESSED';
+
+DROP INDEX IF EXISTS idx_orders_processed_at;
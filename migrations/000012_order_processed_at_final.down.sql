@@ -0,0 +1 @@
+-- Сброшенное время обработки незавершенных заказов не восстанавливается
@@ -0,0 +1,3 @@
+-- Время обработки есть только у заказов с окончательным статусом; раньше оно
+-- проставлялось при любой смене статуса и попадало в фильтр по времени обработки
+UPDATE orders SET processed_at = NULL WHERE status NOT IN ('PROCESSED', 'INVALID') AND processed_at IS NOT NULL;
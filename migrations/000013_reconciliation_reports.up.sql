@@ -0,0 +1,9 @@
+-- Отчеты проходов сверки: проход выполняет одна реплика, а отчет отдают все
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    checked INTEGER NOT NULL,
+    failed INTEGER NOT NULL,
+    discrepancies JSONB NOT NULL DEFAULT '[]'
+);
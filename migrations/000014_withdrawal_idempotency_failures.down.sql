@@ -0,0 +1,6 @@
+DELETE FROM withdrawal_idempotency_keys WHERE withdrawal_id IS NULL;
+ALTER TABLE withdrawal_idempotency_keys DROP CONSTRAINT IF EXISTS withdrawal_idempotency_keys_outcome;
+ALTER TABLE withdrawal_idempotency_keys DROP COLUMN IF EXISTS failure;
+ALTER TABLE withdrawal_idempotency_keys DROP COLUMN IF EXISTS sum;
+ALTER TABLE withdrawal_idempotency_keys DROP COLUMN IF EXISTS order_number;
+ALTER TABLE withdrawal_idempotency_keys ALTER COLUMN withdrawal_id SET NOT NULL;
@@ -0,0 +1,8 @@
+-- Ключ запроса, завершившегося отказом, хранит параметры запроса и причину отказа
+-- вместо списания: повтор с тем же ключом получает тот же отказ
+ALTER TABLE withdrawal_idempotency_keys ALTER COLUMN withdrawal_id DROP NOT NULL;
+ALTER TABLE withdrawal_idempotency_keys ADD COLUMN IF NOT EXISTS order_number VARCHAR(255);
+ALTER TABLE withdrawal_idempotency_keys ADD COLUMN IF NOT EXISTS sum DECIMAL(10, 2);
+ALTER TABLE withdrawal_idempotency_keys ADD COLUMN IF NOT EXISTS failure VARCHAR(32);
+ALTER TABLE withdrawal_idempotency_keys ADD CONSTRAINT withdrawal_idempotency_keys_outcome
+    CHECK ((withdrawal_id IS NULL) <> (failure IS NULL));
@@ -0,0 +1,9 @@
+// Package migrations встраивает SQL-миграции схемы базы данных в исполняемый файл
+package migrations
+
+import "embed"
+
+// FS содержит файлы миграций в формате golang-migrate: NNNNNN_name.up.sql и NNNNNN_name.down.sql
+//
+//go:embed *.sql
+var FS embed.FS